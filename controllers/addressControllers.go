@@ -19,32 +19,178 @@ package controllers
 */
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/greeneg/ipmanager/helpers"
+	"github.com/greeneg/ipmanager/globals"
 	"github.com/greeneg/ipmanager/model"
 )
 
-func (i *IpManager) GetAddresses(c *gin.Context) {
-	addrs, err := model.GetAddresses()
-	helpers.CheckError(err)
+// AssignAddress Assign an address from a subnet to a host
+//
+//	@Summary		Assign address
+//	@Description	Assign an address from a subnet to a host
+//	@Tags			address
+//	@Accept			json
+//	@Produce		json
+//	@Param			address	body	model.AddressAssignment	true	"Address assignment data"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		409	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
+//	@Router			/address [post]
+func (i *IpManager) AssignAddress(c *gin.Context) {
+	var json model.AddressAssignment
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	// need to get our current user context to get the CreatorId. AuthCheck stores it however
+	// the request was authenticated
+	user, exists := c.Get(globals.UserKey)
+	// if missing, we have an issue
+	if !exists {
+		c.Error(&model.AccessDenied{Err: errors.New("Insufficient access. Access denied!")})
+		return
+	}
+
+	// convert user interface to a string
+	username := fmt.Sprintf("%v", user)
+	// lets output our session user
+	log.Println("INFO: Session user: " + username)
+	// get our user id
+	userObject, err := model.Repo.GetUserByUserName(username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// what is our user Id
+	log.Println("INFO: Session user's ID: " + strconv.Itoa(userObject.Id))
+
+	s, err := model.Repo.AssignAddress(json, userObject.Id)
+	if s {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Address '" + json.Address + "' has been assigned to host '" + json.HostName + "'"})
+	} else {
+		c.Error(err)
+	}
+}
+
+// ReassignAddress Change the host or domain an address is assigned to
+//
+//	@Summary		Reassign address
+//	@Description	Change the host or domain an address is assigned to
+//	@Tags			address
+//	@Accept			json
+//	@Produce		json
+//	@Param			address	path	string	true	"IP address"
+//	@Param			addressReassignment	body	model.AddressReassignment	true	"Address reassignment data"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
+//	@Router			/address/{address} [patch]
+func (i *IpManager) ReassignAddress(c *gin.Context) {
+	address := c.Param("address")
+	var json model.AddressReassignment
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
 
-	if addrs == nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no records found!"})
+	status, err := model.Repo.ReassignAddress(address, json)
+	if err != nil {
+		log.Println("ERROR: Cannot reassign address '" + address + "': " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	if status {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Address '" + address + "' has been reassigned"})
 	} else {
-		c.IndentedJSON(http.StatusOK, gin.H{"data": addrs})
+		c.Error(errors.New("Unable to reassign address!"))
+	}
+}
+
+// ReleaseAddress Release an address assignment
+//
+//	@Summary		Release address
+//	@Description	Remove an address assignment, returning the address to its subnet's pool
+//	@Tags			address
+//	@Produce		json
+//	@Param			address	path	string	true	"IP address"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		404	{object}	model.Problem
+//	@Router			/address/{address} [delete]
+func (i *IpManager) ReleaseAddress(c *gin.Context) {
+	address := c.Param("address")
+	status, err := model.Repo.ReleaseAddress(address)
+	if err != nil {
+		log.Println("ERROR: Cannot release address '" + address + "': " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	if status {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Address '" + address + "' has been released"})
+	} else {
+		c.Error(errors.New("Unable to release address!"))
+	}
+}
+
+// GetAddresses Retrieve a list of assigned addresses
+//
+//	@Summary		Retrieve a list of assigned addresses
+//	@Description	Retrieve a page of the assigned addresses. Any other field of an address can be filtered on by passing it as a parameter, and sorted on
+//	@Tags			address
+//	@Produce		json
+//	@Param			limit			query	int		false	"Maximum number of addresses to return, at most 1000"	default(100)
+//	@Param			offset			query	int		false	"Number of addresses to skip"
+//	@Param			sort			query	string	false	"Comma separated fields to sort on, each prefixed with - for descending order"
+//	@Param			creatorId		query	int		false	"Only addresses created by this user Id"
+//	@Param			createdAfter	query	string	false	"Only addresses created at or after this time, as an RFC 3339 timestamp or a date"
+//	@Param			createdBefore	query	string	false	"Only addresses created before this time, as an RFC 3339 timestamp or a date"
+//	@Success		200	{object}	model.AddressList
+//	@Failure		400	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
+//	@Router			/addresses [get]
+func (i *IpManager) GetAddresses(c *gin.Context) {
+	o, err := listOptions(c)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	addrs, total, err := model.Repo.GetAddresses(o)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+
+	writePage(c, addrs, total, o)
 }
 
 func (i *IpManager) GetAddressByHostName(c *gin.Context) {
 	hostName := c.Param("hostname")
-	ent, err := model.GetAddressByHostName(hostName)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressByHostName(hostName)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.Address == "" {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found for " + hostName})
+		c.Error(&model.NotFound{Err: errors.New("no records found for " + hostName)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
@@ -52,12 +198,15 @@ func (i *IpManager) GetAddressByHostName(c *gin.Context) {
 
 func (i *IpManager) GetAddressByHostNameId(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("hostid"))
-	ent, err := model.GetAddressByHostNameId(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressByHostNameId(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.Address == "" {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found for id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found for id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
@@ -65,12 +214,15 @@ func (i *IpManager) GetAddressByHostNameId(c *gin.Context) {
 
 func (i *IpManager) GetAddressById(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	ent, err := model.GetAddressById(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressById(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.Address == "" {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found for id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found for id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
@@ -78,11 +230,14 @@ func (i *IpManager) GetAddressById(c *gin.Context) {
 
 func (i *IpManager) GetAddressByIpAddress(c *gin.Context) {
 	ip := c.Param("ip")
-	ent, err := model.GetAddressByIpAddress(ip)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressByIpAddress(ip)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.Address == "" {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found for IP address " + ip})
+		c.Error(&model.NotFound{Err: errors.New("no records found for IP address " + ip)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
@@ -90,12 +245,15 @@ func (i *IpManager) GetAddressByIpAddress(c *gin.Context) {
 
 func (i *IpManager) GetAddressesByDomainId(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("domainid"))
-	ent, err := model.GetAddressesByDomainId(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressesByDomainId(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent == nil {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with domain id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, gin.H{"data": ent})
 	}
@@ -103,11 +261,14 @@ func (i *IpManager) GetAddressesByDomainId(c *gin.Context) {
 
 func (i *IpManager) GetAddressesByDomainName(c *gin.Context) {
 	domainname := c.Param("domainname")
-	ent, err := model.GetAddressesByDomainName(domainname)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressesByDomainName(domainname)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent == nil {
-		c.IndentedJSON(http.StatusOK, gin.H{"error": "no records found with domain name " + domainname})
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain name " + domainname)})
 	} else {
 		c.IndentedJSON(http.StatusOK, gin.H{"data": ent})
 	}
@@ -115,12 +276,15 @@ func (i *IpManager) GetAddressesByDomainName(c *gin.Context) {
 
 func (i *IpManager) GetAddressesBySubnetId(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("subnetid"))
-	ent, err := model.GetAddressesBySubnetId(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressesBySubnetId(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent == nil {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with subnet id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found with subnet id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, gin.H{"data": ent})
 	}
@@ -128,11 +292,70 @@ func (i *IpManager) GetAddressesBySubnetId(c *gin.Context) {
 
 func (i *IpManager) GetAddressesBySubnetName(c *gin.Context) {
 	subnetname := c.Param("subnetname")
-	ent, err := model.GetAddressesBySubnetName(subnetname)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetAddressesBySubnetName(subnetname)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if ent == nil {
+		c.Error(&model.NotFound{Err: errors.New("no records found with subnet name " + subnetname)})
+	} else {
+		c.IndentedJSON(http.StatusOK, gin.H{"data": ent})
+	}
+}
+
+// GetUnassignedAddressesBySubnetName Retrieve the unassigned addresses of a subnet
+//
+//	@Summary		Retrieve the unassigned addresses of a subnet
+//	@Description	Retrieve the unassigned addresses of a subnet, or just how many there are when count is set, as a string since an IPv6 subnet can hold more than a JSON number can
+//	@Tags			address
+//	@Produce		json
+//	@Param			subnetname	path	string	true	"Subnet name"
+//	@Param			limit	query	int		false	"Maximum number of addresses to return"
+//	@Param			offset	query	int		false	"Number of addresses to skip"
+//	@Param			count	query	bool	false	"Only return the number of unassigned addresses"
+//	@Success		200	{object}	model.UnassignedAddressList
+//	@Failure		400	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Router			/addresses/subnet/name/{subnetname}/unassigned [get]
+func (i *IpManager) GetUnassignedAddressesBySubnetName(c *gin.Context) {
+	subnetname := c.Param("subnetname")
+
+	countOnly, err := strconv.ParseBool(c.DefaultQuery("count", "false"))
+	if err != nil {
+		c.Error(errors.New("invalid value for count: " + c.Query("count"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if countOnly {
+		count, err := model.Repo.CountUnassignedAddressesBySubnetName(subnetname)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{"count": count.String()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.Error(errors.New("invalid value for limit: " + c.Query("limit"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.Error(errors.New("invalid value for offset: " + c.Query("offset"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	ent, err := model.Repo.GetUnassignedAddressesBySubnetName(subnetname, limit, offset)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent == nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with subnet name " + subnetname})
+		c.Error(&model.NotFound{Err: errors.New("no records found with subnet name " + subnetname)})
 	} else {
 		c.IndentedJSON(http.StatusOK, gin.H{"data": ent})
 	}
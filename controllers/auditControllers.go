@@ -0,0 +1,112 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/model"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditTime accepts an RFC 3339 timestamp or a plain date, returning it in the UTC form the
+// database stores
+func auditTime(value string) (string, bool) {
+	if value == "" {
+		return "", true
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format("2006-01-02 15:04:05"), true
+		}
+	}
+	return "", false
+}
+
+// GetAuditLog Retrieve audit log entries
+//
+//	@Summary		Retrieve audit log entries
+//	@Description	Retrieve the record of mutating API calls, newest first, optionally filtered
+//	@Tags			audit
+//	@Produce		json
+//	@Param			user		query	string	false	"User name"
+//	@Param			objectType	query	string	false	"Object type, one of address, domain, host, subnet or user"
+//	@Param			objectId	query	string	false	"Object name or address"
+//	@Param			method		query	string	false	"HTTP method"
+//	@Param			since		query	string	false	"Earliest entry, as an RFC 3339 timestamp or a date"
+//	@Param			until		query	string	false	"Entries before this time, as an RFC 3339 timestamp or a date"
+//	@Param			limit		query	int		false	"Maximum number of entries to return, at most 1000"
+//	@Param			offset		query	int		false	"Number of entries to skip"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.AuditEntryList
+//	@Failure		400	{object}	model.Problem
+//	@Failure		500	{object}	model.Problem
+//	@Router			/audit [get]
+func (i *IpManager) GetAuditLog(c *gin.Context) {
+	filter := model.AuditFilter{
+		UserName:   c.Query("user"),
+		ObjectType: c.Query("objectType"),
+		ObjectId:   c.Query("objectId"),
+		Method:     strings.ToUpper(c.Query("method")),
+	}
+
+	var ok bool
+	filter.Since, ok = auditTime(c.Query("since"))
+	if !ok {
+		c.Error(errors.New("invalid value for since: " + c.Query("since"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+	filter.Until, ok = auditTime(c.Query("until"))
+	if !ok {
+		c.Error(errors.New("invalid value for until: " + c.Query("until"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	var err error
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditLimit)))
+	if err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+		c.Error(errors.New("invalid value for limit: " + c.Query("limit"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+	filter.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || filter.Offset < 0 {
+		c.Error(errors.New("invalid value for offset: " + c.Query("offset"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	entries, err := model.Repo.GetAuditEntries(filter)
+	if err != nil {
+		log.Println("ERROR: Cannot get audit entries: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"data": entries})
+}
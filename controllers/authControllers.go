@@ -0,0 +1,168 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/helpers"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// session keys holding the OIDC login in progress
+const (
+	oidcStateKey    = "oidcState"
+	oidcNonceKey    = "oidcNonce"
+	oidcVerifierKey = "oidcVerifier"
+)
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// OidcLogin Start an OpenID Connect login
+//
+//	@Summary		Start an OpenID Connect login
+//	@Description	Redirect the browser to the identity provider to log in
+//	@Tags			auth
+//	@Success		302
+//	@Failure		404	{object}	model.Problem
+//	@Failure		502	{object}	model.Problem
+//	@Router			/auth/oidc/login [get]
+func (i *IpManager) OidcLogin(c *gin.Context) {
+	if i.Oidc == nil {
+		c.Error(&model.NotFound{Err: errors.New("OIDC login is not configured")})
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	redirect, err := i.Oidc.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Println("ERROR: Cannot reach OIDC provider: " + string(err.Error()))
+		c.Error(errors.New("Unable to reach identity provider! " + string(err.Error()))).SetMeta(http.StatusBadGateway)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(oidcStateKey, state)
+	session.Set(oidcNonceKey, nonce)
+	session.Set(oidcVerifierKey, verifier)
+	if err := session.Save(); err != nil {
+		c.Error(errors.New("failed to save user session"))
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// OidcCallback Finish an OpenID Connect login
+//
+//	@Summary		Finish an OpenID Connect login
+//	@Description	Exchange the authorization code from the identity provider and start a session for the mapped local user
+//	@Tags			auth
+//	@Produce		json
+//	@Param			code	query	string	true	"Authorization code"
+//	@Param			state	query	string	true	"Login state"
+//	@Success		200	{object}	model.SuccessMsg
+//	@Success		302
+//	@Failure		400	{object}	model.Problem
+//	@Failure		401	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Router			/auth/oidc/callback [get]
+func (i *IpManager) OidcCallback(c *gin.Context) {
+	if i.Oidc == nil {
+		c.Error(&model.NotFound{Err: errors.New("OIDC login is not configured")})
+		return
+	}
+
+	session := sessions.Default(c)
+	state := session.Get(oidcStateKey)
+	nonce := session.Get(oidcNonceKey)
+	verifier := session.Get(oidcVerifierKey)
+	// a login attempt can only be finished once
+	session.Delete(oidcStateKey)
+	session.Delete(oidcNonceKey)
+	session.Delete(oidcVerifierKey)
+	session.Save()
+
+	if providerError := c.Query("error"); providerError != "" {
+		log.Println("ERROR: OIDC provider refused login: " + providerError + " " + c.Query("error_description"))
+		c.Error(&model.NotAuthenticated{Err: errors.New("login refused by identity provider: " + providerError)})
+		return
+	}
+	if state == nil || nonce == nil || verifier == nil || c.Query("state") != fmt.Sprintf("%v", state) {
+		log.Println("ERROR: OIDC callback state does not match the session")
+		c.Error(errors.New("invalid or expired login state")).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	username, err := i.Oidc.Exchange(c.Request.Context(), c.Query("code"), fmt.Sprintf("%v", nonce), fmt.Sprintf("%v", verifier))
+	if err != nil {
+		log.Println("ERROR: OIDC login failed: " + string(err.Error()))
+		c.Error(&model.NotAuthenticated{Err: errors.New("not authorized!")})
+		return
+	}
+	if username == "" {
+		c.Error(&model.NotAuthenticated{Err: errors.New("not authorized!")})
+		return
+	}
+
+	if err := helpers.RenewSession(session); err != nil {
+		c.Error(errors.New("failed to renew user session"))
+		return
+	}
+	session.Set(globals.UserKey, username)
+	if err := session.Save(); err != nil {
+		c.Error(errors.New("failed to save user session"))
+		return
+	}
+	log.Println("INFO: User '" + username + "' logged in by OIDC")
+
+	if i.Oidc.PostLoginRedirect() != "" {
+		c.Redirect(http.StatusFound, i.Oidc.PostLoginRedirect())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "User '" + username + "' has logged in"})
+}
@@ -19,14 +19,16 @@ package controllers
 */
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/greeneg/ipmanager/helpers"
+	"github.com/greeneg/ipmanager/generators"
+	"github.com/greeneg/ipmanager/globals"
 	"github.com/greeneg/ipmanager/model"
 )
 
@@ -39,22 +41,23 @@ import (
 //	@Produce		json
 //	@Param			domain	body	model.Domain	true	"Domain data"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
 //	@Router			/domain [post]
 func (i *IpManager) CreateDomain(c *gin.Context) {
 	var json model.Domain
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	// need to get our current user context to get the CreatorId
-	session := sessions.Default(c)
-	user := session.Get("user")
-	// if nil, we have an issue
-	if user == nil {
-		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "Insufficient access. Access denied!"})
+	// need to get our current user context to get the CreatorId. AuthCheck stores it however
+	// the request was authenticated
+	user, exists := c.Get(globals.UserKey)
+	// if missing, we have an issue
+	if !exists {
+		c.Error(&model.AccessDenied{Err: errors.New("Insufficient access. Access denied!")})
 		return
 	}
 
@@ -63,20 +66,20 @@ func (i *IpManager) CreateDomain(c *gin.Context) {
 	// lets output our session user
 	log.Println("INFO: Session user: " + username)
 	// get our user id
-	userObject, err := model.GetUserByUserName(username)
+	userObject, err := model.Repo.GetUserByUserName(username)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err})
+		c.Error(err)
 		return
 	}
 
 	// what is our user Id
 	log.Println("INFO: Session user's ID: " + strconv.Itoa(userObject.Id))
 
-	s, err := model.CreateDomain(json, userObject.Id)
+	s, err := model.Repo.CreateDomain(json, userObject.Id)
 	if s {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "Domain has been added to system"})
 	} else {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 	}
 }
 
@@ -88,43 +91,56 @@ func (i *IpManager) CreateDomain(c *gin.Context) {
 //	@Produce		json
 //	@Param			domainname	path	string	true	"Domain name"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/domain/{domainname} [delete]
 func (i *IpManager) DeleteDomain(c *gin.Context) {
 	domain := c.Param("domainname")
-	status, err := model.DeleteDomain(domain)
+	status, err := model.Repo.DeleteDomain(domain)
 	if err != nil {
 		log.Println("ERROR: Cannot delete domain: " + string(err.Error()))
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove domain! " + string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "domain " + domain + " has been removed from system"})
 	} else {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove domain!"})
+		c.Error(errors.New("Unable to remove domain!"))
 	}
 }
 
 // GetDomains Retrieve a list of domain
 //
 //	@Summary		Retrieve a list of domain
-//	@Description	Retrieve a list of domain
+//	@Description	Retrieve a page of the domains. Any other field of a domain can be filtered on by passing it as a parameter, and sorted on
 //	@Tags			domain
 //	@Produce		json
+//	@Param			limit			query	int		false	"Maximum number of domains to return, at most 1000"	default(100)
+//	@Param			offset			query	int		false	"Number of domains to skip"
+//	@Param			sort			query	string	false	"Comma separated fields to sort on, each prefixed with - for descending order"
+//	@Param			creatorId		query	int		false	"Only domains created by this user Id"
+//	@Param			createdAfter	query	string	false	"Only domains created at or after this time, as an RFC 3339 timestamp or a date"
+//	@Param			createdBefore	query	string	false	"Only domains created before this time, as an RFC 3339 timestamp or a date"
 //	@Success		200	{object}	model.DomainList
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/domains [get]
 func (i *IpManager) GetDomains(c *gin.Context) {
-	domains, err := model.GetDomains()
-	helpers.CheckError(err)
+	o, err := listOptions(c)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
 
-	if domains == nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no records found!"})
-	} else {
-		c.IndentedJSON(http.StatusOK, gin.H{"data": domains})
+	domains, total, err := model.Repo.GetDomains(o)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+
+	writePage(c, domains, total, o)
 }
 
 // GetDomainById Retrieve a domain by Id
@@ -135,16 +151,19 @@ func (i *IpManager) GetDomains(c *gin.Context) {
 //	@Produce		json
 //	@Param			domainid	path	string	true	"Domain Id"
 //	@Success		200	{object}	model.Domain
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/domain/id/{domainid} [get]
 func (i *IpManager) GetDomainById(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("domainid"))
-	ent, err := model.GetDomainById(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetDomainById(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.DomainName == "" {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with domain id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
@@ -158,16 +177,62 @@ func (i *IpManager) GetDomainById(c *gin.Context) {
 //	@Produce		json
 //	@Param			domainname	path	string	true	"Domain name"
 //	@Success		200	{object}	model.Domain
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/domain/name/{domainname} [get]
 func (i *IpManager) GetDomainByDomainName(c *gin.Context) {
 	domain := c.Param("domainname")
-	ent, err := model.GetDomainByDomainName(domain)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetDomainByDomainName(domain)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.DomainName == "" {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with domain name " + domain})
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain name " + domain)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
 }
+
+// GetDomainZone Render a BIND zone file for a domain
+//
+//	@Summary		Render a BIND zone file for a domain
+//	@Description	Render a BIND zone file with SOA, NS and an A or AAAA record for every address assigned in the domain
+//	@Tags			domain
+//	@Produce		plain
+//	@Param			domainname	path	string	true	"Domain name"
+//	@Success		200	{string}	string
+//	@Failure		404	{object}	model.Problem
+//	@Failure		500	{object}	model.Problem
+//	@Router			/domain/name/{domainname}/zone [get]
+func (i *IpManager) GetDomainZone(c *gin.Context) {
+	domain := c.Param("domainname")
+	ent, err := model.Repo.GetDomainByDomainName(domain)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if ent.DomainName == "" {
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain name " + domain)})
+		return
+	}
+
+	inv, err := generators.LoadInventory()
+	if err != nil {
+		log.Println("ERROR: Cannot load inventory: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	// a timestamp serial always moves forward between generations
+	serial := uint32(time.Now().Unix())
+	zone, err := generators.RenderForwardZone(inv, ent, i.ConfStruct.Dns, serial)
+	if err != nil {
+		log.Println("ERROR: Cannot render zone for domain " + domain + ": " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.String(http.StatusOK, zone)
+}
@@ -0,0 +1,152 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/greeneg/ipmanager/generators"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// ExportDhcpd Render an ISC dhcpd configuration
+//
+//	@Summary		Render an ISC dhcpd configuration
+//	@Description	Render subnet blocks with fixed-address host declarations for every IPv4 subnet
+//	@Tags			export
+//	@Produce		plain
+//	@Success		200	{string}	string
+//	@Failure		500	{object}	model.Problem
+//	@Router			/export/dhcpd [get]
+func (i *IpManager) ExportDhcpd(c *gin.Context) {
+	inv, err := generators.LoadInventory()
+	if err != nil {
+		log.Println("ERROR: Cannot load inventory: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	conf, err := generators.RenderDhcpd(inv)
+	if err != nil {
+		log.Println("ERROR: Cannot render dhcpd configuration: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.String(http.StatusOK, conf)
+}
+
+// ExportKeaDhcp4 Render a Kea DHCPv4 configuration
+//
+//	@Summary		Render a Kea DHCPv4 configuration
+//	@Description	Render the Dhcp4 subnet4 list, with host reservations, for every IPv4 subnet
+//	@Tags			export
+//	@Produce		json
+//	@Success		200	{object}	generators.KeaDhcp4Config
+//	@Failure		500	{object}	model.Problem
+//	@Router			/export/kea/dhcp4 [get]
+func (i *IpManager) ExportKeaDhcp4(c *gin.Context) {
+	inv, err := generators.LoadInventory()
+	if err != nil {
+		log.Println("ERROR: Cannot load inventory: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	conf, err := generators.RenderKeaDhcp4(inv)
+	if err != nil {
+		log.Println("ERROR: Cannot render Kea DHCPv4 configuration: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, conf)
+}
+
+// ExportKeaDhcp6 Render a Kea DHCPv6 configuration
+//
+//	@Summary		Render a Kea DHCPv6 configuration
+//	@Description	Render the Dhcp6 subnet6 list, with host reservations, for every IPv6 subnet
+//	@Tags			export
+//	@Produce		json
+//	@Success		200	{object}	generators.KeaDhcp6Config
+//	@Failure		500	{object}	model.Problem
+//	@Router			/export/kea/dhcp6 [get]
+func (i *IpManager) ExportKeaDhcp6(c *gin.Context) {
+	inv, err := generators.LoadInventory()
+	if err != nil {
+		log.Println("ERROR: Cannot load inventory: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	conf, err := generators.RenderKeaDhcp6(inv)
+	if err != nil {
+		log.Println("ERROR: Cannot render Kea DHCPv6 configuration: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, conf)
+}
+
+// ExportDnsmasq Render a dnsmasq configuration for a domain
+//
+//	@Summary		Render a dnsmasq configuration for a domain
+//	@Description	Render dhcp-range, dhcp-host and host-record lines for the domain's subnets and hosts
+//	@Tags			export
+//	@Produce		plain
+//	@Param			domainname	path	string	true	"Domain name"
+//	@Success		200	{string}	string
+//	@Failure		404	{object}	model.Problem
+//	@Failure		500	{object}	model.Problem
+//	@Router			/export/dnsmasq/domain/{domainname} [get]
+func (i *IpManager) ExportDnsmasq(c *gin.Context) {
+	domain := c.Param("domainname")
+	ent, err := model.Repo.GetDomainByDomainName(domain)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if ent.DomainName == "" {
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain name " + domain)})
+		return
+	}
+
+	inv, err := generators.LoadInventory()
+	if err != nil {
+		log.Println("ERROR: Cannot load inventory: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	conf, err := generators.RenderDnsmasq(inv, ent)
+	if err != nil {
+		log.Println("ERROR: Cannot render dnsmasq configuration for domain " + domain + ": " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.String(http.StatusOK, conf)
+}
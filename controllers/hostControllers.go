@@ -19,15 +19,15 @@ package controllers
 */
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/globals"
 
-	"github.com/greeneg/ipmanager/helpers"
 	"github.com/greeneg/ipmanager/model"
 )
 
@@ -40,22 +40,24 @@ import (
 //	@Produce		json
 //	@Param			host	body	model.Host	true	"Host Data"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/host [post]
 func (i *IpManager) CreateHost(c *gin.Context) {
 	var json model.Host
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	// need to get our current user context to get the CreatorId
-	session := sessions.Default(c)
-	user := session.Get("user")
-	// if nil, we have an issue
-	if user == nil {
-		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "Insufficient access. Access denied!"})
+	// need to get our current user context to get the CreatorId. AuthCheck stores it however
+	// the request was authenticated
+	user, exists := c.Get(globals.UserKey)
+	// if missing, we have an issue
+	if !exists {
+		c.Error(&model.AccessDenied{Err: errors.New("Insufficient access. Access denied!")})
 		return
 	}
 
@@ -64,20 +66,20 @@ func (i *IpManager) CreateHost(c *gin.Context) {
 	// lets output our session user
 	log.Println("INFO: Session user: " + username)
 	// get our user id
-	userObject, err := model.GetUserByUserName(username)
+	userObject, err := model.Repo.GetUserByUserName(username)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err})
+		c.Error(err)
 		return
 	}
 
 	// what is our user Id
 	log.Println("INFO: Session user's ID: " + strconv.Itoa(userObject.Id))
 
-	s, err := model.CreateHost(json, userObject.Id)
+	s, err := model.Repo.CreateHost(json, userObject.Id)
 	if s {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "Host has been added to system"})
 	} else {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 	}
 }
 
@@ -90,22 +92,23 @@ func (i *IpManager) CreateHost(c *gin.Context) {
 //	@Produce		json
 //	@Param			hostname	path	string	true	"Hostname"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/host/{hostname} [delete]
 func (i *IpManager) DeleteHostname(c *gin.Context) {
 	hostname := c.Param("hostname")
-	status, err := model.DeleteDomain(hostname)
+	status, err := model.Repo.DeleteHostname(hostname)
 	if err != nil {
 		log.Println("ERROR: Cannot delete host: " + string(err.Error()))
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove host! " + string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "host " + hostname + " has been removed from system"})
 	} else {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove host!"})
+		c.Error(errors.New("Unable to remove host!"))
 	}
 }
 
@@ -119,49 +122,63 @@ func (i *IpManager) DeleteHostname(c *gin.Context) {
 //	@Param			hostname	path	string	true	"Hostname"
 //	@Param			updateMacAddresses	body	model.MacAddressList	true	"MAC address list"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/host/{hostname} [patch]
 func (i *IpManager) UpdateMacAddresses(c *gin.Context) {
 	hostname := c.Param("hostname")
 	var json model.MacAddressList
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	status, err := model.UpdateMacAddresses(hostname, json.Data)
+	status, err := model.Repo.UpdateMacAddresses(hostname, json.Data)
 	if err != nil {
 		log.Println("ERROR: Cannot update host's MAC address list: " + string(err.Error()))
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to update host's MAC address list: " + string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "host " + hostname + "'s MAC address list has been modified"})
 	} else {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to update host's MAC address list"})
+		c.Error(errors.New("Unable to update host's MAC address list"))
 	}
 }
 
 // GetHosts Retrieve list of all hosts
 //
 //	@Summary		Retrieve list of all hosts
-//	@Description	Retrieve list of all hosts
+//	@Description	Retrieve a page of the hosts. Any other field of a host can be filtered on by passing it as a parameter, and sorted on, except MacAddresses
 //	@Tags			host
 //	@Produce		json
+//	@Param			limit			query	int		false	"Maximum number of hosts to return, at most 1000"	default(100)
+//	@Param			offset			query	int		false	"Number of hosts to skip"
+//	@Param			sort			query	string	false	"Comma separated fields to sort on, each prefixed with - for descending order"
+//	@Param			creatorId		query	int		false	"Only hosts created by this user Id"
+//	@Param			createdAfter	query	string	false	"Only hosts created at or after this time, as an RFC 3339 timestamp or a date"
+//	@Param			createdBefore	query	string	false	"Only hosts created before this time, as an RFC 3339 timestamp or a date"
 //	@Success		200	{object}	model.HostList
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/hosts [get]
 func (i *IpManager) GetHosts(c *gin.Context) {
-	hosts, err := model.GetHosts()
-	helpers.CheckError(err)
+	o, err := listOptions(c)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
 
-	if hosts == nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no records found!"})
-	} else {
-		c.IndentedJSON(http.StatusOK, gin.H{"data": hosts})
+	hosts, total, err := model.Repo.GetHosts(o)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+
+	writePage(c, hosts, total, o)
 }
 
 // GetHostByHostName Retrieve a host by its hostname
@@ -172,15 +189,18 @@ func (i *IpManager) GetHosts(c *gin.Context) {
 //	@Produce		json
 //	@Param			hostname	path	string	true	"hostname"
 //	@Success		200	{object}	model.Host
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/host/name/{hostname} [get]
 func (i *IpManager) GetHostByHostName(c *gin.Context) {
 	host := c.Param("hostname")
-	ent, err := model.GetHostByHostName(host)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetHostByHostName(host)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.HostName == "" {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with host name " + host})
+		c.Error(&model.NotFound{Err: errors.New("no records found with host name " + host)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
@@ -194,16 +214,19 @@ func (i *IpManager) GetHostByHostName(c *gin.Context) {
 //	@Produce		json
 //	@Param			hostid	path	string	true	"host Id"
 //	@Success		200	{object}	model.Host
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/host/id/{hostid} [get]
 func (i *IpManager) GetHostById(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("hostid"))
-	ent, err := model.GetHostById(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetHostById(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.HostName == "" {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with host id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found with host id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
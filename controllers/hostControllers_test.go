@@ -0,0 +1,69 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/greeneg/ipmanager/model"
+)
+
+// hostOnlyRepository only lets hosts be deleted. Any other call panics on the nil Repository
+// it embeds, and deleting a domain fails the test outright
+type hostOnlyRepository struct {
+	model.Repository
+	t       *testing.T
+	deleted []string
+}
+
+func (r *hostOnlyRepository) DeleteHostname(hostname string) (bool, error) {
+	r.deleted = append(r.deleted, hostname)
+	return true, nil
+}
+
+func (r *hostOnlyRepository) DeleteDomain(domain string) (bool, error) {
+	r.t.Errorf("deleting host removed domain %q", domain)
+	return true, nil
+}
+
+func TestDeleteHostnameLeavesDomains(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &hostOnlyRepository{t: t}
+	saved := model.Repo
+	model.Repo = repo
+	t.Cleanup(func() { model.Repo = saved })
+
+	i := &IpManager{}
+	router := gin.New()
+	router.DELETE("/host/:hostname", i.DeleteHostname)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/host/example.com", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if len(repo.deleted) != 1 || repo.deleted[0] != "example.com" {
+		t.Errorf("got hosts deleted %v, want [example.com]", repo.deleted)
+	}
+}
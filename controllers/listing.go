@@ -0,0 +1,98 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/model"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listOptions reads the page, sort order and filters of a list request. Every query
+// parameter other than limit, offset and sort is taken as a filter, for the model to check
+func listOptions(c *gin.Context) (model.ListOptions, error) {
+	o := model.ListOptions{Filters: make(map[string]string)}
+
+	var err error
+	o.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultListLimit)))
+	if err != nil || o.Limit < 1 || o.Limit > maxListLimit {
+		return o, errors.New("invalid value for limit: " + c.Query("limit"))
+	}
+	o.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || o.Offset < 0 {
+		return o, errors.New("invalid value for offset: " + c.Query("offset"))
+	}
+	if sort := c.Query("sort"); sort != "" {
+		o.Sort = strings.Split(sort, ",")
+	}
+
+	for field, values := range c.Request.URL.Query() {
+		switch field {
+		case "limit", "offset", "sort":
+			continue
+		}
+		o.Filters[field] = values[0]
+	}
+
+	return o, nil
+}
+
+// pageLink is the URL of another page of the current list, keeping its sort and filters
+func pageLink(c *gin.Context, o model.ListOptions, offset int, rel string) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set("limit", strconv.Itoa(o.Limit))
+	query.Set("offset", strconv.Itoa(offset))
+	u.RawQuery = query.Encode()
+	return "<" + u.RequestURI() + ">; rel=\"" + rel + "\""
+}
+
+// writePage answers a list request with a page of results, how many there are over every
+// page, and Link headers to the first, previous, next and last pages
+func writePage(c *gin.Context, data any, total int, o model.ListOptions) {
+	last := 0
+	if total > 0 {
+		last = (total - 1) / o.Limit * o.Limit
+	}
+
+	links := []string{pageLink(c, o, 0, "first")}
+	if o.Offset > 0 {
+		prev := o.Offset - o.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, pageLink(c, o, prev, "prev"))
+	}
+	if o.Offset+o.Limit < total {
+		links = append(links, pageLink(c, o, o.Offset+o.Limit, "next"))
+	}
+	links = append(links, pageLink(c, o, last, "last"))
+	c.Header("Link", strings.Join(links, ", "))
+
+	c.IndentedJSON(http.StatusOK, gin.H{"data": data, "total": total, "limit": o.Limit, "offset": o.Offset})
+}
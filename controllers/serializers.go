@@ -0,0 +1,93 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// Users are never handed to the client as model.User. Every controller goes through the
+// serializers here, which copy across only the fields the caller is allowed to see.
+
+// PublicUser is what any authenticated user can see of another account
+type PublicUser struct {
+	Id           int
+	UserName     string
+	CreationDate string
+}
+
+// SafeUser is the full account detail, shown to admins and to users looking at themselves
+type SafeUser struct {
+	Id              int
+	UserName        string
+	Status          string
+	Role            string
+	CreationDate    string
+	LastChangedDate string
+}
+
+type PublicUserList struct {
+	Data []PublicUser `json:"data"`
+}
+
+type SafeUserList struct {
+	Data []SafeUser `json:"data"`
+	model.Page
+}
+
+func toPublicUser(u model.User) PublicUser {
+	return PublicUser{
+		Id:           u.Id,
+		UserName:     u.UserName,
+		CreationDate: u.CreationDate,
+	}
+}
+
+func toSafeUser(u model.User) SafeUser {
+	return SafeUser{
+		Id:              u.Id,
+		UserName:        u.UserName,
+		Status:          u.Status,
+		Role:            u.Role,
+		CreationDate:    u.CreationDate,
+		LastChangedDate: u.LastChangedDate,
+	}
+}
+
+func canSeeUserDetail(c *gin.Context, u model.User) bool {
+	return c.GetString(globals.RoleKey) == model.RoleAdmin || c.GetString(globals.UserKey) == u.UserName
+}
+
+// serializeUser returns the representation of a user the current caller may see
+func serializeUser(c *gin.Context, u model.User) any {
+	if canSeeUserDetail(c, u) {
+		return toSafeUser(u)
+	}
+	return toPublicUser(u)
+}
+
+func serializeUsers(c *gin.Context, users []model.User) []any {
+	serialized := make([]any, 0, len(users))
+	for _, user := range users {
+		serialized = append(serialized, serializeUser(c, user))
+	}
+	return serialized
+}
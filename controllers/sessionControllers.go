@@ -0,0 +1,174 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/helpers"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// Logout End the current session
+//
+//	@Summary		End the current session
+//	@Description	End the current session and clear its cookie
+//	@Tags			auth
+//	@Produce		json
+//	@Security		BasicAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		500	{object}	model.Problem
+//	@Router			/logout [post]
+func (i *IpManager) Logout(c *gin.Context) {
+	session := sessions.Default(c)
+	session.Clear()
+	session.Options(sessions.Options{Path: "/", MaxAge: -1})
+	if err := session.Save(); err != nil {
+		log.Println("ERROR: Cannot end session: " + string(err.Error()))
+		c.Error(errors.New("failed to end user session"))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// serverSideSessions records an error for the request when sessions only live in cookies, where
+// the server can't see or revoke them
+func (i *IpManager) serverSideSessions(c *gin.Context) bool {
+	if !helpers.ServerSideSessions(i.ConfStruct.Session) {
+		c.Error(errors.New("session management needs the database session store")).SetMeta(http.StatusNotImplemented)
+		return false
+	}
+	return true
+}
+
+// GetSessions Retrieve the active sessions of a user
+//
+//	@Summary		Retrieve the active sessions of a user
+//	@Description	Retrieve the active sessions of a user. Needs the database session store
+//	@Tags			user
+//	@Produce		json
+//	@Param			name	path	string	true	"User name"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SessionList
+//	@Failure		403	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		501	{object}	model.Problem
+//	@Router			/user/{name}/sessions [get]
+func (i *IpManager) GetSessions(c *gin.Context) {
+	if !i.serverSideSessions(c) {
+		return
+	}
+	user, ok := manageableUser(c)
+	if !ok {
+		return
+	}
+
+	userSessions, err := model.Repo.GetSessionsByUserName(user.UserName)
+	if err != nil {
+		log.Println("ERROR: Cannot get sessions for user '" + user.UserName + "': " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"data": userSessions})
+}
+
+// DeleteSessions Revoke all sessions of a user
+//
+//	@Summary		Revoke all sessions of a user
+//	@Description	Revoke all sessions of a user. Needs the database session store
+//	@Tags			user
+//	@Produce		json
+//	@Param			name	path	string	true	"User name"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		403	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		501	{object}	model.Problem
+//	@Router			/user/{name}/sessions [delete]
+func (i *IpManager) DeleteSessions(c *gin.Context) {
+	if !i.serverSideSessions(c) {
+		return
+	}
+	user, ok := manageableUser(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := model.Repo.DeleteSessions(user.UserName, 0)
+	if err != nil {
+		log.Println("ERROR: Cannot revoke sessions: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": strconv.FormatInt(revoked, 10) + " sessions of user '" + user.UserName + "' have been revoked"})
+}
+
+// DeleteSession Revoke a session of a user
+//
+//	@Summary		Revoke a session of a user
+//	@Description	Revoke a session of a user. Needs the database session store
+//	@Tags			user
+//	@Produce		json
+//	@Param			name		path	string	true	"User name"
+//	@Param			sessionid	path	int		true	"Session ID"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		403	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		501	{object}	model.Problem
+//	@Router			/user/{name}/sessions/{sessionid} [delete]
+func (i *IpManager) DeleteSession(c *gin.Context) {
+	if !i.serverSideSessions(c) {
+		return
+	}
+	user, ok := manageableUser(c)
+	if !ok {
+		return
+	}
+
+	sessionId, err := strconv.Atoi(c.Param("sessionid"))
+	if err != nil || sessionId <= 0 {
+		c.Error(errors.New("invalid session id " + c.Param("sessionid"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	revoked, err := model.Repo.DeleteSessions(user.UserName, sessionId)
+	if err != nil {
+		log.Println("ERROR: Cannot revoke session: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	if revoked == 0 {
+		c.Error(&model.NotFound{Err: errors.New("no records found with session id " + strconv.Itoa(sessionId))})
+	} else {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Session " + strconv.Itoa(sessionId) + " has been revoked"})
+	}
+}
@@ -19,42 +19,47 @@ package controllers
 */
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/greeneg/ipmanager/helpers"
+	"github.com/greeneg/ipmanager/generators"
+	"github.com/greeneg/ipmanager/globals"
 	"github.com/greeneg/ipmanager/model"
 )
 
 // CreateSubnet Register a subnet with the system
 //
 //	@Summary		Register subnet
-//	@Description	Add a new subnet
+//	@Description	Add a new subnet. The prefix has to be the network address of its block and the gateway a host address inside it. A subnet can't overlap another unless it's nested inside a container block by setting ParentId to the most specific one holding it. A container has Container set and no gateway, and holds subnets rather than addresses. An IPv4 subnet that isn't a container can be no larger than a /16
 //	@Tags			subnet
 //	@Accept			json
 //	@Produce		json
 //	@Param			subnet	body	model.Subnet	true	"Subnet Data"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		409	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/subnet [post]
 func (i *IpManager) CreateSubnet(c *gin.Context) {
 	var json model.Subnet
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	// need to get our current user context to get the CreatorId
-	session := sessions.Default(c)
-	user := session.Get("user")
-	// if nil, we have an issue
-	if user == nil {
-		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "Insufficient access. Access denied!"})
+	// need to get our current user context to get the CreatorId. AuthCheck stores it however
+	// the request was authenticated
+	user, exists := c.Get(globals.UserKey)
+	// if missing, we have an issue
+	if !exists {
+		c.Error(&model.AccessDenied{Err: errors.New("Insufficient access. Access denied!")})
 		return
 	}
 
@@ -63,23 +68,81 @@ func (i *IpManager) CreateSubnet(c *gin.Context) {
 	// lets output our session user
 	log.Println("INFO: Session user: " + username)
 	// get our user id
-	userObject, err := model.GetUserByUserName(username)
+	userObject, err := model.Repo.GetUserByUserName(username)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err})
+		c.Error(err)
 		return
 	}
 
 	// what is our user Id
 	log.Println("INFO: Session user's ID: " + strconv.Itoa(userObject.Id))
 
-	s, err := model.CreateSubnet(json, userObject.Id)
+	s, err := model.Repo.CreateSubnet(json, userObject.Id)
 	if s {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "Subnet '" + json.NetworkName + "' has been added to system"})
 	} else {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 	}
 }
 
+// AllocateAddress Assign the next free address in a subnet to a host
+//
+//	@Summary		Allocate the next free address in a subnet
+//	@Description	Pick the lowest unassigned address in a subnet and assign it to a host
+//	@Tags			subnet
+//	@Accept			json
+//	@Produce		json
+//	@Param			networkname	path	string	true	"Network name"
+//	@Param			allocation	body	model.AddressAllocation	true	"Allocation data"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.Address
+//	@Failure		400	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		409	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
+//	@Router			/subnet/{networkname}/allocate [post]
+func (i *IpManager) AllocateAddress(c *gin.Context) {
+	subnetName := c.Param("networkname")
+	var json model.AddressAllocation
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	// need to get our current user context to get the CreatorId. AuthCheck stores it however
+	// the request was authenticated
+	user, exists := c.Get(globals.UserKey)
+	// if missing, we have an issue
+	if !exists {
+		c.Error(&model.AccessDenied{Err: errors.New("Insufficient access. Access denied!")})
+		return
+	}
+
+	// convert user interface to a string
+	username := fmt.Sprintf("%v", user)
+	// lets output our session user
+	log.Println("INFO: Session user: " + username)
+	// get our user id
+	userObject, err := model.Repo.GetUserByUserName(username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// what is our user Id
+	log.Println("INFO: Session user's ID: " + strconv.Itoa(userObject.Id))
+
+	addr, err := model.Repo.AllocateAddress(subnetName, json, userObject.Id)
+	if err != nil {
+		log.Println("ERROR: Cannot allocate address in subnet '" + subnetName + "': " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, addr)
+}
+
 // DeleteSubnet Remove a subnet
 //
 //	@Summary		Delete subnet
@@ -89,22 +152,25 @@ func (i *IpManager) CreateSubnet(c *gin.Context) {
 //	@Produce		json
 //	@Param			networkname	path	string	true	"Network name"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		409	{object}	model.Problem
 //	@Router			/subnet/{networkname} [delete]
 func (i *IpManager) DeleteSubnet(c *gin.Context) {
 	subnetName := c.Param("networkname")
-	status, err := model.DeleteSubnet(subnetName)
+	status, err := model.Repo.DeleteSubnet(subnetName)
 	if err != nil {
 		log.Println("ERROR: Cannot delete subnet: " + string(err.Error()))
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove subnet! " + string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "Subnet '" + subnetName + "' has been removed from system"})
 	} else {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove user!"})
+		c.Error(errors.New("Unable to remove subnet!"))
 	}
 
 }
@@ -112,56 +178,72 @@ func (i *IpManager) DeleteSubnet(c *gin.Context) {
 // ModifySubnet Change a subnet's network information
 //
 //	@Summary		Change subnet network information
-//	@Description	Change subnet network information
+//	@Description	Change subnet network information. Only the fields given are changed, and the result is checked as it is when a subnet is created. A ParentId of 0 takes the subnet out of the one it was nested in, and turning a subnet into a container takes an empty GatewayAddress. The subnets nested under a container have to stay inside its new block, and it stays a container while it holds any. The block can only change, or the subnet become or stop being a container, while none of its addresses are assigned
 //	@Tags			subnet
 //	@Accept			json
 //	@Produce		json
 //	@Param			networkname	path	string	true	"Network name"
-//	@Param			subnetUpdate	body	model.SubnetUpdate	true	"Subnet data"
+//	@Param			subnetUpdate	body	model.SubnetUpdate	true	"Fields to change"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		409	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/subnet/{networkname} [patch]
 func (i *IpManager) ModifySubnet(c *gin.Context) {
 	subnetName := c.Param("networkname")
 	var json model.SubnetUpdate
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	status, err := model.ModifySubnet(subnetName, json)
+	status, err := model.Repo.ModifySubnet(subnetName, json)
 	if err != nil {
 		log.Println("ERROR: Cannot modify subnet '" + subnetName + "'! " + string(err.Error()))
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to modify subnet '" + subnetName + "'! " + string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{})
 	} else {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{})
+		c.Error(errors.New("Unable to modify subnet!"))
 	}
 }
 
 // GetSubnets Retrieve list of all subnets
 //
 //	@Summary		Retrieve list of all subnets
-//	@Description	Retrieve list of all subnets
+//	@Description	Retrieve a page of the subnets. Any other field of a subnet can be filtered on by passing it as a parameter, and sorted on
 //	@Tags			subnet
 //	@Produce		json
-//	@Success		200	{object}	model.Subnets
-//	@Failure		400	{object}	model.FailureMsg
+//	@Param			limit			query	int		false	"Maximum number of subnets to return, at most 1000"	default(100)
+//	@Param			offset			query	int		false	"Number of subnets to skip"
+//	@Param			sort			query	string	false	"Comma separated fields to sort on, each prefixed with - for descending order"
+//	@Param			creatorId		query	int		false	"Only subnets created by this user Id"
+//	@Param			createdAfter	query	string	false	"Only subnets created at or after this time, as an RFC 3339 timestamp or a date"
+//	@Param			createdBefore	query	string	false	"Only subnets created before this time, as an RFC 3339 timestamp or a date"
+//	@Success		200	{object}	model.SubnetList
+//	@Failure		400	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/subnets [get]
 func (i *IpManager) GetSubnets(c *gin.Context) {
-	snets, err := model.GetSubnets()
-	helpers.CheckError(err)
+	o, err := listOptions(c)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
 
-	if snets == nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no records found!"})
-	} else {
-		c.IndentedJSON(http.StatusOK, gin.H{"data": snets})
+	snets, total, err := model.Repo.GetSubnets(o)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+
+	writePage(c, snets, total, o)
 }
 
 // GetSubnetById Retrieve a subnet by its Id
@@ -172,16 +254,19 @@ func (i *IpManager) GetSubnets(c *gin.Context) {
 //	@Produce		json
 //	@Param			subnetid	path	string	true	"Subnet Id"
 //	@Success		200	{object}	model.Subnet
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/subnet/id/{subnetname} [get]
 func (i *IpManager) GetSubnetById(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("subnetid"))
-	ent, err := model.GetSubnetById(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetSubnetById(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.NetworkName == "" {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with subnet id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found with subnet id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
@@ -195,20 +280,65 @@ func (i *IpManager) GetSubnetById(c *gin.Context) {
 //	@Produce		json
 //	@Param			subnetname	path	string	true	"Subnet name"
 //	@Success		200	{object}	model.Subnet
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/subnet/name/{subnetname} [get]
 func (i *IpManager) GetSubnetByNetworkName(c *gin.Context) {
 	netname := c.Param("subnetname")
-	ent, err := model.GetSubnetByNetworkName(netname)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetSubnetByNetworkName(netname)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.NetworkName == "" {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with subnet name " + netname})
+		c.Error(&model.NotFound{Err: errors.New("no records found with subnet name " + netname)})
 	} else {
 		c.IndentedJSON(http.StatusOK, ent)
 	}
 }
 
+// GetSubnetReverseZones Render the reverse DNS zones for a subnet
+//
+//	@Summary		Render the reverse DNS zones for a subnet
+//	@Description	Render in-addr.arpa or ip6.arpa zones with PTR records for every address assigned in the subnet. Prefixes that aren't on a label boundary are split into several zones, except IPv4 prefixes longer than /24, which get an RFC 2317 classless zone and the delegation records for its parent zone
+//	@Tags			subnet
+//	@Produce		json
+//	@Param			subnetname	path	string	true	"Subnet name"
+//	@Success		200	{object}	generators.ReverseZoneList
+//	@Failure		404	{object}	model.Problem
+//	@Failure		500	{object}	model.Problem
+//	@Router			/subnet/name/{subnetname}/reversezones [get]
+func (i *IpManager) GetSubnetReverseZones(c *gin.Context) {
+	netname := c.Param("subnetname")
+	ent, err := model.Repo.GetSubnetByNetworkName(netname)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if ent.NetworkName == "" {
+		c.Error(&model.NotFound{Err: errors.New("no records found with subnet name " + netname)})
+		return
+	}
+
+	inv, err := generators.LoadInventory()
+	if err != nil {
+		log.Println("ERROR: Cannot load inventory: " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	// a timestamp serial always moves forward between generations
+	serial := uint32(time.Now().Unix())
+	zones, err := generators.RenderReverseZones(inv, ent, i.ConfStruct.Dns, serial)
+	if err != nil {
+		log.Println("ERROR: Cannot render reverse zones for subnet " + netname + ": " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"data": zones})
+}
+
 // GetSubnetsByDomainId Retrieve the list of subnets assigned to a domain Id
 //
 //	@Summary		Retrieve a list of subnets assigned to a domain Id
@@ -217,16 +347,19 @@ func (i *IpManager) GetSubnetByNetworkName(c *gin.Context) {
 //	@Produce		json
 //	@Param			domainname	path	string	true	"Domain name"
 //	@Success		200	{object}	model.Subnets
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/subnets/domain/id/{domainid} [get]
 func (i *IpManager) GetSubnetsByDomainId(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("domainid"))
-	ent, err := model.GetSubnestByDomainId(id)
-	helpers.CheckError(err)
+	ent, err := model.Repo.GetSubnestByDomainId(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent == nil {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with domain id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain id " + strId)})
 	} else {
 		c.IndentedJSON(http.StatusOK, gin.H{"data": ent})
 	}
@@ -240,18 +373,80 @@ func (i *IpManager) GetSubnetsByDomainId(c *gin.Context) {
 //	@Produce		json
 //	@Param			domainname	path	string	true	"Domain name"
 //	@Success		200	{object}	model.Subnets
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/subnets/domain/name/{domainname} [get]
 func (i *IpManager) GetSubnetsByDomainName(c *gin.Context) {
 	domainname := c.Param("domainname")
-	ent, err := model.GetSubnestByDomainName(domainname)
+	ent, err := model.Repo.GetSubnestByDomainName(domainname)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to retrieve records matching domain name " + domainname})
+		c.Error(err)
+		return
 	}
 
 	if ent == nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with domain name " + domainname})
+		c.Error(&model.NotFound{Err: errors.New("no records found with domain name " + domainname)})
 	} else {
 		c.IndentedJSON(http.StatusOK, gin.H{"data": ent})
 	}
 }
+
+// GetSubnetContaining Retrieve the most specific subnet holding an address
+//
+//	@Summary		Retrieve the most specific subnet holding an address
+//	@Description	Retrieve the subnet with the longest prefix containing an address, along with the subnet's domain and whether the address is assigned, unassigned, the gateway or reserved. An address inside a container block but none of the subnets in it is unallocated
+//	@Tags			subnet
+//	@Produce		json
+//	@Param			ip	path	string	true	"IPv4 or IPv6 address"
+//	@Success		200	{object}	model.SubnetMatch
+//	@Failure		404	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
+//	@Router			/subnets/containing/{ip} [get]
+func (i *IpManager) GetSubnetContaining(c *gin.Context) {
+	ip := c.Param("ip")
+	match, err := model.Repo.GetSubnetContaining(ip)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, match)
+}
+
+// GetSubnetTrees Retrieve the hierarchy of subnets
+//
+//	@Summary		Retrieve the hierarchy of subnets
+//	@Description	Retrieve every subnet that isn't nested in another, with the subnets nested under each in address order. Each level carries its utilisation, which for a container block is rolled up from everything nested in it
+//	@Tags			subnet
+//	@Produce		json
+//	@Success		200	{object}	model.SubnetTrees
+//	@Router			/subnets/tree [get]
+func (i *IpManager) GetSubnetTrees(c *gin.Context) {
+	trees, err := model.Repo.GetSubnetTrees()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"data": trees})
+}
+
+// GetSubnetTreeByNetworkName Retrieve a subnet with the subnets nested under it
+//
+//	@Summary		Retrieve a subnet with the subnets nested under it
+//	@Description	Retrieve a subnet with the subnets nested under it in address order. Each level carries its utilisation, which for a container block is rolled up from everything nested in it
+//	@Tags			subnet
+//	@Produce		json
+//	@Param			subnetname	path	string	true	"Subnet name"
+//	@Success		200	{object}	model.SubnetTree
+//	@Failure		404	{object}	model.Problem
+//	@Router			/subnet/name/{subnetname}/tree [get]
+func (i *IpManager) GetSubnetTreeByNetworkName(c *gin.Context) {
+	netname := c.Param("subnetname")
+	tree, err := model.Repo.GetSubnetTreeByNetworkName(netname)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, tree)
+}
@@ -0,0 +1,141 @@
+package controllers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// CreateApiToken Create an API token for a user
+//
+//	@Summary		Create an API token
+//	@Description	Create a bearer token for a user with the given scopes and optional RFC 3339 expiry. The token is only shown in this response
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path	string	true	"User name"
+//	@Param			token	body	model.ProposedApiToken	true	"Token data"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.NewApiToken
+//	@Failure		400	{object}	model.Problem
+//	@Failure		403	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Router			/user/{name}/tokens [post]
+func (i *IpManager) CreateApiToken(c *gin.Context) {
+	var json model.ProposedApiToken
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	user, ok := manageableUser(c)
+	if !ok {
+		return
+	}
+
+	token, err := model.Repo.CreateApiToken(json, user.Id)
+	if err != nil {
+		log.Println("ERROR: Cannot create API token for user '" + user.UserName + "': " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, token)
+}
+
+// GetApiTokens Retrieve the API tokens of a user
+//
+//	@Summary		Retrieve the API tokens of a user
+//	@Description	Retrieve the API tokens of a user. The tokens themselves are never returned
+//	@Tags			user
+//	@Produce		json
+//	@Param			name	path	string	true	"User name"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.ApiTokenList
+//	@Failure		403	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Router			/user/{name}/tokens [get]
+func (i *IpManager) GetApiTokens(c *gin.Context) {
+	user, ok := manageableUser(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := model.Repo.GetApiTokensByUserId(user.Id)
+	if err != nil {
+		log.Println("ERROR: Cannot get API tokens for user '" + user.UserName + "': " + string(err.Error()))
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+// DeleteApiToken Revoke an API token
+//
+//	@Summary		Revoke an API token
+//	@Description	Revoke an API token
+//	@Tags			user
+//	@Produce		json
+//	@Param			name	path	string	true	"User name"
+//	@Param			tokenid	path	int	true	"Token ID"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		403	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Router			/user/{name}/tokens/{tokenid} [delete]
+func (i *IpManager) DeleteApiToken(c *gin.Context) {
+	user, ok := manageableUser(c)
+	if !ok {
+		return
+	}
+
+	tokenId, err := strconv.Atoi(c.Param("tokenid"))
+	if err != nil {
+		c.Error(errors.New("invalid token id " + c.Param("tokenid"))).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	status, err := model.Repo.DeleteApiToken(tokenId, user.Id)
+	if err != nil {
+		log.Println("ERROR: Cannot delete API token: " + string(err.Error()))
+		if err == sql.ErrNoRows {
+			c.Error(&model.NotFound{Err: errors.New("no records found with token id " + strconv.Itoa(tokenId))})
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	if status {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "API token " + strconv.Itoa(tokenId) + " has been revoked"})
+	} else {
+		c.Error(errors.New("Unable to revoke API token!"))
+	}
+}
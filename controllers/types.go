@@ -18,16 +18,14 @@ package controllers
 
 */
 
-import "github.com/greeneg/ipmanager/globals"
+import (
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/helpers"
+)
 
 type IpManager struct {
 	AppPath    string
 	ConfigPath string
 	ConfStruct globals.Config
-}
-
-type SafeUser struct {
-	Id           int
-	UserName     string
-	CreationDate string
+	Oidc       *helpers.OidcLogin
 }
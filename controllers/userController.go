@@ -19,15 +19,39 @@ package controllers
 */
 
 import (
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/greeneg/ipmanager/helpers"
+	"github.com/greeneg/ipmanager/globals"
 	"github.com/greeneg/ipmanager/model"
 )
 
+// manageableUser resolves the user named in the path, as long as the current user is that user
+// or an admin. It writes the error response itself when it returns false
+func manageableUser(c *gin.Context) (model.User, bool) {
+	username := c.Param("name")
+	if c.GetString(globals.UserKey) != username && c.GetString(globals.RoleKey) != model.RoleAdmin {
+		c.Error(&model.AccessDenied{Err: errors.New("Insufficient access. Access denied!")})
+		return model.User{}, false
+	}
+
+	user, err := model.Repo.GetUserByUserName(username)
+	if err != nil {
+		c.Error(err)
+		return model.User{}, false
+	}
+	if user.UserName == "" {
+		c.Error(&model.NotFound{Err: errors.New("no records found with user name " + username)})
+		return model.User{}, false
+	}
+
+	return user, true
+}
+
 // CreateUser Register a user for authentication and authorization
 //
 //	@Summary		Register user
@@ -37,55 +61,64 @@ import (
 //	@Produce		json
 //	@Param			user	body	model.ProposedUser	true	"User Data"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
 //	@Router			/user [post]
 func (i *IpManager) CreateUser(c *gin.Context) {
 	var json model.ProposedUser
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	s, err := model.CreateUser(json)
+	s, err := model.Repo.CreateUser(json)
 	if s {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "User has been added to system"})
 	} else {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 	}
 }
 
 // ChangeAccountPassowrd Change an account's password
 //
 //	@Summary		Change password
-//	@Description	Change password
+//	@Description	Change a user's password. Only the user themselves or an admin can change it
 //	@Tags			user
 //	@Accept			json
 //	@Produce		json
 //	@Param			name	path	string	true	"User name"
 //	@Param			changePassword	body	model.PasswordChange	true	"Password data"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		403	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
 //	@Router			/user/{name} [patch]
 func (i *IpManager) ChangeAccountPassword(c *gin.Context) {
-	username := c.Param("name")
+	user, ok := manageableUser(c)
+	if !ok {
+		return
+	}
+	username := user.UserName
+
 	var json model.PasswordChange
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	status, err := model.ChangeAccountPassword(username, json.OldPassword, json.NewPassword)
+	status, err := model.Repo.ChangeAccountPassword(username, json.OldPassword, json.NewPassword)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "User '" + username + "' has changed their password"})
 	} else {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "User password could not be updated!"})
+		c.Error(errors.New("User password could not be updated!"))
 	}
 }
 
@@ -98,22 +131,23 @@ func (i *IpManager) ChangeAccountPassword(c *gin.Context) {
 //	@Produce		json
 //	@Param			name	path	string	true	"User name"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.SuccessMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/user/{name} [delete]
 func (i *IpManager) DeleteUser(c *gin.Context) {
 	username := c.Param("name")
-	status, err := model.DeleteUser(username)
+	status, err := model.Repo.DeleteUser(username)
 	if err != nil {
 		log.Println("ERROR: Cannot delete user: " + string(err.Error()))
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove user! " + string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "User " + username + " has been removed from system"})
 	} else {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to remove user!"})
+		c.Error(errors.New("Unable to remove user!"))
 	}
 }
 
@@ -126,21 +160,22 @@ func (i *IpManager) DeleteUser(c *gin.Context) {
 //	@Produce		json
 //	@Param			name	path	string	true	"User name"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.UserStatusMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/user/{name}/status [get]
 func (i *IpManager) GetUserStatus(c *gin.Context) {
 	username := c.Param("name")
-	status, err := model.GetUserStatus(username)
+	status, err := model.Repo.GetUserStatus(username)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Unable to get the user " + username + " status: " + string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status != "" {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "User status: " + status, "userStatus": status})
 	} else {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Unable to retrieve user status"})
+		c.Error(errors.New("Unable to retrieve user status"))
 	}
 }
 
@@ -151,116 +186,170 @@ func (i *IpManager) GetUserStatus(c *gin.Context) {
 //	@Tags			user
 //	@Accept			json
 //	@Produce		json
-//	@Param			user	body	model.User.UserName	true	"User Data"
+//	@Param			status	body	model.UserStatus	true	"Status data"
 //	@Param			name	path	string	true "User name"
 //	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	model.UserStatusMsg
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		400	{object}	model.Problem
 //	@Router			/user/{name}/status [patch]
 func (i *IpManager) SetUserStatus(c *gin.Context) {
 	username := c.Param("name")
 	var json model.UserStatus
 	if err := c.ShouldBindJSON(&json); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	status, err := model.SetUserStatus(username, json)
+	status, err := model.Repo.SetUserStatus(username, json)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": string(err.Error())})
+		c.Error(err)
 		return
 	}
 
 	if status {
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "User '" + username + "' has been " + json.Status})
 	} else {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err})
+		c.Error(err)
+	}
+}
+
+// SetUserRole Set the role of a user. Can be 'admin', 'network-operator' or 'read-only'
+//
+//	@Summary		Set a user's role. Can be 'admin', 'network-operator' or 'read-only'
+//	@Description	Set a user's role
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			role	body	model.UserRole	true	"Role data"
+//	@Param			name	path	string	true	"User name"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessMsg
+//	@Failure		400	{object}	model.Problem
+//	@Failure		404	{object}	model.Problem
+//	@Failure		409	{object}	model.Problem
+//	@Router			/user/{name}/role [patch]
+func (i *IpManager) SetUserRole(c *gin.Context) {
+	username := c.Param("name")
+	var json model.UserRole
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	status, err := model.Repo.SetUserRole(username, json)
+	if err != nil {
+		log.Println("ERROR: Cannot set role of user '" + username + "': " + string(err.Error()))
+		if err == sql.ErrNoRows {
+			c.Error(&model.NotFound{Err: errors.New("no records found with user name " + username)})
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	if status {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "User '" + username + "' now has the role " + json.Role})
+	} else {
+		c.Error(errors.New("User role could not be updated!"))
 	}
 }
 
 // GetUsers Retrieve list of all users
 //
 //	@Summary		Retrieve list of all users
-//	@Description	Retrieve list of all users
+//	@Description	Retrieve a page of the users. Admins get the full detail of every account, and can sort and filter on any field but PasswordHash. Other users get the detail of their own account and the PublicUser fields of the rest, and can only sort and filter on those
 //	@Tags			user
 //	@Produce		json
-//	@Success		200	{object}	model.UsersList
-//	@Failure		400	{object}	model.FailureMsg
+//	@Param			limit			query	int		false	"Maximum number of users to return, at most 1000"	default(100)
+//	@Param			offset			query	int		false	"Number of users to skip"
+//	@Param			sort			query	string	false	"Comma separated fields to sort on, each prefixed with - for descending order"
+//	@Param			status			query	string	false	"Only users with this status, enabled or locked. Admins only"
+//	@Param			role			query	string	false	"Only users with this role. Admins only"
+//	@Param			createdAfter	query	string	false	"Only users created at or after this time, as an RFC 3339 timestamp or a date"
+//	@Param			createdBefore	query	string	false	"Only users created before this time, as an RFC 3339 timestamp or a date"
+//	@Param			changedAfter	query	string	false	"Only users last changed at or after this time, as an RFC 3339 timestamp or a date. Admins only"
+//	@Param			changedBefore	query	string	false	"Only users last changed before this time, as an RFC 3339 timestamp or a date. Admins only"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
+//	@Success		200	{object}	SafeUserList
+//	@Failure		400	{object}	model.Problem
+//	@Failure		422	{object}	model.Problem
 //	@Router			/users [get]
 func (i *IpManager) GetUsers(c *gin.Context) {
-	users, err := model.GetUsers()
-	helpers.CheckError(err)
-
-	safeUsers := make([]SafeUser, 0)
-	for _, user := range users {
-		safeUser := SafeUser{}
-		safeUser.Id = user.Id
-		safeUser.UserName = user.UserName
-		safeUser.CreationDate = user.CreationDate
+	o, err := listOptions(c)
+	if err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
 
-		safeUsers = append(safeUsers, safeUser)
+	// only admins see more than the PublicUser fields of other accounts, so everyone else
+	// can only sort and filter on those
+	if c.GetString(globals.RoleKey) != model.RoleAdmin {
+		o.Fields = []string{"Id", "UserName", "CreationDate"}
 	}
 
-	if users == nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no records found!"})
-	} else {
-		c.IndentedJSON(http.StatusOK, gin.H{"data": safeUsers})
+	users, total, err := model.Repo.GetUsers(o)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+
+	writePage(c, serializeUsers(c, users), total, o)
 }
 
 // GetUserById Retrieve a user by their Id
 //
 //	@Summary		Retrieve a user by their Id
-//	@Description	Retrieve a user by their Id
+//	@Description	Retrieve a user by their Id. Only admins and the user themselves get the full detail, everyone else gets the PublicUser fields
 //	@Tags			user
 //	@Produce		json
 //	@Param			id	path int true "User ID"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	SafeUser
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/user/id/{id} [get]
 func (i *IpManager) GetUserById(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	ent, err := model.GetUserById(id)
-	helpers.CheckError(err)
-
-	// don't return the password hash
-	safeUser := new(SafeUser)
-	safeUser.Id = ent.Id
-	safeUser.UserName = ent.UserName
-	safeUser.CreationDate = ent.CreationDate
+	ent, err := model.Repo.GetUserById(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.UserName == "" {
 		strId := strconv.Itoa(id)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with user id " + strId})
+		c.Error(&model.NotFound{Err: errors.New("no records found with user id " + strId)})
 	} else {
-		c.IndentedJSON(http.StatusOK, safeUser)
+		c.IndentedJSON(http.StatusOK, serializeUser(c, ent))
 	}
 }
 
 // GetUserByName Retrieve a user by their UserName
 //
 //	@Summary		Retrieve a user by their UserName
-//	@Description	Retrieve a user by their UserName
+//	@Description	Retrieve a user by their UserName. Only admins and the user themselves get the full detail, everyone else gets the PublicUser fields
 //	@Tags			user
 //	@Produce		json
 //	@Param			name	path	string	true	"User name"
+//	@Security		BasicAuth
+//	@Security		BearerAuth
 //	@Success		200	{object}	SafeUser
-//	@Failure		400	{object}	model.FailureMsg
+//	@Failure		404	{object}	model.Problem
 //	@Router			/user/name/{name} [get]
 func (i *IpManager) GetUserByUserName(c *gin.Context) {
 	username := c.Param("name")
-	ent, err := model.GetUserByUserName(username)
-	helpers.CheckError(err)
-
-	// don't return the password hash
-	safeUser := new(SafeUser)
-	safeUser.Id = ent.Id
-	safeUser.UserName = ent.UserName
-	safeUser.CreationDate = ent.CreationDate
+	ent, err := model.Repo.GetUserByUserName(username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if ent.UserName == "" {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no records found with user name " + username})
+		c.Error(&model.NotFound{Err: errors.New("no records found with user name " + username)})
 	} else {
-		c.IndentedJSON(http.StatusOK, safeUser)
+		c.IndentedJSON(http.StatusOK, serializeUser(c, ent))
 	}
 }
@@ -0,0 +1,230 @@
+package db
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// SQLite migrations that need Go rather than plain SQL. Values such as role names are written
+// out in full rather than taken from the model package, as a migration must keep doing what
+// it did when it was released
+
+func columnExists(t *sql.Tx, table string, column string) (bool, error) {
+	count := 0
+	err := t.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	return count > 0, err
+}
+
+// addUserRoles adds the Role column to Users. Every account already present keeps the
+// unrestricted access it had, so nobody is locked out by the upgrade
+func addUserRoles(t *sql.Tx) error {
+	// databases run by builds from before migrations may have had the column added already
+	exists, err := columnExists(t, "Users", "Role")
+	if err != nil {
+		log.Println("ERROR: Failed to read Users table layout")
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = t.Exec("ALTER TABLE Users ADD COLUMN Role STRING NOT NULL DEFAULT 'read-only'")
+	if err != nil {
+		log.Println("ERROR: Failed to add Role column to Users table")
+		return err
+	}
+	result, err := t.Exec("UPDATE Users SET Role = 'admin'")
+	if err != nil {
+		log.Println("ERROR: Failed to set role of existing users")
+		return err
+	}
+	if count, _ := result.RowsAffected(); count > 0 {
+		log.Println("NOTICE: Existing users have been given the admin role")
+	}
+	return nil
+}
+
+// addExternalIdentities adds the columns binding an account to the issuer and subject of an
+// OpenID Connect identity
+func addExternalIdentities(t *sql.Tx) error {
+	// databases run by builds from before migrations may have had the columns added already
+	exists, err := columnExists(t, "Users", "ExternalSubject")
+	if err != nil {
+		log.Println("ERROR: Failed to read Users table layout")
+		return err
+	}
+	if !exists {
+		_, err = t.Exec("ALTER TABLE Users ADD COLUMN ExternalIssuer STRING")
+		if err != nil {
+			log.Println("ERROR: Failed to add ExternalIssuer column to Users table")
+			return err
+		}
+		_, err = t.Exec("ALTER TABLE Users ADD COLUMN ExternalSubject STRING")
+		if err != nil {
+			log.Println("ERROR: Failed to add ExternalSubject column to Users table")
+			return err
+		}
+	}
+
+	_, err = t.Exec("CREATE UNIQUE INDEX IF NOT EXISTS UsersExternalIdentity ON Users (ExternalIssuer, ExternalSubject)")
+	if err != nil {
+		log.Println("ERROR: Failed to index external identities of Users table")
+		return err
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a table name taken from data rather than code
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// createSubnetAddresses replaces the table each subnet used to get for its addresses, named
+// after the subnet, with the single SubnetAddresses table. The rows of every per-subnet table
+// are copied across in their original order, which is address order, before it's dropped
+func createSubnetAddresses(t *sql.Tx) error {
+	_, err := t.Exec(`CREATE TABLE SubnetAddresses (
+    Id              INTEGER  PRIMARY KEY AUTOINCREMENT
+                             UNIQUE
+                             NOT NULL,
+    SubnetId        INTEGER  NOT NULL
+                             REFERENCES Subnets (Id) ON DELETE CASCADE,
+    IpAddress       STRING   NOT NULL,
+    AssignmentState BOOL     NOT NULL
+                             DEFAULT (0),
+    UNIQUE (SubnetId, IpAddress)
+)`)
+	if err != nil {
+		log.Println("ERROR: Failed to create SubnetAddresses table")
+		return err
+	}
+
+	rows, err := t.Query("SELECT Id, NetworkName FROM Subnets ORDER BY Id")
+	if err != nil {
+		log.Println("ERROR: Failed to query subnets")
+		return err
+	}
+	subnets := make(map[int]string)
+	order := make([]int, 0)
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			rows.Close()
+			log.Println("ERROR: Failed to scan rows")
+			return err
+		}
+		subnets[id] = name
+		order = append(order, id)
+	}
+	rows.Close()
+
+	for _, id := range order {
+		name := subnets[id]
+		// a subnet whose table was never created, or whose name collided with one of ours,
+		// has nothing of its own to copy
+		hasAddress, err := columnExists(t, name, "IpAddress")
+		if err != nil {
+			log.Println("ERROR: Failed to read layout of table '" + name + "'")
+			return err
+		}
+		hasState, err := columnExists(t, name, "AssignmentState")
+		if err != nil {
+			log.Println("ERROR: Failed to read layout of table '" + name + "'")
+			return err
+		}
+		if !hasAddress || !hasState {
+			log.Println("WARN: Subnet '" + name + "' has no address table to migrate")
+			continue
+		}
+
+		result, err := t.Exec("INSERT INTO SubnetAddresses (SubnetId, IpAddress, AssignmentState) SELECT ?, IpAddress, AssignmentState FROM "+
+			quoteIdentifier(name)+" ORDER BY Id", id)
+		if err != nil {
+			log.Println("ERROR: Failed to copy addresses of subnet '" + name + "'")
+			return err
+		}
+		_, err = t.Exec("DROP TABLE " + quoteIdentifier(name))
+		if err != nil {
+			log.Println("ERROR: Failed to drop address table of subnet '" + name + "'")
+			return err
+		}
+		count, _ := result.RowsAffected()
+		log.Println("NOTICE: Moved " + strconv.FormatInt(count, 10) + " addresses of subnet '" + name + "' to SubnetAddresses")
+	}
+	return nil
+}
+
+// addSubnetParents lets subnets be nested under a parent subnet. A prefix then only has to be
+// unique along with its length, and as SQLite can't drop a constraint, Subnets is rebuilt
+// without the one on NetworkPrefix alone
+func addSubnetParents(t *sql.Tx) error {
+	_, err := t.Exec(`CREATE TABLE Subnets_new (
+    Id             INTEGER  NOT NULL
+                            UNIQUE
+                            PRIMARY KEY AUTOINCREMENT,
+    NetworkName    STRING   NOT NULL
+                            UNIQUE,
+    NetworkPrefix  STRING   NOT NULL,
+    BitMask        INTEGER  NOT NULL,
+    GatewayAddress STRING   NOT NULL,
+    DomainId       INTEGER  NOT NULL
+                            REFERENCES Domains (Id),
+    CreatorId      INTEGER  REFERENCES Users (Id)
+                            NOT NULL,
+    CreationDate   DATETIME NOT NULL
+                            DEFAULT (CURRENT_TIMESTAMP),
+    ParentId       INTEGER  REFERENCES Subnets (Id),
+    UNIQUE (NetworkPrefix, BitMask)
+)`)
+	if err != nil {
+		log.Println("ERROR: Failed to create new Subnets table")
+		return err
+	}
+
+	result, err := t.Exec(`INSERT INTO Subnets_new (Id, NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, CreationDate)
+	SELECT Id, NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, CreationDate FROM Subnets ORDER BY Id`)
+	if err != nil {
+		log.Println("ERROR: Failed to copy subnets")
+		return err
+	}
+
+	// with foreign keys off, the references other tables make to Subnets are left as they
+	// are, and so point at the new table once it's renamed
+	for _, statement := range []string{
+		"DROP TABLE Subnets",
+		"ALTER TABLE Subnets_new RENAME TO Subnets",
+		"CREATE INDEX SubnetsParent ON Subnets (ParentId)",
+	} {
+		_, err = t.Exec(statement)
+		if err != nil {
+			log.Println("ERROR: Failed to replace Subnets table")
+			return err
+		}
+	}
+
+	count, _ := result.RowsAffected()
+	log.Println("NOTICE: Rebuilt Subnets table with " + strconv.FormatInt(count, 10) + " subnets")
+	return nil
+}
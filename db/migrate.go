@@ -0,0 +1,349 @@
+package db
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema changes are numbered migrations, applied in order and each in its own transaction.
+// Each database backend has its own set, as plain SQL files under migrations/<backend>/,
+// named <version>_<name>.sql; those that need to inspect the database first are Go functions
+// registered with the backend. A version number must only ever be used once, and a migration
+// must never change once it has been released. Backends are numbered independently, as the
+// PostgreSQL set started out from the schema SQLite databases had reached by then
+
+// the database backends, as named in the configuration
+const (
+	Sqlite   = "sqlite"
+	Postgres = "postgres"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	sql     string
+	fn      func(t *sql.Tx) error
+	// rebuildsTables is set for SQLite migrations that replace a table others refer to,
+	// which has to be done with foreign key enforcement off. The references are checked
+	// before the migration commits instead
+	rebuildsTables bool
+}
+
+// databases created before migrations existed were loaded from the schema in migration 1
+const legacyVersion = 1
+
+// backend holds what the migrator needs to know about a database backend
+type backend struct {
+	goMigrations []Migration
+	// counts the tables with the name given as the only parameter
+	tableQuery string
+	// records a migration, given its version and name
+	recordVersion string
+	// whether there are databases from before migrations to recognise
+	legacy bool
+}
+
+var backends = map[string]backend{
+	Sqlite: {
+		goMigrations: []Migration{
+			{Version: 2, Name: "user_roles", fn: addUserRoles},
+			{Version: 6, Name: "external_identities", fn: addExternalIdentities},
+			{Version: 7, Name: "subnet_addresses", fn: createSubnetAddresses},
+			{Version: 8, Name: "subnet_parents", fn: addSubnetParents, rebuildsTables: true},
+		},
+		tableQuery:    "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
+		recordVersion: "INSERT INTO schema_version (Version, Name) VALUES (?, ?)",
+		legacy:        true,
+	},
+	// unquoted names are folded to lower case by PostgreSQL
+	Postgres: {
+		tableQuery:    "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = lower($1)",
+		recordVersion: "INSERT INTO schema_version (Version, Name) VALUES ($1, $2)",
+	},
+}
+
+func backendByName(driver string) (backend, error) {
+	b, ok := backends[driver]
+	if !ok {
+		return backend{}, errors.New("no migrations for database driver '" + driver + "'")
+	}
+	return b, nil
+}
+
+// Migrations returns every known migration for a backend, ordered by version
+func Migrations(driver string) ([]Migration, error) {
+	b, err := backendByName(driver)
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0)
+	migrations = append(migrations, b.goMigrations...)
+
+	files, err := fs.Glob(migrationFiles, "migrations/"+driver+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		number, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !found || err != nil {
+			return nil, errors.New("migration file " + file + " is not named <version>_<name>.sql")
+		}
+		content, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for idx, migration := range migrations {
+		if migration.Version != idx+1 {
+			return nil, fmt.Errorf("migration versions must run from 1 without gaps or repeats, found %d at position %d", migration.Version, idx+1)
+		}
+	}
+	return migrations, nil
+}
+
+func (b backend) tableExists(conn *sql.DB, name string) (bool, error) {
+	count := 0
+	err := conn.QueryRow(b.tableQuery, name).Scan(&count)
+	return count > 0, err
+}
+
+func (b backend) ensureVersionTable(conn *sql.DB) error {
+	exists, err := b.tableExists(conn, "schema_version")
+	if err != nil {
+		log.Println("ERROR: Failed to look for schema_version table")
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	// a database with tables but no version table was created from the old schema.sql,
+	// which is what migration 1 holds, so it starts out at that version
+	legacy := false
+	if b.legacy {
+		legacy, err = b.tableExists(conn, "Users")
+		if err != nil {
+			log.Println("ERROR: Failed to look for Users table")
+			return err
+		}
+	}
+
+	_, err = conn.Exec(`CREATE TABLE schema_version (
+    Version     INTEGER   PRIMARY KEY
+                          NOT NULL,
+    Name        TEXT      NOT NULL,
+    AppliedDate TIMESTAMP NOT NULL
+                          DEFAULT (CURRENT_TIMESTAMP)
+)`)
+	if err != nil {
+		log.Println("ERROR: Failed to create schema_version table")
+		return err
+	}
+	if legacy {
+		log.Println("NOTICE: Existing database predates migrations, marking it as schema version " + strconv.Itoa(legacyVersion))
+		_, err = conn.Exec(b.recordVersion, legacyVersion, "initial")
+		if err != nil {
+			log.Println("ERROR: Failed to record schema version")
+			return err
+		}
+	}
+	return nil
+}
+
+// CurrentVersion returns the schema version of the database, 0 if nothing has been applied
+func CurrentVersion(conn *sql.DB, driver string) (int, error) {
+	b, err := backendByName(driver)
+	if err != nil {
+		return 0, err
+	}
+	return b.currentVersion(conn)
+}
+
+func (b backend) currentVersion(conn *sql.DB) (int, error) {
+	exists, err := b.tableExists(conn, "schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		if !b.legacy {
+			return 0, nil
+		}
+		legacy, err := b.tableExists(conn, "Users")
+		if err != nil || !legacy {
+			return 0, err
+		}
+		return legacyVersion, nil
+	}
+	version := 0
+	err = conn.QueryRow("SELECT COALESCE(MAX(Version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		log.Println("ERROR: Failed to read schema version")
+		return 0, err
+	}
+	return version, nil
+}
+
+// LatestVersion returns the version the database is at once every migration is applied
+func LatestVersion(driver string) (int, error) {
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return 0, err
+	}
+	return len(migrations), nil
+}
+
+// checkForeignKeys fails if any row refers to one that doesn't exist
+func checkForeignKeys(t *sql.Tx) error {
+	rows, err := t.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		log.Println("ERROR: Failed to check foreign keys")
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		return errors.New("migration left rows referring to rows that don't exist")
+	}
+	return rows.Err()
+}
+
+func (b backend) apply(pool *sql.DB, migration Migration) error {
+	label := strconv.Itoa(migration.Version) + "_" + migration.Name
+	log.Println("NOTICE: Applying migration " + label)
+
+	// foreign key enforcement can only be switched outside a transaction, and only for the
+	// connection it's switched on, so the migration gets a connection of its own
+	ctx := context.Background()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		log.Println("ERROR: Failed to reserve connection")
+		return err
+	}
+	defer conn.Close()
+	if migration.rebuildsTables {
+		_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+		if err != nil {
+			log.Println("ERROR: Failed to turn off foreign key enforcement")
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
+
+	t, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to apply migration " + label)
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to apply migration " + label)
+			t.Rollback()
+		}
+	}()
+
+	if migration.fn != nil {
+		err = migration.fn(t)
+	} else {
+		_, err = t.Exec(migration.sql)
+	}
+	if err != nil {
+		return err
+	}
+	if migration.rebuildsTables {
+		err = checkForeignKeys(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = t.Exec(b.recordVersion, migration.Version, migration.Name)
+	if err != nil {
+		log.Println("ERROR: Failed to record schema version")
+		return err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return err
+	}
+	return nil
+}
+
+// Migrate brings the database up to the latest schema version, creating it from scratch if
+// it's empty, and returns the versions it applied
+func Migrate(conn *sql.DB, driver string) ([]int, error) {
+	b, err := backendByName(driver)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	err = b.ensureVersionTable(conn)
+	if err != nil {
+		return nil, err
+	}
+	current, err := b.currentVersion(conn)
+	if err != nil {
+		return nil, err
+	}
+	if current > len(migrations) {
+		return nil, fmt.Errorf("database schema version %d is newer than this release knows about (%d)", current, len(migrations))
+	}
+
+	applied := make([]int, 0)
+	for _, migration := range migrations[current:] {
+		err = b.apply(conn, migration)
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration.Version)
+	}
+
+	if len(applied) > 0 {
+		log.Println("NOTICE: Database schema migrated to version " + strconv.Itoa(len(migrations)))
+	}
+	return applied, nil
+}
@@ -0,0 +1,149 @@
+-- The schema as it stood when PostgreSQL support was introduced, matching that of a SQLite
+-- database at its version 6
+
+-- Table: Users
+CREATE TABLE IF NOT EXISTS Users (
+    Id              INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                              PRIMARY KEY,
+    UserName        TEXT      UNIQUE
+                              NOT NULL,
+    Status          TEXT      NOT NULL
+                              DEFAULT 'enabled',
+    PasswordHash    TEXT      NOT NULL,
+    CreationDate    TIMESTAMP NOT NULL
+                              DEFAULT (CURRENT_TIMESTAMP),
+    LastChangedDate TIMESTAMP NOT NULL
+                              DEFAULT (CURRENT_TIMESTAMP),
+    Role            TEXT      NOT NULL
+                              DEFAULT 'read-only',
+    ExternalIssuer  TEXT,
+    ExternalSubject TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS UsersExternalIdentity ON Users (ExternalIssuer, ExternalSubject);
+
+-- Table: Domains
+CREATE TABLE IF NOT EXISTS Domains (
+    Id           INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                           PRIMARY KEY,
+    DomainName   TEXT      UNIQUE
+                           NOT NULL,
+    CreatorId    INTEGER   NOT NULL
+                           REFERENCES Users (Id),
+    CreationDate TIMESTAMP NOT NULL
+                           DEFAULT (CURRENT_TIMESTAMP)
+);
+
+-- Table: Hosts
+CREATE TABLE IF NOT EXISTS Hosts (
+    Id           INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                           PRIMARY KEY,
+    HostName     TEXT      NOT NULL
+                           UNIQUE,
+    MacAddresses TEXT      NOT NULL,
+    CreatorId    INTEGER   NOT NULL
+                           REFERENCES Users (Id),
+    CreationDate TIMESTAMP NOT NULL
+                           DEFAULT (CURRENT_TIMESTAMP)
+);
+
+-- Table: Subnets
+CREATE TABLE IF NOT EXISTS Subnets (
+    Id             INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                             PRIMARY KEY,
+    NetworkName    TEXT      NOT NULL
+                             UNIQUE,
+    NetworkPrefix  TEXT      NOT NULL
+                             UNIQUE,
+    BitMask        INTEGER   NOT NULL,
+    GatewayAddress TEXT      NOT NULL,
+    DomainId       INTEGER   NOT NULL
+                             REFERENCES Domains (Id),
+    CreatorId      INTEGER   NOT NULL
+                             REFERENCES Users (Id),
+    CreationDate   TIMESTAMP NOT NULL
+                             DEFAULT (CURRENT_TIMESTAMP)
+);
+
+-- Table: AssignedAddresses
+CREATE TABLE IF NOT EXISTS AssignedAddresses (
+    Id           INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                           PRIMARY KEY,
+    Address      TEXT      UNIQUE
+                           NOT NULL,
+    HostNameId   INTEGER   NOT NULL
+                           REFERENCES Hosts (Id),
+    DomainId     INTEGER   NOT NULL
+                           REFERENCES Domains (Id),
+    SubnetId     INTEGER   NOT NULL
+                           REFERENCES Subnets (Id),
+    CreatorId    INTEGER   NOT NULL
+                           REFERENCES Users (Id),
+    CreationDate TIMESTAMP NOT NULL
+                           DEFAULT (CURRENT_TIMESTAMP)
+);
+
+-- Table: SubnetAddresses
+CREATE TABLE IF NOT EXISTS SubnetAddresses (
+    Id              INTEGER GENERATED BY DEFAULT AS IDENTITY
+                            PRIMARY KEY,
+    SubnetId        INTEGER NOT NULL
+                            REFERENCES Subnets (Id) ON DELETE CASCADE,
+    IpAddress       TEXT    NOT NULL,
+    AssignmentState INTEGER NOT NULL
+                            DEFAULT 0,
+    UNIQUE (SubnetId, IpAddress)
+);
+
+-- Table: ApiTokens
+CREATE TABLE IF NOT EXISTS ApiTokens (
+    Id           INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                           PRIMARY KEY,
+    UserId       INTEGER   NOT NULL
+                           REFERENCES Users (Id) ON DELETE CASCADE,
+    Name         TEXT      NOT NULL,
+    TokenHash    TEXT      UNIQUE
+                           NOT NULL,
+    Scopes       TEXT      NOT NULL,
+    ExpiresAt    TIMESTAMP,
+    LastUsedDate TIMESTAMP,
+    CreationDate TIMESTAMP NOT NULL
+                           DEFAULT (CURRENT_TIMESTAMP)
+);
+
+-- Table: Sessions
+CREATE TABLE IF NOT EXISTS Sessions (
+    Id           INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                           PRIMARY KEY,
+    SessionKey   TEXT      UNIQUE
+                           NOT NULL,
+    UserName     TEXT,
+    Data         TEXT      NOT NULL,
+    ClientIp     TEXT,
+    CreationDate TIMESTAMP NOT NULL
+                           DEFAULT (CURRENT_TIMESTAMP),
+    LastSeenDate TIMESTAMP NOT NULL
+                           DEFAULT (CURRENT_TIMESTAMP),
+    ExpiresAt    TIMESTAMP NOT NULL
+);
+
+-- Table: AuditLog
+CREATE TABLE IF NOT EXISTS AuditLog (
+    Id           INTEGER   GENERATED BY DEFAULT AS IDENTITY
+                           PRIMARY KEY,
+    UserName     TEXT      NOT NULL,
+    Method       TEXT      NOT NULL,
+    Route        TEXT      NOT NULL,
+    Path         TEXT      NOT NULL,
+    ObjectType   TEXT,
+    ObjectId     TEXT,
+    Before       TEXT,
+    After        TEXT,
+    Request      TEXT,
+    StatusCode   INTEGER   NOT NULL,
+    ClientIp     TEXT,
+    CreationDate TIMESTAMP NOT NULL
+                           DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE INDEX IF NOT EXISTS AuditLogObject ON AuditLog (ObjectType, ObjectId);
@@ -0,0 +1,8 @@
+-- Subnets can be nested under a parent subnet, so a prefix is only unique along with its
+-- length
+
+ALTER TABLE Subnets DROP CONSTRAINT subnets_networkprefix_key;
+ALTER TABLE Subnets ADD UNIQUE (NetworkPrefix, BitMask);
+ALTER TABLE Subnets ADD COLUMN ParentId INTEGER REFERENCES Subnets (Id);
+
+CREATE INDEX IF NOT EXISTS SubnetsParent ON Subnets (ParentId);
@@ -0,0 +1,17 @@
+-- Scoped API tokens for bearer authentication. Only a hash of each token is kept
+
+CREATE TABLE IF NOT EXISTS ApiTokens (
+    Id           INTEGER  PRIMARY KEY AUTOINCREMENT
+                          UNIQUE
+                          NOT NULL,
+    UserId       INTEGER  NOT NULL
+                          REFERENCES Users (Id) ON DELETE CASCADE,
+    Name         STRING   NOT NULL,
+    TokenHash    STRING   UNIQUE
+                          NOT NULL,
+    Scopes       JSON     NOT NULL,
+    ExpiresAt    DATETIME,
+    LastUsedDate DATETIME,
+    CreationDate DATETIME NOT NULL
+                          DEFAULT (CURRENT_TIMESTAMP)
+);
@@ -0,0 +1,17 @@
+-- Server-side session storage, used when the sqlite session store is configured
+
+CREATE TABLE IF NOT EXISTS Sessions (
+    Id           INTEGER  PRIMARY KEY AUTOINCREMENT
+                          UNIQUE
+                          NOT NULL,
+    SessionKey   STRING   UNIQUE
+                          NOT NULL,
+    UserName     STRING,
+    Data         STRING   NOT NULL,
+    ClientIp     STRING,
+    CreationDate DATETIME NOT NULL
+                          DEFAULT (CURRENT_TIMESTAMP),
+    LastSeenDate DATETIME NOT NULL
+                          DEFAULT (CURRENT_TIMESTAMP),
+    ExpiresAt    DATETIME NOT NULL
+);
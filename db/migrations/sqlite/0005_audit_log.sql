@@ -0,0 +1,22 @@
+-- Record of every mutating API call
+
+CREATE TABLE IF NOT EXISTS AuditLog (
+    Id           INTEGER  PRIMARY KEY AUTOINCREMENT
+                          UNIQUE
+                          NOT NULL,
+    UserName     STRING   NOT NULL,
+    Method       STRING   NOT NULL,
+    Route        STRING   NOT NULL,
+    Path         STRING   NOT NULL,
+    ObjectType   STRING,
+    ObjectId     STRING,
+    Before       JSON,
+    After        JSON,
+    Request      JSON,
+    StatusCode   INTEGER  NOT NULL,
+    ClientIp     STRING,
+    CreationDate DATETIME NOT NULL
+                          DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE INDEX IF NOT EXISTS AuditLogObject ON AuditLog (ObjectType, ObjectId);
@@ -0,0 +1,8 @@
+-- Container blocks hold other subnets rather than addresses. Subnets that already have others
+-- nested under them become containers, losing their gateway and their free address rows
+
+ALTER TABLE Subnets ADD COLUMN Container BOOLEAN NOT NULL DEFAULT 0;
+
+UPDATE Subnets SET Container = 1, GatewayAddress = '' WHERE Id IN (SELECT ParentId FROM Subnets WHERE ParentId IS NOT NULL);
+
+DELETE FROM SubnetAddresses WHERE AssignmentState = 0 AND SubnetId IN (SELECT Id FROM Subnets WHERE Container = 1);
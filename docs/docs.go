@@ -22,14 +22,17 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/domain": {
+        "/address": {
             "post": {
                 "security": [
                     {
                         "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Create a new domain",
+                "description": "Assign an address from a subnet to a host",
                 "consumes": [
                     "application/json"
                 ],
@@ -37,17 +40,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "domain"
+                    "address"
                 ],
-                "summary": "Create a new domain",
+                "summary": "Assign address",
                 "parameters": [
                     {
-                        "description": "Domain data",
-                        "name": "domain",
+                        "description": "Address assignment data",
+                        "name": "address",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Domain"
+                            "$ref": "#/definitions/model.AddressAssignment"
                         }
                     }
                 ],
@@ -61,27 +64,47 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/domain/id/{domainid}": {
-            "get": {
-                "description": "Retrieve a domain by Id",
+        "/address/{address}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove an address assignment, returning the address to its subnet's pool",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "domain"
+                    "address"
                 ],
-                "summary": "Retrieve a domain by Id",
+                "summary": "Release address",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Domain Id",
-                        "name": "domainid",
+                        "description": "IP address",
+                        "name": "address",
                         "in": "path",
                         "required": true
                     }
@@ -90,180 +113,326 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Domain"
+                            "$ref": "#/definitions/model.SuccessMsg"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/domain/name/{domainname}": {
-            "get": {
-                "description": "Retrieve a domain by DomainName",
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change the host or domain an address is assigned to",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "domain"
+                    "address"
                 ],
-                "summary": "Retrieve a domain by DomainName",
+                "summary": "Reassign address",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Domain name",
-                        "name": "domainname",
+                        "description": "IP address",
+                        "name": "address",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Address reassignment data",
+                        "name": "addressReassignment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.AddressReassignment"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Domain"
+                            "$ref": "#/definitions/model.SuccessMsg"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/domain/{domainname}": {
-            "delete": {
-                "security": [
-                    {
-                        "BasicAuth": []
-                    }
-                ],
-                "description": "Delete a domain",
+        "/addresses": {
+            "get": {
+                "description": "Retrieve a page of the assigned addresses. Any other field of an address can be filtered on by passing it as a parameter, and sorted on",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "domain"
+                    "address"
                 ],
-                "summary": "Delete a domain",
+                "summary": "Retrieve a list of assigned addresses",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of addresses to return, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of addresses to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Domain name",
-                        "name": "domainname",
-                        "in": "path",
-                        "required": true
+                        "description": "Comma separated fields to sort on, each prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only addresses created by this user Id",
+                        "name": "creatorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only addresses created at or after this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only addresses created before this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdBefore",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.SuccessMsg"
+                            "$ref": "#/definitions/model.AddressList"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/domains": {
+        "/addresses/subnet/name/{subnetname}/unassigned": {
             "get": {
-                "description": "Retrieve a list of domain",
+                "description": "Retrieve the unassigned addresses of a subnet, or just how many there are when count is set, as a string since an IPv6 subnet can hold more than a JSON number can",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "domain"
+                    "address"
+                ],
+                "summary": "Retrieve the unassigned addresses of a subnet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subnet name",
+                        "name": "subnetname",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of addresses to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of addresses to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the number of unassigned addresses",
+                        "name": "count",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Retrieve a list of domain",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.DomainList"
+                            "$ref": "#/definitions/model.UnassignedAddressList"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/host": {
-            "post": {
+        "/audit": {
+            "get": {
                 "security": [
                     {
                         "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Add a new host",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Retrieve the record of mutating API calls, newest first, optionally filtered",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "host"
+                    "audit"
                 ],
-                "summary": "Register host",
+                "summary": "Retrieve audit log entries",
                 "parameters": [
                     {
-                        "description": "Host Data",
-                        "name": "host",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.Host"
-                        }
+                        "type": "string",
+                        "description": "User name",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Object type, one of address, domain, host, subnet or user",
+                        "name": "objectType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Object name or address",
+                        "name": "objectId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "HTTP method",
+                        "name": "method",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest entry, as an RFC 3339 timestamp or a date",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entries before this time, as an RFC 3339 timestamp or a date",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of entries to return, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of entries to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.SuccessMsg"
+                            "$ref": "#/definitions/model.AuditEntryList"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/host/id/{hostid}": {
+        "/auth/oidc/callback": {
             "get": {
-                "description": "Retrieve a host by its Id",
+                "description": "Exchange the authorization code from the identity provider and start a session for the mapped local user",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "host"
+                    "auth"
                 ],
-                "summary": "Retrieve a host by its Id",
+                "summary": "Finish an OpenID Connect login",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "host Id",
-                        "name": "hostid",
-                        "in": "path",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Login state",
+                        "name": "state",
+                        "in": "query",
                         "required": true
                     }
                 ],
@@ -271,61 +440,70 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Host"
+                            "$ref": "#/definitions/model.SuccessMsg"
                         }
                     },
+                    "302": {
+                        "description": "Found"
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/host/name/{hostname}": {
+        "/auth/oidc/login": {
             "get": {
-                "description": "Retrieve a host by its hostname",
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Redirect the browser to the identity provider to log in",
                 "tags": [
-                    "host"
-                ],
-                "summary": "Retrieve a host by its hostname",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "hostname",
-                        "name": "hostname",
-                        "in": "path",
-                        "required": true
-                    }
+                    "auth"
                 ],
+                "summary": "Start an OpenID Connect login",
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "302": {
+                        "description": "Found"
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.Host"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "502": {
+                        "description": "Bad Gateway",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/host/{hostname}": {
-            "delete": {
+        "/domain": {
+            "post": {
                 "security": [
                     {
                         "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Delete a host",
+                "description": "Create a new domain",
                 "consumes": [
                     "application/json"
                 ],
@@ -333,16 +511,18 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "host"
+                    "domain"
                 ],
-                "summary": "Delete a host",
+                "summary": "Create a new domain",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
-                        "in": "path",
-                        "required": true
+                        "description": "Domain data",
+                        "name": "domain",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Domain"
+                        }
                     }
                 ],
                 "responses": {
@@ -355,148 +535,62 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
-            },
-            "patch": {
-                "security": [
-                    {
-                        "BasicAuth": []
-                    }
-                ],
-                "description": "Update MAC address list",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/domain/id/{domainid}": {
+            "get": {
+                "description": "Retrieve a domain by Id",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "host"
+                    "domain"
                 ],
-                "summary": "Update MAC address list",
+                "summary": "Retrieve a domain by Id",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
+                        "description": "Domain Id",
+                        "name": "domainid",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "MAC address list",
-                        "name": "updateMacAddresses",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.MacAddressList"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/model.SuccessMsg"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
-                        }
-                    }
-                }
-            }
-        },
-        "/hosts": {
-            "get": {
-                "description": "Retrieve list of all hosts",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "host"
-                ],
-                "summary": "Retrieve list of all hosts",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/model.HostList"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
-                        }
-                    }
-                }
-            }
-        },
-        "/subnet": {
-            "post": {
-                "security": [
-                    {
-                        "BasicAuth": []
-                    }
-                ],
-                "description": "Add a new subnet",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "subnet"
-                ],
-                "summary": "Register subnet",
-                "parameters": [
-                    {
-                        "description": "Subnet Data",
-                        "name": "subnet",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.Subnet"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.SuccessMsg"
+                            "$ref": "#/definitions/model.Domain"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/subnet/id/{subnetname}": {
+        "/domain/name/{domainname}": {
             "get": {
-                "description": "Retrieve a subnet by its Id",
+                "description": "Retrieve a domain by DomainName",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subnet"
+                    "domain"
                 ],
-                "summary": "Retrieve a subnet by its Id",
+                "summary": "Retrieve a domain by DomainName",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Subnet Id",
-                        "name": "subnetid",
+                        "description": "Domain name",
+                        "name": "domainname",
                         "in": "path",
                         "required": true
                     }
@@ -505,33 +599,33 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subnet"
+                            "$ref": "#/definitions/model.Domain"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/subnet/name/{subnetname}": {
+        "/domain/name/{domainname}/zone": {
             "get": {
-                "description": "Retrieve a subnet by its network name",
+                "description": "Render a BIND zone file with SOA, NS and an A or AAAA record for every address assigned in the domain",
                 "produces": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "tags": [
-                    "subnet"
+                    "domain"
                 ],
-                "summary": "Retrieve a subnet by its network name",
+                "summary": "Render a BIND zone file for a domain",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Subnet name",
-                        "name": "subnetname",
+                        "description": "Domain name",
+                        "name": "domainname",
                         "in": "path",
                         "required": true
                     }
@@ -540,41 +634,47 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subnet"
+                            "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/subnet/{networkname}": {
+        "/domain/{domainname}": {
             "delete": {
                 "security": [
                     {
                         "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Delete a subnet",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Delete a domain",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subnet"
+                    "domain"
                 ],
-                "summary": "Delete subnet",
+                "summary": "Delete a domain",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Network name",
-                        "name": "networkname",
+                        "description": "Domain name",
+                        "name": "domainname",
                         "in": "path",
                         "required": true
                     }
@@ -586,101 +686,122 @@ const docTemplate = `{
                             "$ref": "#/definitions/model.SuccessMsg"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
-            },
-            "patch": {
-                "security": [
-                    {
-                        "BasicAuth": []
-                    }
-                ],
-                "description": "Change subnet network information",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/domains": {
+            "get": {
+                "description": "Retrieve a page of the domains. Any other field of a domain can be filtered on by passing it as a parameter, and sorted on",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subnet"
+                    "domain"
                 ],
-                "summary": "Change subnet network information",
+                "summary": "Retrieve a list of domain",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of domains to return, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of domains to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Network name",
-                        "name": "networkname",
-                        "in": "path",
-                        "required": true
+                        "description": "Comma separated fields to sort on, each prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
                     },
                     {
-                        "description": "Subnet data",
-                        "name": "subnetUpdate",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.SubnetUpdate"
-                        }
+                        "type": "integer",
+                        "description": "Only domains created by this user Id",
+                        "name": "creatorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only domains created at or after this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only domains created before this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdBefore",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.SuccessMsg"
+                            "$ref": "#/definitions/model.DomainList"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/subnets": {
+        "/export/dhcpd": {
             "get": {
-                "description": "Retrieve list of all subnets",
+                "description": "Render subnet blocks with fixed-address host declarations for every IPv4 subnet",
                 "produces": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "tags": [
-                    "subnet"
+                    "export"
                 ],
-                "summary": "Retrieve list of all subnets",
+                "summary": "Render an ISC dhcpd configuration",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subnets"
+                            "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/subnets/domain/id/{domainid}": {
+        "/export/dnsmasq/domain/{domainname}": {
             "get": {
-                "description": "Retrieve a list of subnets assigned to a domain Id",
+                "description": "Render dhcp-range, dhcp-host and host-record lines for the domain's subnets and hosts",
                 "produces": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "tags": [
-                    "subnet"
+                    "export"
                 ],
-                "summary": "Retrieve a list of subnets assigned to a domain Id",
+                "summary": "Render a dnsmasq configuration for a domain",
                 "parameters": [
                     {
                         "type": "string",
@@ -694,61 +815,87 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subnets"
+                            "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/subnets/domain/name/{domainname}": {
+        "/export/kea/dhcp4": {
             "get": {
-                "description": "Retrieve a list of subnets assigned to a domain name",
+                "description": "Render the Dhcp4 subnet4 list, with host reservations, for every IPv4 subnet",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subnet"
+                    "export"
                 ],
-                "summary": "Retrieve a list of subnets assigned to a domain name",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Domain name",
-                        "name": "domainname",
-                        "in": "path",
-                        "required": true
+                "summary": "Render a Kea DHCPv4 configuration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/generators.KeaDhcp4Config"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
                     }
+                }
+            }
+        },
+        "/export/kea/dhcp6": {
+            "get": {
+                "description": "Render the Dhcp6 subnet6 list, with host reservations, for every IPv6 subnet",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "export"
                 ],
+                "summary": "Render a Kea DHCPv6 configuration",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subnets"
+                            "$ref": "#/definitions/generators.KeaDhcp6Config"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/user": {
+        "/host": {
             "post": {
                 "security": [
                     {
                         "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Add a new user",
+                "description": "Add a new host",
                 "consumes": [
                     "application/json"
                 ],
@@ -756,17 +903,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "host"
                 ],
-                "summary": "Register user",
+                "summary": "Register host",
                 "parameters": [
                     {
-                        "description": "User Data",
-                        "name": "user",
+                        "description": "Host Data",
+                        "name": "host",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.ProposedUser"
+                            "$ref": "#/definitions/model.Host"
                         }
                     }
                 ],
@@ -780,27 +927,33 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/id/{id}": {
+        "/host/id/{hostid}": {
             "get": {
-                "description": "Retrieve a user by their Id",
+                "description": "Retrieve a host by its Id",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "host"
                 ],
-                "summary": "Retrieve a user by their Id",
+                "summary": "Retrieve a host by its Id",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
+                        "type": "string",
+                        "description": "host Id",
+                        "name": "hostid",
                         "in": "path",
                         "required": true
                     }
@@ -809,33 +962,33 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/controllers.SafeUser"
+                            "$ref": "#/definitions/model.Host"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/name/{name}": {
+        "/host/name/{hostname}": {
             "get": {
-                "description": "Retrieve a user by their UserName",
+                "description": "Retrieve a host by its hostname",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "host"
                 ],
-                "summary": "Retrieve a user by their UserName",
+                "summary": "Retrieve a host by its hostname",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User name",
-                        "name": "name",
+                        "description": "hostname",
+                        "name": "hostname",
                         "in": "path",
                         "required": true
                     }
@@ -844,26 +997,29 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/controllers.SafeUser"
+                            "$ref": "#/definitions/model.Host"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/{name}": {
+        "/host/{hostname}": {
             "delete": {
                 "security": [
                     {
                         "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Delete a user",
+                "description": "Delete a host",
                 "consumes": [
                     "application/json"
                 ],
@@ -871,14 +1027,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "host"
                 ],
-                "summary": "Delete user",
+                "summary": "Delete a host",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User name",
-                        "name": "name",
+                        "description": "Hostname",
+                        "name": "hostname",
                         "in": "path",
                         "required": true
                     }
@@ -890,10 +1046,10 @@ const docTemplate = `{
                             "$ref": "#/definitions/model.SuccessMsg"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
@@ -902,9 +1058,12 @@ const docTemplate = `{
                 "security": [
                     {
                         "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Change password",
+                "description": "Update MAC address list",
                 "consumes": [
                     "application/json"
                 ],
@@ -912,24 +1071,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "host"
                 ],
-                "summary": "Change password",
+                "summary": "Update MAC address list",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User name",
-                        "name": "name",
+                        "description": "Hostname",
+                        "name": "hostname",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Password data",
-                        "name": "changePassword",
+                        "description": "MAC address list",
+                        "name": "updateMacAddresses",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.PasswordChange"
+                            "$ref": "#/definitions/model.MacAddressList"
                         }
                     }
                 ],
@@ -943,61 +1102,131 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/{name}/status": {
+        "/hosts": {
             "get": {
-                "security": [
-                    {
-                        "BasicAuth": []
-                    }
-                ],
-                "description": "Retrieve a user's active status",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Retrieve a page of the hosts. Any other field of a host can be filtered on by passing it as a parameter, and sorted on, except MacAddresses",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "host"
                 ],
-                "summary": "Retrieve a user's active status. Can be either 'enabled' or 'locked'",
+                "summary": "Retrieve list of all hosts",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of hosts to return, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of hosts to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "User name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "description": "Comma separated fields to sort on, each prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only hosts created by this user Id",
+                        "name": "creatorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only hosts created at or after this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only hosts created before this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdBefore",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.UserStatusMsg"
+                            "$ref": "#/definitions/model.HostList"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
-            },
-            "patch": {
+            }
+        },
+        "/logout": {
+            "post": {
                 "security": [
                     {
                         "BasicAuth": []
                     }
                 ],
-                "description": "Set a user's active status",
+                "description": "End the current session and clear its cookie",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "End the current session",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnet": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a new subnet. The prefix has to be the network address of its block and the gateway a host address inside it. A subnet can't overlap another unless it's nested inside a container block by setting ParentId to the most specific one holding it. A container has Container set and no gateway, and holds subnets rather than addresses. An IPv4 subnet that isn't a container can be no larger than a /16",
                 "consumes": [
                     "application/json"
                 ],
@@ -1005,23 +1234,63 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "subnet"
                 ],
-                "summary": "Set a user's active status. Can be either 'enabled' or 'locked'",
+                "summary": "Register subnet",
                 "parameters": [
                     {
-                        "description": "User Data",
-                        "name": "user",
+                        "description": "Subnet Data",
+                        "name": "subnet",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "$ref": "#/definitions/model.Subnet"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
                         }
                     },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnet/id/{subnetname}": {
+            "get": {
+                "description": "Retrieve a subnet by its Id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Retrieve a subnet by its Id",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "User name",
-                        "name": "name",
+                        "description": "Subnet Id",
+                        "name": "subnetid",
                         "in": "path",
                         "required": true
                     }
@@ -1030,60 +1299,1729 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.UserStatusMsg"
+                            "$ref": "#/definitions/model.Subnet"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
                     }
                 }
             }
         },
-        "/users": {
+        "/subnet/name/{subnetname}": {
             "get": {
-                "description": "Retrieve list of all users",
+                "description": "Retrieve a subnet by its network name",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "subnet"
+                ],
+                "summary": "Retrieve a subnet by its network name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subnet name",
+                        "name": "subnetname",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Retrieve list of all users",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.UsersList"
+                            "$ref": "#/definitions/model.Subnet"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnet/name/{subnetname}/reversezones": {
+            "get": {
+                "description": "Render in-addr.arpa or ip6.arpa zones with PTR records for every address assigned in the subnet. Prefixes that aren't on a label boundary are split into several zones, except IPv4 prefixes longer than /24, which get an RFC 2317 classless zone and the delegation records for its parent zone",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Render the reverse DNS zones for a subnet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subnet name",
+                        "name": "subnetname",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/generators.ReverseZoneList"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/model.FailureMsg"
+                            "$ref": "#/definitions/model.Problem"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnet/name/{subnetname}/tree": {
+            "get": {
+                "description": "Retrieve a subnet with the subnets nested under it in address order. Each level carries its utilisation, which for a container block is rolled up from everything nested in it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Retrieve a subnet with the subnets nested under it",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subnet name",
+                        "name": "subnetname",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SubnetTree"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnet/{networkname}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a subnet",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Delete subnet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network name",
+                        "name": "networkname",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change subnet network information. Only the fields given are changed, and the result is checked as it is when a subnet is created. A ParentId of 0 takes the subnet out of the one it was nested in, and turning a subnet into a container takes an empty GatewayAddress. The subnets nested under a container have to stay inside its new block, and it stays a container while it holds any. The block can only change, or the subnet become or stop being a container, while none of its addresses are assigned",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Change subnet network information",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network name",
+                        "name": "networkname",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "subnetUpdate",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SubnetUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnet/{networkname}/allocate": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Pick the lowest unassigned address in a subnet and assign it to a host",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Allocate the next free address in a subnet",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Network name",
+                        "name": "networkname",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Allocation data",
+                        "name": "allocation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.AddressAllocation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Address"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnets": {
+            "get": {
+                "description": "Retrieve a page of the subnets. Any other field of a subnet can be filtered on by passing it as a parameter, and sorted on",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Retrieve list of all subnets",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of subnets to return, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of subnets to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to sort on, each prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only subnets created by this user Id",
+                        "name": "creatorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only subnets created at or after this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only subnets created before this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdBefore",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SubnetList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnets/containing/{ip}": {
+            "get": {
+                "description": "Retrieve the subnet with the longest prefix containing an address, along with the subnet's domain and whether the address is assigned, unassigned, the gateway or reserved. An address inside a container block but none of the subnets in it is unallocated",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Retrieve the most specific subnet holding an address",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "IPv4 or IPv6 address",
+                        "name": "ip",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SubnetMatch"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnets/domain/id/{domainid}": {
+            "get": {
+                "description": "Retrieve a list of subnets assigned to a domain Id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Retrieve a list of subnets assigned to a domain Id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Domain name",
+                        "name": "domainname",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subnets"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnets/domain/name/{domainname}": {
+            "get": {
+                "description": "Retrieve a list of subnets assigned to a domain name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Retrieve a list of subnets assigned to a domain name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Domain name",
+                        "name": "domainname",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subnets"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subnets/tree": {
+            "get": {
+                "description": "Retrieve every subnet that isn't nested in another, with the subnets nested under each in address order. Each level carries its utilisation, which for a container block is rolled up from everything nested in it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subnet"
+                ],
+                "summary": "Retrieve the hierarchy of subnets",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SubnetTrees"
+                        }
+                    }
+                }
+            }
+        },
+        "/user": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a new user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Register user",
+                "parameters": [
+                    {
+                        "description": "User Data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ProposedUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve a user by their Id. Only admins and the user themselves get the full detail, everyone else gets the PublicUser fields",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Retrieve a user by their Id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.SafeUser"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/name/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve a user by their UserName. Only admins and the user themselves get the full detail, everyone else gets the PublicUser fields",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Retrieve a user by their UserName",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.SafeUser"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{name}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Delete user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change a user's password. Only the user themselves or an admin can change it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Password data",
+                        "name": "changePassword",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PasswordChange"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{name}/role": {
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set a user's role",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Set a user's role. Can be 'admin', 'network-operator' or 'read-only'",
+                "parameters": [
+                    {
+                        "description": "Role data",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UserRole"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{name}/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve the active sessions of a user. Needs the database session store",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Retrieve the active sessions of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SessionList"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke all sessions of a user. Needs the database session store",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Revoke all sessions of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{name}/sessions/{sessionid}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke a session of a user. Needs the database session store",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Revoke a session of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Session ID",
+                        "name": "sessionid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{name}/status": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve a user's active status",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Retrieve a user's active status. Can be either 'enabled' or 'locked'",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserStatusMsg"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set a user's active status",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Set a user's active status. Can be either 'enabled' or 'locked'",
+                "parameters": [
+                    {
+                        "description": "Status data",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UserStatus"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserStatusMsg"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{name}/tokens": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve the API tokens of a user. The tokens themselves are never returned",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Retrieve the API tokens of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ApiTokenList"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a bearer token for a user with the given scopes and optional RFC 3339 expiry. The token is only shown in this response",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Create an API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Token data",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ProposedApiToken"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.NewApiToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{name}/tokens/{tokenid}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke an API token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Revoke an API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Token ID",
+                        "name": "tokenid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SuccessMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve a page of the users. Admins get the full detail of every account, and can sort and filter on any field but PasswordHash. Other users get the detail of their own account and the PublicUser fields of the rest, and can only sort and filter on those",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Retrieve list of all users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum number of users to return, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to sort on, each prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only users with this status, enabled or locked. Admins only",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only users with this role. Admins only",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only users created at or after this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only users created before this time, as an RFC 3339 timestamp or a date",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only users last changed at or after this time, as an RFC 3339 timestamp or a date. Admins only",
+                        "name": "changedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only users last changed before this time, as an RFC 3339 timestamp or a date. Admins only",
+                        "name": "changedBefore",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.SafeUserList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "controllers.SafeUser": {
+            "type": "object",
+            "properties": {
+                "creationDate": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastChangedDate": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "userName": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.SafeUserList": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.SafeUser"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "generators.KeaDhcp4": {
+            "type": "object",
+            "properties": {
+                "subnet4": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/generators.KeaSubnet"
+                    }
+                }
+            }
+        },
+        "generators.KeaDhcp4Config": {
+            "type": "object",
+            "properties": {
+                "Dhcp4": {
+                    "$ref": "#/definitions/generators.KeaDhcp4"
+                }
+            }
+        },
+        "generators.KeaDhcp6": {
+            "type": "object",
+            "properties": {
+                "subnet6": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/generators.KeaSubnet"
+                    }
+                }
+            }
+        },
+        "generators.KeaDhcp6Config": {
+            "type": "object",
+            "properties": {
+                "Dhcp6": {
+                    "$ref": "#/definitions/generators.KeaDhcp6"
+                }
+            }
+        },
+        "generators.KeaOptionData": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "generators.KeaReservation": {
+            "type": "object",
+            "properties": {
+                "hostname": {
+                    "type": "string"
+                },
+                "hw-address": {
+                    "type": "string"
+                },
+                "ip-address": {
+                    "type": "string"
+                },
+                "ip-addresses": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "generators.KeaSubnet": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "option-data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/generators.KeaOptionData"
+                    }
+                },
+                "reservations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/generators.KeaReservation"
+                    }
+                },
+                "subnet": {
+                    "type": "string"
+                },
+                "user-context": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "generators.ReverseZone": {
+            "type": "object",
+            "properties": {
+                "Delegation": {
+                    "type": "string"
+                },
+                "Zone": {
+                    "type": "string"
+                },
+                "ZoneName": {
+                    "type": "string"
+                }
+            }
+        },
+        "generators.ReverseZoneList": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/generators.ReverseZone"
+                    }
+                }
+            }
+        },
+        "model.Address": {
+            "type": "object",
+            "properties": {
+                "Address": {
+                    "type": "string"
+                },
+                "CreationDate": {
+                    "type": "string"
+                },
+                "CreatorId": {
+                    "type": "integer"
+                },
+                "DomainId": {
+                    "type": "integer"
+                },
+                "HostNameId": {
+                    "type": "integer"
+                },
+                "Id": {
+                    "type": "integer"
+                },
+                "SubnetId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AddressAllocation": {
+            "type": "object",
+            "properties": {
+                "DomainName": {
+                    "type": "string"
+                },
+                "HostName": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.AddressAssignment": {
+            "type": "object",
+            "properties": {
+                "Address": {
+                    "type": "string"
+                },
+                "DomainName": {
+                    "type": "string"
+                },
+                "HostName": {
+                    "type": "string"
+                },
+                "NetworkName": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.AddressList": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Address"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AddressReassignment": {
+            "type": "object",
+            "properties": {
+                "DomainName": {
+                    "type": "string"
+                },
+                "HostName": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ApiToken": {
+            "type": "object",
+            "properties": {
+                "CreationDate": {
+                    "type": "string"
+                },
+                "ExpiresAt": {
+                    "type": "string"
+                },
+                "Id": {
+                    "type": "integer"
+                },
+                "LastUsedDate": {
+                    "type": "string"
+                },
+                "Name": {
+                    "type": "string"
+                },
+                "Scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "UserId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ApiTokenList": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ApiToken"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "controllers.SafeUser": {
+        },
+        "model.AuditEntry": {
             "type": "object",
             "properties": {
-                "creationDate": {
+                "After": {
+                    "type": "object"
+                },
+                "Before": {
+                    "type": "object"
+                },
+                "ClientIp": {
                     "type": "string"
                 },
-                "id": {
+                "CreationDate": {
+                    "type": "string"
+                },
+                "Id": {
                     "type": "integer"
                 },
-                "userName": {
+                "Method": {
+                    "type": "string"
+                },
+                "ObjectId": {
+                    "type": "string"
+                },
+                "ObjectType": {
+                    "type": "string"
+                },
+                "Path": {
+                    "type": "string"
+                },
+                "Request": {
+                    "type": "object"
+                },
+                "Route": {
+                    "type": "string"
+                },
+                "StatusCode": {
+                    "type": "integer"
+                },
+                "UserName": {
                     "type": "string"
                 }
             }
         },
+        "model.AuditEntryList": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AuditEntry"
+                    }
+                }
+            }
+        },
         "model.Domain": {
             "type": "object",
             "properties": {
@@ -1109,14 +3047,15 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/model.Domain"
                     }
-                }
-            }
-        },
-        "model.FailureMsg": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
         },
@@ -1151,6 +3090,26 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/model.Host"
                     }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.InvalidParam": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
                 }
             }
         },
@@ -1165,6 +3124,38 @@ const docTemplate = `{
                 }
             }
         },
+        "model.NewApiToken": {
+            "type": "object",
+            "properties": {
+                "CreationDate": {
+                    "type": "string"
+                },
+                "ExpiresAt": {
+                    "type": "string"
+                },
+                "Id": {
+                    "type": "integer"
+                },
+                "LastUsedDate": {
+                    "type": "string"
+                },
+                "Name": {
+                    "type": "string"
+                },
+                "Scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Token": {
+                    "type": "string"
+                },
+                "UserId": {
+                    "type": "integer"
+                }
+            }
+        },
         "model.PasswordChange": {
             "type": "object",
             "properties": {
@@ -1176,6 +3167,49 @@ const docTemplate = `{
                 }
             }
         },
+        "model.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "invalidParams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.InvalidParam"
+                    }
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ProposedApiToken": {
+            "type": "object",
+            "properties": {
+                "ExpiresAt": {
+                    "type": "string"
+                },
+                "Name": {
+                    "type": "string"
+                },
+                "Scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "model.ProposedUser": {
             "type": "object",
             "properties": {
@@ -1188,6 +3222,9 @@ const docTemplate = `{
                 "Password": {
                     "type": "string"
                 },
+                "Role": {
+                    "type": "string"
+                },
                 "Status": {
                     "type": "string"
                 },
@@ -1196,12 +3233,50 @@ const docTemplate = `{
                 }
             }
         },
+        "model.Session": {
+            "type": "object",
+            "properties": {
+                "ClientIp": {
+                    "type": "string"
+                },
+                "CreationDate": {
+                    "type": "string"
+                },
+                "ExpiresAt": {
+                    "type": "string"
+                },
+                "Id": {
+                    "type": "integer"
+                },
+                "LastSeenDate": {
+                    "type": "string"
+                },
+                "UserName": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SessionList": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Session"
+                    }
+                }
+            }
+        },
         "model.Subnet": {
             "type": "object",
             "properties": {
                 "BitMask": {
                     "type": "integer"
                 },
+                "Container": {
+                    "description": "Container is set for a block that holds other subnets rather than addresses. It has\nno gateway",
+                    "type": "boolean"
+                },
                 "CreationDate": {
                     "type": "string"
                 },
@@ -1222,101 +3297,206 @@ const docTemplate = `{
                 },
                 "NetworkPrefix": {
                     "type": "string"
+                },
+                "ParentId": {
+                    "type": "integer"
                 }
             }
         },
-        "model.SubnetUpdate": {
+        "model.SubnetList": {
             "type": "object",
             "properties": {
-                "BitMask": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Subnet"
+                    }
+                },
+                "limit": {
                     "type": "integer"
                 },
-                "DomainName": {
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.SubnetMatch": {
+            "type": "object",
+            "properties": {
+                "Address": {
                     "type": "string"
                 },
-                "GatewayAddress": {
+                "Assignment": {
+                    "$ref": "#/definitions/model.Address"
+                },
+                "AssignmentState": {
                     "type": "string"
                 },
-                "NetworkPrefix": {
+                "Domain": {
+                    "$ref": "#/definitions/model.Domain"
+                },
+                "HostName": {
                     "type": "string"
+                },
+                "Subnet": {
+                    "$ref": "#/definitions/model.Subnet"
                 }
             }
         },
-        "model.Subnets": {
+        "model.SubnetTree": {
+            "type": "object",
+            "properties": {
+                "Children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SubnetTree"
+                    }
+                },
+                "Subnet": {
+                    "$ref": "#/definitions/model.Subnet"
+                },
+                "Utilisation": {
+                    "$ref": "#/definitions/model.SubnetUtilisation"
+                }
+            }
+        },
+        "model.SubnetTrees": {
             "type": "object",
             "properties": {
                 "data": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.Subnet"
+                        "$ref": "#/definitions/model.SubnetTree"
                     }
                 }
             }
         },
-        "model.SuccessMsg": {
+        "model.SubnetUpdate": {
             "type": "object",
             "properties": {
-                "message": {
+                "BitMask": {
+                    "type": "integer"
+                },
+                "Container": {
+                    "type": "boolean"
+                },
+                "DomainName": {
+                    "type": "string"
+                },
+                "GatewayAddress": {
                     "type": "string"
+                },
+                "NetworkPrefix": {
+                    "type": "string"
+                },
+                "ParentId": {
+                    "type": "integer"
                 }
             }
         },
-        "model.User": {
+        "model.SubnetUtilisation": {
             "type": "object",
             "properties": {
-                "CreationDate": {
+                "Allocated": {
+                    "description": "Allocated is the addresses given over to subnets: all of a subnet's block, and the\nblocks of the subnets nested in a container",
                     "type": "string"
                 },
-                "Id": {
-                    "type": "integer"
+                "AllocatedPercent": {
+                    "type": "number"
                 },
-                "LastChangedDate": {
+                "Assigned": {
                     "type": "string"
                 },
-                "PasswordHash": {
-                    "type": "string"
+                "AssignedPercent": {
+                    "type": "number"
                 },
-                "Status": {
+                "Size": {
+                    "description": "Size is every address in the block",
                     "type": "string"
                 },
-                "UserName": {
+                "Usable": {
+                    "description": "Usable is the addresses that can be assigned, leaving out gateways and the network,\nbroadcast and anycast addresses",
                     "type": "string"
                 }
             }
         },
-        "model.UserStatusMsg": {
+        "model.Subnets": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Subnet"
+                    }
+                }
+            }
+        },
+        "model.SuccessMsg": {
             "type": "object",
             "properties": {
                 "message": {
                     "type": "string"
-                },
-                "userStatus": {
-                    "type": "string"
                 }
             }
         },
-        "model.UsersList": {
+        "model.UnassignedAddressList": {
             "type": "object",
             "properties": {
                 "data": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.User"
+                        "type": "string"
                     }
                 }
             }
+        },
+        "model.UserRole": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UserStatus": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UserStatusMsg": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "userStatus": {
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
         "BasicAuth": {
             "type": "basic"
+        },
+        "BearerAuth": {
+            "description": "API token, sent as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
-	Version:          "0.0.8",
+	Version:          "0.1.0",
 	Host:             "localhost:8000",
 	BasePath:         "/api/v1",
 	Schemes:          []string{},
@@ -0,0 +1,89 @@
+package generators
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// RenderDhcpd renders an ISC dhcpd.conf fragment with a subnet block per IPv4 subnet,
+// holding a host declaration for every MAC address of each host assigned an address in
+// it. IPv6 subnets are skipped, as they belong in a separate dhcpd -6 configuration
+func RenderDhcpd(inv Inventory) (string, error) {
+	log.Println("INFO: Rendering dhcpd configuration")
+	var b strings.Builder
+	b.WriteString("# Generated by IpManager. Local changes will be overwritten\n")
+
+	for _, subnet := range inv.Subnets {
+		block, err := prefixBlock(subnet)
+		if err != nil {
+			log.Println("ERROR: Unable to parse prefix of subnet " + subnet.NetworkName)
+			return "", err
+		}
+		if !block.IsIPv4() {
+			continue
+		}
+
+		netmask := block.GetNetworkMask().WithoutPrefixLen().String()
+		fmt.Fprintf(&b, "\n# %s\n", subnet.NetworkName)
+		fmt.Fprintf(&b, "subnet %s netmask %s {\n", block.GetLower().WithoutPrefixLen().String(), netmask)
+		if subnet.GatewayAddress != "" {
+			fmt.Fprintf(&b, "  option routers %s;\n", subnet.GatewayAddress)
+		}
+		fmt.Fprintf(&b, "  option subnet-mask %s;\n", netmask)
+		if domain, ok := inv.Domains[subnet.DomainId]; ok {
+			fmt.Fprintf(&b, "  option domain-name \"%s\";\n", domain.DomainName)
+		}
+
+		// collect each host's addresses in this subnet, keeping the order hosts first appear in
+		hostOrder := make([]int, 0)
+		hostAddresses := make(map[int][]string)
+		for _, address := range inv.AddressesInSubnet(subnet.Id) {
+			if _, seen := hostAddresses[address.HostNameId]; !seen {
+				hostOrder = append(hostOrder, address.HostNameId)
+			}
+			hostAddresses[address.HostNameId] = append(hostAddresses[address.HostNameId], address.Address)
+		}
+
+		for _, hostId := range hostOrder {
+			host, ok := inv.Hosts[hostId]
+			if !ok {
+				continue
+			}
+			for idx, mac := range host.MacAddresses {
+				// host declarations must have unique names, so number them when a host has
+				// more than one interface
+				name := host.HostName
+				if len(host.MacAddresses) > 1 {
+					name = host.HostName + "-" + strconv.Itoa(idx)
+				}
+				fmt.Fprintf(&b, "\n  host %s {\n", name)
+				fmt.Fprintf(&b, "    hardware ethernet %s;\n", strings.ToLower(mac))
+				fmt.Fprintf(&b, "    fixed-address %s;\n", strings.Join(hostAddresses[hostId], ", "))
+				b.WriteString("  }\n")
+			}
+		}
+		b.WriteString("}\n")
+	}
+
+	return b.String(), nil
+}
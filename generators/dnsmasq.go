@@ -0,0 +1,127 @@
+package generators
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+
+	"github.com/greeneg/ipmanager/model"
+)
+
+// RenderDnsmasq renders a dnsmasq.d snippet for a site's domain: a static dhcp-range per
+// subnet in the domain, a dhcp-host line for each host assigned an address in those subnets,
+// and a host-record for every address assigned in the domain
+func RenderDnsmasq(inv Inventory, domain model.Domain) (string, error) {
+	log.Println("INFO: Rendering dnsmasq configuration for domain " + domain.DomainName)
+	var b strings.Builder
+	b.WriteString("# Generated by IpManager. Local changes will be overwritten\n")
+	fmt.Fprintf(&b, "domain=%s\n", strings.TrimSuffix(domain.DomainName, "."))
+
+	// dnsmasq takes a single IPv4 and a single IPv6 address per dhcp-host, so keep the
+	// first of each a host has, in the order hosts first appear
+	hostOrder := make([]int, 0)
+	hostIpv4 := make(map[int]string)
+	hostIpv6 := make(map[int]string)
+	for _, subnet := range inv.Subnets {
+		if subnet.DomainId != domain.Id {
+			continue
+		}
+		block, err := prefixBlock(subnet)
+		if err != nil {
+			log.Println("ERROR: Unable to parse prefix of subnet " + subnet.NetworkName)
+			return "", err
+		}
+
+		fmt.Fprintf(&b, "\n# %s\n", subnet.NetworkName)
+		network := block.GetLower().WithoutPrefixLen().String()
+		if block.IsIPv6() {
+			fmt.Fprintf(&b, "dhcp-range=set:%s,%s,static,%s\n", subnet.NetworkName, network, strconv.Itoa(subnet.BitMask))
+		} else {
+			netmask := block.GetNetworkMask().WithoutPrefixLen().String()
+			fmt.Fprintf(&b, "dhcp-range=set:%s,%s,static,%s\n", subnet.NetworkName, network, netmask)
+			if subnet.GatewayAddress != "" {
+				fmt.Fprintf(&b, "dhcp-option=tag:%s,option:router,%s\n", subnet.NetworkName, subnet.GatewayAddress)
+			}
+		}
+
+		for _, address := range inv.AddressesInSubnet(subnet.Id) {
+			if _, ok := inv.Hosts[address.HostNameId]; !ok {
+				continue
+			}
+			_, hasIpv4 := hostIpv4[address.HostNameId]
+			_, hasIpv6 := hostIpv6[address.HostNameId]
+			if !hasIpv4 && !hasIpv6 {
+				hostOrder = append(hostOrder, address.HostNameId)
+			}
+			if block.IsIPv6() {
+				if !hasIpv6 {
+					hostIpv6[address.HostNameId] = address.Address
+				}
+			} else if !hasIpv4 {
+				hostIpv4[address.HostNameId] = address.Address
+			}
+		}
+	}
+
+	if len(hostOrder) > 0 {
+		b.WriteString("\n")
+	}
+	for _, hostId := range hostOrder {
+		host := inv.Hosts[hostId]
+		if len(host.MacAddresses) == 0 {
+			continue
+		}
+		fields := make([]string, 0)
+		for _, mac := range host.MacAddresses {
+			fields = append(fields, strings.ToLower(mac))
+		}
+		if address, ok := hostIpv4[hostId]; ok {
+			fields = append(fields, address)
+		}
+		if address, ok := hostIpv6[hostId]; ok {
+			fields = append(fields, "["+address+"]")
+		}
+		fields = append(fields, host.HostName)
+		fmt.Fprintf(&b, "dhcp-host=%s\n", strings.Join(fields, ","))
+	}
+
+	addresses := inv.AddressesInDomain(domain.Id)
+	if len(addresses) > 0 {
+		b.WriteString("\n")
+	}
+	for _, address := range addresses {
+		host, ok := inv.Hosts[address.HostNameId]
+		if !ok {
+			continue
+		}
+		if ipaddr.NewIPAddressString(address.Address).GetAddress() == nil {
+			log.Println("ERROR: Skipping unparseable address " + address.Address)
+			continue
+		}
+		name := strings.TrimSuffix(hostFqdn(host.HostName, domain.DomainName), ".")
+		fmt.Fprintf(&b, "host-record=%s,%s\n", name, address.Address)
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,130 @@
+package generators
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+
+	"github.com/greeneg/ipmanager/model"
+)
+
+// Inventory is a snapshot of everything the generators need, keyed so that assignments can
+// be joined back to their hosts, domains and subnets without further queries
+type Inventory struct {
+	Subnets   []model.Subnet
+	Domains   map[int]model.Domain
+	Hosts     map[int]model.Host
+	Addresses []model.Address
+}
+
+func LoadInventory() (Inventory, error) {
+	log.Println("INFO: Loading inventory for configuration generation")
+	inv := Inventory{
+		Domains: make(map[int]model.Domain),
+		Hosts:   make(map[int]model.Host),
+	}
+
+	subnets, _, err := model.Repo.GetSubnets(model.ListOptions{})
+	if err != nil {
+		log.Println("ERROR: Failed to load subnets")
+		return Inventory{}, err
+	}
+	// container blocks aren't networks hosts sit on, only the subnets nested in them are
+	for _, subnet := range subnets {
+		if !subnet.Container {
+			inv.Subnets = append(inv.Subnets, subnet)
+		}
+	}
+
+	domains, _, err := model.Repo.GetDomains(model.ListOptions{})
+	if err != nil {
+		log.Println("ERROR: Failed to load domains")
+		return Inventory{}, err
+	}
+	for _, domain := range domains {
+		inv.Domains[domain.Id] = domain
+	}
+
+	hosts, _, err := model.Repo.GetHosts(model.ListOptions{})
+	if err != nil {
+		log.Println("ERROR: Failed to load hosts")
+		return Inventory{}, err
+	}
+	for _, host := range hosts {
+		inv.Hosts[host.Id] = host
+	}
+
+	addresses, _, err := model.Repo.GetAddresses(model.ListOptions{})
+	if err != nil {
+		log.Println("ERROR: Failed to load addresses")
+		return Inventory{}, err
+	}
+	inv.Addresses = addresses
+
+	log.Println("INFO: Inventory loaded with " + strconv.Itoa(len(inv.Addresses)) + " assigned addresses")
+	return inv, nil
+}
+
+// AddressesInSubnet returns the subnet's assigned addresses in ascending address order
+func (inv Inventory) AddressesInSubnet(subnetId int) []model.Address {
+	addresses := make([]model.Address, 0)
+	for _, address := range inv.Addresses {
+		if address.SubnetId == subnetId {
+			addresses = append(addresses, address)
+		}
+	}
+	sortAddresses(addresses)
+	return addresses
+}
+
+// AddressesInDomain returns the domain's assigned addresses in ascending address order
+func (inv Inventory) AddressesInDomain(domainId int) []model.Address {
+	addresses := make([]model.Address, 0)
+	for _, address := range inv.Addresses {
+		if address.DomainId == domainId {
+			addresses = append(addresses, address)
+		}
+	}
+	sortAddresses(addresses)
+	return addresses
+}
+
+func sortAddresses(addresses []model.Address) {
+	sort.SliceStable(addresses, func(i, j int) bool {
+		a := ipaddr.NewIPAddressString(addresses[i].Address).GetAddress()
+		b := ipaddr.NewIPAddressString(addresses[j].Address).GetAddress()
+		if a == nil || b == nil {
+			return addresses[i].Address < addresses[j].Address
+		}
+		return a.Compare(b) < 0
+	})
+}
+
+// prefixBlock returns the subnet as a prefix block, e.g. 10.0.0.0/24
+func prefixBlock(s model.Subnet) (*ipaddr.IPAddress, error) {
+	addr, err := ipaddr.NewIPAddressString(s.NetworkPrefix + "/" + strconv.Itoa(s.BitMask)).ToAddress()
+	if err != nil {
+		return nil, err
+	}
+	return addr.ToPrefixBlock(), nil
+}
@@ -0,0 +1,176 @@
+package generators
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"log"
+	"strings"
+
+	"github.com/greeneg/ipmanager/model"
+)
+
+type KeaOptionData struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+type KeaReservation struct {
+	HwAddress   string   `json:"hw-address"`
+	IpAddress   string   `json:"ip-address,omitempty"`
+	IpAddresses []string `json:"ip-addresses,omitempty"`
+	Hostname    string   `json:"hostname,omitempty"`
+}
+
+type KeaSubnet struct {
+	Id           int               `json:"id"`
+	Subnet       string            `json:"subnet"`
+	OptionData   []KeaOptionData   `json:"option-data,omitempty"`
+	Reservations []KeaReservation  `json:"reservations"`
+	UserContext  map[string]string `json:"user-context,omitempty"`
+}
+
+type KeaDhcp4 struct {
+	Subnet4 []KeaSubnet `json:"subnet4"`
+}
+
+type KeaDhcp6 struct {
+	Subnet6 []KeaSubnet `json:"subnet6"`
+}
+
+type KeaDhcp4Config struct {
+	Dhcp4 KeaDhcp4 `json:"Dhcp4"`
+}
+
+type KeaDhcp6Config struct {
+	Dhcp6 KeaDhcp6 `json:"Dhcp6"`
+}
+
+// keaSubnets builds the subnet entries for one address family. Subnet ids are the subnet's
+// own Id, so they stay stable however the list is ordered or filtered
+func keaSubnets(inv Inventory, ipv6 bool) ([]KeaSubnet, error) {
+	subnets := make([]KeaSubnet, 0)
+	for _, subnet := range inv.Subnets {
+		block, err := prefixBlock(subnet)
+		if err != nil {
+			log.Println("ERROR: Unable to parse prefix of subnet " + subnet.NetworkName)
+			return nil, err
+		}
+		if block.IsIPv6() != ipv6 {
+			continue
+		}
+
+		entry := KeaSubnet{
+			Id:           subnet.Id,
+			Subnet:       block.String(),
+			OptionData:   make([]KeaOptionData, 0),
+			Reservations: make([]KeaReservation, 0),
+			UserContext:  map[string]string{"network-name": subnet.NetworkName},
+		}
+		domain, hasDomain := inv.Domains[subnet.DomainId]
+		if ipv6 {
+			if hasDomain {
+				entry.OptionData = append(entry.OptionData, KeaOptionData{Name: "domain-search", Data: domain.DomainName})
+			}
+		} else {
+			if subnet.GatewayAddress != "" {
+				entry.OptionData = append(entry.OptionData, KeaOptionData{Name: "routers", Data: subnet.GatewayAddress})
+			}
+			if hasDomain {
+				entry.OptionData = append(entry.OptionData, KeaOptionData{Name: "domain-name", Data: domain.DomainName})
+			}
+		}
+
+		// group the subnet's addresses by host, keeping the order hosts first appear in
+		hostOrder := make([]int, 0)
+		hostAddresses := make(map[int][]string)
+		for _, address := range inv.AddressesInSubnet(subnet.Id) {
+			if _, seen := hostAddresses[address.HostNameId]; !seen {
+				hostOrder = append(hostOrder, address.HostNameId)
+			}
+			hostAddresses[address.HostNameId] = append(hostAddresses[address.HostNameId], address.Address)
+		}
+
+		for _, hostId := range hostOrder {
+			host, ok := inv.Hosts[hostId]
+			if !ok {
+				continue
+			}
+			entry.Reservations = append(entry.Reservations, keaReservations(host, hostAddresses[hostId], ipv6)...)
+		}
+
+		subnets = append(subnets, entry)
+	}
+
+	return subnets, nil
+}
+
+// keaReservations pairs a host's MAC addresses with its addresses in order, as Kea won't
+// accept the same address reserved for two hardware addresses in one subnet. DHCPv6
+// reservations can hold several addresses, so there the last MAC address takes whatever is
+// left over; DHCPv4 ones can't, so surplus addresses are left out
+func keaReservations(host model.Host, addresses []string, ipv6 bool) []KeaReservation {
+	reservations := make([]KeaReservation, 0)
+	for idx, mac := range host.MacAddresses {
+		if idx >= len(addresses) {
+			break
+		}
+
+		reservation := KeaReservation{
+			HwAddress: strings.ToLower(mac),
+			Hostname:  host.HostName,
+		}
+		if ipv6 {
+			if idx == len(host.MacAddresses)-1 {
+				reservation.IpAddresses = addresses[idx:]
+			} else {
+				reservation.IpAddresses = addresses[idx : idx+1]
+			}
+		} else {
+			reservation.IpAddress = addresses[idx]
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	if !ipv6 && len(addresses) > len(host.MacAddresses) {
+		log.Println("WARN: Host " + host.HostName + " has more addresses than MAC addresses. Surplus addresses have no reservation")
+	}
+	return reservations
+}
+
+// RenderKeaDhcp4 builds the Dhcp4 subnet4 configuration, with a reservation for each of the
+// host MAC addresses that have an address assigned in the subnet
+func RenderKeaDhcp4(inv Inventory) (KeaDhcp4Config, error) {
+	log.Println("INFO: Rendering Kea DHCPv4 configuration")
+	subnets, err := keaSubnets(inv, false)
+	if err != nil {
+		return KeaDhcp4Config{}, err
+	}
+	return KeaDhcp4Config{Dhcp4: KeaDhcp4{Subnet4: subnets}}, nil
+}
+
+// RenderKeaDhcp6 builds the Dhcp6 subnet6 configuration, with a reservation for each of the
+// host MAC addresses that have an address assigned in the subnet
+func RenderKeaDhcp6(inv Inventory) (KeaDhcp6Config, error) {
+	log.Println("INFO: Rendering Kea DHCPv6 configuration")
+	subnets, err := keaSubnets(inv, true)
+	if err != nil {
+		return KeaDhcp6Config{}, err
+	}
+	return KeaDhcp6Config{Dhcp6: KeaDhcp6{Subnet6: subnets}}, nil
+}
@@ -0,0 +1,195 @@
+package generators
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// ReverseZone is a single reverse zone file. Delegation is only set for RFC 2317 classless
+// zones, and holds the records the operator of the parent zone needs to add
+type ReverseZone struct {
+	ZoneName   string `json:"ZoneName"`
+	Zone       string `json:"Zone"`
+	Delegation string `json:"Delegation,omitempty"`
+}
+
+type ReverseZoneList struct {
+	Data []ReverseZone `json:"data"`
+}
+
+// reverseZoneBlocks splits a subnet into the blocks that each get a reverse zone. Reverse
+// zones can only be cut on label boundaries, octets for IPv4 and nibbles for IPv6, so an
+// unaligned prefix is split into the aligned blocks beneath it. IPv4 prefixes longer than
+// a /24 are the exception, being served as a single RFC 2317 classless zone instead
+func reverseZoneBlocks(block *ipaddr.IPAddress) []*ipaddr.IPAddress {
+	bits := int(block.GetPrefixLen().Len())
+	unit := 8
+	if block.IsIPv6() {
+		unit = 4
+	}
+	if bits%unit == 0 || (block.IsIPv4() && bits > 24) {
+		return []*ipaddr.IPAddress{block}
+	}
+
+	aligned := (bits/unit + 1) * unit
+	blocks := make([]*ipaddr.IPAddress, 0)
+	iterator := block.SetPrefixLen(ipaddr.BitCount(aligned)).PrefixBlockIterator()
+	for next := iterator.Next(); next != nil; next = iterator.Next() {
+		blocks = append(blocks, next)
+	}
+	return blocks
+}
+
+// reverseLabels returns the labels of an address in reverse DNS order, octets for IPv4 and
+// nibbles for IPv6, least significant first
+func reverseLabels(addr *ipaddr.IPAddress) []string {
+	bytes := addr.GetLower().Bytes()
+	labels := make([]string, 0)
+	for idx := len(bytes) - 1; idx >= 0; idx-- {
+		if addr.IsIPv6() {
+			labels = append(labels, strconv.FormatUint(uint64(bytes[idx]&0x0f), 16))
+			labels = append(labels, strconv.FormatUint(uint64(bytes[idx]>>4), 16))
+		} else {
+			labels = append(labels, strconv.Itoa(int(bytes[idx])))
+		}
+	}
+	return labels
+}
+
+func reverseSuffix(block *ipaddr.IPAddress) string {
+	if block.IsIPv6() {
+		return "ip6.arpa"
+	}
+	return "in-addr.arpa"
+}
+
+func isClassless(block *ipaddr.IPAddress) bool {
+	return block.IsIPv4() && int(block.GetPrefixLen().Len()) > 24
+}
+
+// classlessLabel is the RFC 2317 label for a block, e.g. 64/26
+func classlessLabel(block *ipaddr.IPAddress) string {
+	bytes := block.GetLower().Bytes()
+	return strconv.Itoa(int(bytes[3])) + "/" + strconv.Itoa(int(block.GetPrefixLen().Len()))
+}
+
+// reverseZoneName works out the zone name of an aligned block, and how many of an address'
+// reversed labels fall under the zone's origin
+func reverseZoneName(block *ipaddr.IPAddress) (string, int) {
+	labels := reverseLabels(block)
+	if isClassless(block) {
+		parent := strings.Join(labels[1:], ".") + "." + reverseSuffix(block)
+		return classlessLabel(block) + "." + parent, 1
+	}
+
+	unit := 8
+	if block.IsIPv6() {
+		unit = 4
+	}
+	hostLabels := len(labels) - int(block.GetPrefixLen().Len())/unit
+	if hostLabels == len(labels) {
+		return reverseSuffix(block), hostLabels
+	}
+	return strings.Join(labels[hostLabels:], ".") + "." + reverseSuffix(block), hostLabels
+}
+
+// renderClasslessDelegation renders the NS and CNAME records that delegate a classless
+// block from its parent /24 zone, per RFC 2317
+func renderClasslessDelegation(block *ipaddr.IPAddress, dns globals.DnsConfig, domainName string) string {
+	var b strings.Builder
+	label := classlessLabel(block)
+	labels := reverseLabels(block)
+	parent := strings.Join(labels[1:], ".") + "." + reverseSuffix(block)
+
+	fmt.Fprintf(&b, "; RFC 2317 delegation of %s, to be added to the %s zone\n", block.String(), parent)
+	_, servers := nameServers(dns, domainName)
+	for _, ns := range servers {
+		fmt.Fprintf(&b, "%s\tIN\tNS\t%s\n", label, ns)
+	}
+
+	// the network and broadcast addresses don't need a pointer unless the block is a /31 or /32
+	first := int(block.GetLower().Bytes()[3])
+	last := int(block.GetUpper().Bytes()[3])
+	if last-first > 1 {
+		first++
+		last--
+	}
+	for octet := first; octet <= last; octet++ {
+		fmt.Fprintf(&b, "%d\tIN\tCNAME\t%d.%s\n", octet, octet, label)
+	}
+
+	return b.String()
+}
+
+// RenderReverseZones renders the reverse zones of a subnet with a PTR record for every
+// address assigned in it, pointing at the assigned host's fully qualified name
+func RenderReverseZones(inv Inventory, subnet model.Subnet, dns globals.DnsConfig, serial uint32) ([]ReverseZone, error) {
+	log.Println("INFO: Rendering reverse zones for subnet " + subnet.NetworkName)
+	block, err := prefixBlock(subnet)
+	if err != nil {
+		log.Println("ERROR: Unable to parse prefix of subnet " + subnet.NetworkName)
+		return nil, err
+	}
+
+	// server and mailbox defaults come from the subnet's own domain
+	domainName := inv.Domains[subnet.DomainId].DomainName
+	addresses := inv.AddressesInSubnet(subnet.Id)
+
+	zones := make([]ReverseZone, 0)
+	for _, zoneBlock := range reverseZoneBlocks(block) {
+		zoneName, hostLabels := reverseZoneName(zoneBlock)
+
+		var b strings.Builder
+		writeZoneHeader(&b, zoneName, domainName, dns, serial)
+		for _, address := range addresses {
+			addr := ipaddr.NewIPAddressString(address.Address).GetAddress()
+			if addr == nil || !zoneBlock.Contains(addr) {
+				continue
+			}
+			host, ok := inv.Hosts[address.HostNameId]
+			if !ok {
+				continue
+			}
+
+			owner := strings.Join(reverseLabels(addr)[:hostLabels], ".")
+			target := hostFqdn(host.HostName, inv.Domains[address.DomainId].DomainName)
+			fmt.Fprintf(&b, "%s\tIN\tPTR\t%s\n", owner, target)
+		}
+
+		zone := ReverseZone{
+			ZoneName: zoneName,
+			Zone:     b.String(),
+		}
+		if isClassless(zoneBlock) {
+			zone.Delegation = renderClasslessDelegation(zoneBlock, dns, domainName)
+		}
+		zones = append(zones, zone)
+	}
+
+	return zones, nil
+}
@@ -0,0 +1,151 @@
+package generators
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/greeneg/ipmanager/model"
+)
+
+func TestReverseZones(t *testing.T) {
+	type zone struct {
+		name       string
+		hostLabels int
+	}
+	tests := []struct {
+		name    string
+		prefix  string
+		bitmask int
+		want    []zone
+	}{
+		{
+			name:    "IPv4 /24",
+			prefix:  "10.1.2.0",
+			bitmask: 24,
+			want:    []zone{{"2.1.10.in-addr.arpa", 1}},
+		},
+		{
+			name:    "IPv4 /8",
+			prefix:  "10.0.0.0",
+			bitmask: 8,
+			want:    []zone{{"10.in-addr.arpa", 3}},
+		},
+		{
+			name:    "IPv4 /0",
+			prefix:  "0.0.0.0",
+			bitmask: 0,
+			want:    []zone{{"in-addr.arpa", 4}},
+		},
+		{
+			name:    "RFC 2317 /25",
+			prefix:  "10.1.2.128",
+			bitmask: 25,
+			want:    []zone{{"128/25.2.1.10.in-addr.arpa", 1}},
+		},
+		{
+			name:    "RFC 2317 /26",
+			prefix:  "10.1.2.64",
+			bitmask: 26,
+			want:    []zone{{"64/26.2.1.10.in-addr.arpa", 1}},
+		},
+		{
+			name:    "RFC 2317 /31",
+			prefix:  "10.1.2.6",
+			bitmask: 31,
+			want:    []zone{{"6/31.2.1.10.in-addr.arpa", 1}},
+		},
+		{
+			name:    "RFC 2317 /32",
+			prefix:  "10.1.2.7",
+			bitmask: 32,
+			want:    []zone{{"7/32.2.1.10.in-addr.arpa", 1}},
+		},
+		{
+			name:    "unaligned IPv4 /23 splits into two /24s",
+			prefix:  "10.1.0.0",
+			bitmask: 23,
+			want: []zone{
+				{"0.1.10.in-addr.arpa", 1},
+				{"1.1.10.in-addr.arpa", 1},
+			},
+		},
+		{
+			name:    "unaligned IPv4 /14 splits into four /16s",
+			prefix:  "10.4.0.0",
+			bitmask: 14,
+			want: []zone{
+				{"4.10.in-addr.arpa", 2},
+				{"5.10.in-addr.arpa", 2},
+				{"6.10.in-addr.arpa", 2},
+				{"7.10.in-addr.arpa", 2},
+			},
+		},
+		{
+			name:    "IPv6 /32",
+			prefix:  "2001:db8::",
+			bitmask: 32,
+			want:    []zone{{"8.b.d.0.1.0.0.2.ip6.arpa", 24}},
+		},
+		{
+			name:    "IPv6 /64",
+			prefix:  "2001:db8:0:10::",
+			bitmask: 64,
+			want:    []zone{{"0.1.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", 16}},
+		},
+		{
+			name:    "unaligned IPv6 /31 splits into two /32s",
+			prefix:  "2001:db8::",
+			bitmask: 31,
+			want: []zone{
+				{"8.b.d.0.1.0.0.2.ip6.arpa", 24},
+				{"9.b.d.0.1.0.0.2.ip6.arpa", 24},
+			},
+		},
+		{
+			name:    "unaligned IPv6 /62 splits into four /64s",
+			prefix:  "2001:db8:0:10::",
+			bitmask: 62,
+			want: []zone{
+				{"0.1.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", 16},
+				{"1.1.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", 16},
+				{"2.1.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", 16},
+				{"3.1.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", 16},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := prefixBlock(model.Subnet{NetworkPrefix: tt.prefix, BitMask: tt.bitmask})
+			if err != nil {
+				t.Fatalf("prefixBlock: %v", err)
+			}
+			got := make([]zone, 0)
+			for _, zoneBlock := range reverseZoneBlocks(block) {
+				name, hostLabels := reverseZoneName(zoneBlock)
+				got = append(got, zone{name, hostLabels})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("zones = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package generators
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// fqdn makes sure a name is absolute
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// relativeName returns a host name relative to the zone's origin. Names that are already
+// fully qualified inside the zone have the origin stripped, while names that are fully
+// qualified elsewhere are kept absolute
+func relativeName(hostName string, origin string) string {
+	origin = strings.TrimSuffix(origin, ".")
+	name := strings.TrimSuffix(hostName, ".")
+	if strings.EqualFold(name, origin) {
+		return "@"
+	}
+	if strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(origin)) {
+		return name[:len(name)-len(origin)-1]
+	}
+	if strings.HasSuffix(hostName, ".") {
+		return hostName
+	}
+	return name
+}
+
+// hostFqdn returns the absolute name of a host within a domain, without doubling up the
+// domain if the host name already carries it
+func hostFqdn(hostName string, domainName string) string {
+	if strings.HasSuffix(hostName, ".") || domainName == "" {
+		return fqdn(hostName)
+	}
+	domainName = strings.TrimSuffix(domainName, ".")
+	if strings.HasSuffix(strings.ToLower(hostName), "."+strings.ToLower(domainName)) {
+		return fqdn(hostName)
+	}
+	return hostName + "." + fqdn(domainName)
+}
+
+// nameServers returns the primary name server and the full NS set from the DNS settings,
+// defaulting to ns1 within the given domain
+func nameServers(dns globals.DnsConfig, domainName string) (string, []string) {
+	servers := dns.NameServers
+	primary := dns.PrimaryNameServer
+	if primary == "" {
+		if len(servers) > 0 {
+			primary = servers[0]
+		} else {
+			primary = "ns1." + fqdn(domainName)
+		}
+	}
+	if len(servers) == 0 {
+		servers = []string{primary}
+	}
+
+	absolute := make([]string, 0, len(servers))
+	for _, server := range servers {
+		absolute = append(absolute, fqdn(server))
+	}
+	return fqdn(primary), absolute
+}
+
+// writeZoneHeader writes the $ORIGIN, $TTL, SOA and NS records for a zone. Any unset DNS
+// settings are filled with defaults, with server and mailbox names placed in domainName
+func writeZoneHeader(b *strings.Builder, origin string, domainName string, dns globals.DnsConfig, serial uint32) {
+	ttl := dns.Ttl
+	if ttl == 0 {
+		ttl = 3600
+	}
+	refresh := dns.Refresh
+	if refresh == 0 {
+		refresh = 3600
+	}
+	retry := dns.Retry
+	if retry == 0 {
+		retry = 900
+	}
+	expire := dns.Expire
+	if expire == 0 {
+		expire = 1209600
+	}
+	negativeTtl := dns.NegativeTtl
+	if negativeTtl == 0 {
+		negativeTtl = 300
+	}
+
+	primary, servers := nameServers(dns, domainName)
+
+	// the SOA RNAME is a mailbox written as a domain name
+	hostMaster := strings.Replace(dns.HostMaster, "@", ".", 1)
+	if hostMaster == "" {
+		hostMaster = "hostmaster." + fqdn(domainName)
+	}
+
+	b.WriteString("; Generated by IpManager. Local changes will be overwritten\n")
+	fmt.Fprintf(b, "$ORIGIN %s\n", fqdn(origin))
+	fmt.Fprintf(b, "$TTL %d\n", ttl)
+	fmt.Fprintf(b, "@\tIN\tSOA\t%s %s (\n", primary, fqdn(hostMaster))
+	fmt.Fprintf(b, "\t\t\t%d\t; serial\n", serial)
+	fmt.Fprintf(b, "\t\t\t%d\t\t; refresh\n", refresh)
+	fmt.Fprintf(b, "\t\t\t%d\t\t; retry\n", retry)
+	fmt.Fprintf(b, "\t\t\t%d\t\t; expire\n", expire)
+	fmt.Fprintf(b, "\t\t\t%d )\t\t; negative caching TTL\n", negativeTtl)
+	for _, ns := range servers {
+		fmt.Fprintf(b, "\tIN\tNS\t%s\n", ns)
+	}
+	b.WriteString("\n")
+}
+
+// RenderForwardZone renders a BIND zone file for a domain with an A or AAAA record for
+// every address assigned within it, owned by the assigned host's name
+func RenderForwardZone(inv Inventory, domain model.Domain, dns globals.DnsConfig, serial uint32) (string, error) {
+	log.Println("INFO: Rendering forward zone for " + domain.DomainName)
+	if domain.DomainName == "" {
+		return "", errors.New("domain has no name")
+	}
+
+	var b strings.Builder
+	writeZoneHeader(&b, domain.DomainName, domain.DomainName, dns, serial)
+
+	for _, address := range inv.AddressesInDomain(domain.Id) {
+		host, ok := inv.Hosts[address.HostNameId]
+		if !ok {
+			continue
+		}
+		addr := ipaddr.NewIPAddressString(address.Address).GetAddress()
+		if addr == nil {
+			log.Println("WARN: Skipping unparsable address " + address.Address)
+			continue
+		}
+
+		recordType := "A"
+		if addr.IsIPv6() {
+			recordType = "AAAA"
+		}
+		fmt.Fprintf(&b, "%s\tIN\t%s\t%s\n", relativeName(host.HostName, domain.DomainName), recordType, addr.WithoutPrefixLen().String())
+	}
+
+	return b.String(), nil
+}
@@ -18,6 +18,10 @@ package globals
 
 */
 
-var Secret = []byte("secret")
-
 const UserKey = "user"
+
+const SessionSecretsEnv = "IPMANAGER_SESSION_SECRETS"
+
+const RoleKey = "role"
+
+const ScopesKey = "scopes"
@@ -19,10 +19,98 @@ package globals
 */
 
 type Config struct {
-	TcpPort    int    `json:"tcpPort"`
-	TLSTcpPort int    `json:"tlsTcpPort"`
-	TLSPemFile string `json:"tlsPemFile"`
-	TLSKeyFile string `json:"tlsKeyFile"`
-	DbPath     string `json:"dbPath"`
-	UseTLS     bool   `json:"useTls"`
+	TcpPort    int            `json:"tcpPort"`
+	TLSTcpPort int            `json:"tlsTcpPort"`
+	TLSPemFile string         `json:"tlsPemFile"`
+	TLSKeyFile string         `json:"tlsKeyFile"`
+	DbPath     string         `json:"dbPath"`
+	Database   DatabaseConfig `json:"database"`
+	UseTLS     bool           `json:"useTls"`
+	Dns        DnsConfig      `json:"dns"`
+	Auth       AuthConfig     `json:"auth"`
+	Session    SessionConfig  `json:"session"`
+}
+
+// DatabaseConfig selects the database backend. Driver is either sqlite, the default, or
+// postgres. For sqlite, Dsn is the path of the database file and defaults to DbPath; for
+// postgres it's a connection URL or keyword/value connection string
+type DatabaseConfig struct {
+	Driver string `json:"driver"`
+	Dsn    string `json:"dsn"`
+}
+
+// DnsConfig holds the SOA and NS settings used when generating zone files. Anything left
+// unset falls back to a sensible default derived from the zone's name
+type DnsConfig struct {
+	PrimaryNameServer string   `json:"primaryNameServer"`
+	HostMaster        string   `json:"hostMaster"`
+	NameServers       []string `json:"nameServers"`
+	Ttl               int      `json:"ttl"`
+	Refresh           int      `json:"refresh"`
+	Retry             int      `json:"retry"`
+	Expire            int      `json:"expire"`
+	NegativeTtl       int      `json:"negativeTtl"`
+}
+
+// AuthConfig picks the authentication backends, tried in order until one accepts the
+// credentials. With none configured only local accounts can log in
+type AuthConfig struct {
+	Backends []string   `json:"backends"`
+	Ldap     LdapConfig `json:"ldap"`
+	Oidc     OidcConfig `json:"oidc"`
+}
+
+// LdapConfig describes how to find and bind as a directory user. Either UserDnTemplate is
+// set, and the user's DN is built from it directly, or the user is searched for under
+// UserSearchBase with UserFilter, binding first as BindDn if the directory needs it. Both
+// templates take the user name in place of %s.
+//
+// GroupRoles maps group DNs to roles. When set, a user's role follows their most privileged
+// group on every login, and users in none of the groups get DefaultRole, or are refused if
+// that's empty. Without it, new users get DefaultRole, or read-only
+type LdapConfig struct {
+	Url                string            `json:"url"`
+	StartTLS           bool              `json:"startTls"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify"`
+	BindDn             string            `json:"bindDn"`
+	BindPassword       string            `json:"bindPassword"`
+	UserDnTemplate     string            `json:"userDnTemplate"`
+	UserSearchBase     string            `json:"userSearchBase"`
+	UserFilter         string            `json:"userFilter"`
+	GroupSearchBase    string            `json:"groupSearchBase"`
+	GroupFilter        string            `json:"groupFilter"`
+	GroupRoles         map[string]string `json:"groupRoles"`
+	DefaultRole        string            `json:"defaultRole"`
+}
+
+// OidcConfig enables the OpenID Connect login flow when Issuer is set. RedirectUrl must be
+// the public address of /api/v1/auth/oidc/callback, as registered with the provider.
+// UsernameClaim names the claim used as the local user name, preferred_username by default.
+// GroupsClaim, GroupRoles and DefaultRole work as they do for LDAP, with group names taken
+// from the claim. PostLoginRedirect, if set, is where the browser is sent once logged in
+type OidcConfig struct {
+	Issuer            string            `json:"issuer"`
+	ClientId          string            `json:"clientId"`
+	ClientSecret      string            `json:"clientSecret"`
+	RedirectUrl       string            `json:"redirectUrl"`
+	Scopes            []string          `json:"scopes"`
+	UsernameClaim     string            `json:"usernameClaim"`
+	GroupsClaim       string            `json:"groupsClaim"`
+	GroupRoles        map[string]string `json:"groupRoles"`
+	DefaultRole       string            `json:"defaultRole"`
+	PostLoginRedirect string            `json:"postLoginRedirect"`
+}
+
+// SessionConfig holds the session signing secrets and where sessions are kept. Secrets are
+// taken from the IPMANAGER_SESSION_SECRETS environment variable (comma separated), then
+// SecretFile (one per line), then Secrets. The first secret signs new sessions and the rest
+// are only used to check existing ones, so a secret can be rotated out without logging
+// everybody off. Store is either cookie, the default, or database to keep sessions server
+// side where they can be listed and revoked. MaxAge is in seconds
+type SessionConfig struct {
+	Secrets      []string `json:"secrets"`
+	SecretFile   string   `json:"secretFile"`
+	Store        string   `json:"store"`
+	MaxAge       int      `json:"maxAge"`
+	SecureCookie bool     `json:"secureCookie"`
 }
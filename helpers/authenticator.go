@@ -0,0 +1,148 @@
+package helpers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// Authenticator checks a user name and password against one identity backend. It returns
+// true only if the backend accepts the credentials and the user has a usable local account
+// afterwards, provisioning one if the backend is allowed to
+type Authenticator interface {
+	Name() string
+	Authenticate(username string, password string) (bool, error)
+}
+
+// authenticators are tried in order until one accepts the credentials
+var authenticators = []Authenticator{LocalAuthenticator{}}
+
+// ConfigureAuthenticators sets up the authentication backends named in the configuration.
+// With none named, only local accounts can log in
+func ConfigureAuthenticators(conf globals.AuthConfig) error {
+	if len(conf.Backends) == 0 {
+		authenticators = []Authenticator{LocalAuthenticator{}}
+		return nil
+	}
+
+	configured := make([]Authenticator, 0)
+	for _, backend := range conf.Backends {
+		switch backend {
+		case "local":
+			configured = append(configured, LocalAuthenticator{})
+		case "ldap":
+			ldapAuth, err := NewLdapAuthenticator(conf.Ldap)
+			if err != nil {
+				return err
+			}
+			configured = append(configured, ldapAuth)
+		default:
+			return errors.New("unknown authentication backend: " + backend)
+		}
+		log.Println("NOTICE: Enabled authentication backend: " + backend)
+	}
+
+	authenticators = configured
+	return nil
+}
+
+// roleForGroups picks the most privileged role any of an external user's groups map to,
+// falling back to defaultRole. The returned bool is false if the user should be refused
+func roleForGroups(groups []string, groupRoles map[string]string, defaultRole string) (string, bool) {
+	// model.Roles runs from most to least privileged, so the first match wins
+	for _, role := range model.Roles {
+		for _, group := range groups {
+			for mapped, groupRole := range groupRoles {
+				if groupRole == role && strings.EqualFold(group, mapped) {
+					return role, true
+				}
+			}
+		}
+	}
+
+	if defaultRole == "" {
+		return "", false
+	}
+	return defaultRole, true
+}
+
+func CheckUserPass(username, password string) bool {
+	if EmptyUserPass(username, password) {
+		return false
+	}
+
+	for _, authenticator := range authenticators {
+		ok, err := authenticator.Authenticate(username, password)
+		if err != nil {
+			// a backend being down shouldn't stop the next one being tried
+			log.Println("ERROR: " + authenticator.Name() + " authentication error for user '" + username + "': " + string(err.Error()))
+			continue
+		}
+		if ok {
+			log.Println("INFO: User '" + username + "' authenticated by " + authenticator.Name() + " backend")
+			return true
+		}
+	}
+
+	return false
+}
+
+// LocalAuthenticator checks passwords against the hashes in the Users table
+type LocalAuthenticator struct{}
+
+func (LocalAuthenticator) Name() string {
+	return "local"
+}
+
+func (LocalAuthenticator) Authenticate(username string, password string) (bool, error) {
+	user, err := model.Repo.GetUserByUserName(username)
+	if err != nil {
+		return false, err
+	}
+	if user.UserName == "" {
+		return false, nil
+	}
+
+	status := CheckIsNotLocked(user)
+	if !status {
+		return false, nil
+	}
+
+	// check the password against the user's stored hash
+	matches, needsRehash, err := model.VerifyPassword(password, user.PasswordHash)
+	if err != nil {
+		return false, err
+	}
+
+	// old or weak hashes are replaced while we have the plain text password to hand
+	if matches && needsRehash {
+		_, err = model.Repo.RehashPassword(username, password)
+		if err != nil {
+			// the user has still authenticated, so don't fail the login over it
+			log.Println("WARN: Unable to upgrade password hash for user '" + username + "': " + string(err.Error()))
+		}
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,196 @@
+package helpers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// LdapAuthenticator authenticates users by binding to a directory as them
+type LdapAuthenticator struct {
+	conf globals.LdapConfig
+}
+
+func NewLdapAuthenticator(conf globals.LdapConfig) (*LdapAuthenticator, error) {
+	if conf.Url == "" {
+		return nil, errors.New("ldap backend enabled without a url")
+	}
+	if conf.UserDnTemplate == "" && (conf.UserSearchBase == "" || conf.UserFilter == "") {
+		return nil, errors.New("ldap backend needs either userDnTemplate or both userSearchBase and userFilter")
+	}
+	if conf.DefaultRole != "" && !model.IsValidRole(conf.DefaultRole) {
+		return nil, errors.New("ldap defaultRole is not a valid role: " + conf.DefaultRole)
+	}
+	for group, role := range conf.GroupRoles {
+		if !model.IsValidRole(role) {
+			return nil, errors.New("ldap group " + group + " maps to an invalid role: " + role)
+		}
+	}
+	if len(conf.GroupRoles) > 0 && (conf.GroupSearchBase == "" || conf.GroupFilter == "") {
+		return nil, errors.New("ldap groupRoles need both groupSearchBase and groupFilter")
+	}
+
+	return &LdapAuthenticator{conf: conf}, nil
+}
+
+func (a *LdapAuthenticator) Name() string {
+	return "ldap"
+}
+
+func (a *LdapAuthenticator) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.conf.InsecureSkipVerify}
+	if u, err := url.Parse(a.conf.Url); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(a.conf.Url, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	if a.conf.StartTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// userDn works out the DN to bind as, either from the template or by searching for the user
+func (a *LdapAuthenticator) userDn(conn *ldap.Conn, username string) (string, error) {
+	if a.conf.UserDnTemplate != "" {
+		return fmt.Sprintf(a.conf.UserDnTemplate, ldap.EscapeDN(username)), nil
+	}
+
+	if a.conf.BindDn != "" {
+		err := conn.Bind(a.conf.BindDn, a.conf.BindPassword)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	req := ldap.NewSearchRequest(
+		a.conf.UserSearchBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(a.conf.UserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn"},
+		nil,
+	)
+	result, err := conn.Search(req)
+	if err != nil {
+		return "", err
+	}
+	if len(result.Entries) != 1 {
+		// missing or ambiguous users are refused, never guessed at
+		return "", nil
+	}
+	return result.Entries[0].DN, nil
+}
+
+// role resolves the user's role from their groups. The returned bool is false if the user
+// shouldn't be let in at all
+func (a *LdapAuthenticator) role(conn *ldap.Conn, dn string) (string, bool, error) {
+	if len(a.conf.GroupRoles) == 0 {
+		role := a.conf.DefaultRole
+		if role == "" {
+			role = model.RoleReadOnly
+		}
+		return role, true, nil
+	}
+
+	req := ldap.NewSearchRequest(
+		a.conf.GroupSearchBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(a.conf.GroupFilter, ldap.EscapeFilter(dn)),
+		[]string{"dn"},
+		nil,
+	)
+	result, err := conn.Search(req)
+	if err != nil {
+		return "", false, err
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	role, allowed := roleForGroups(groups, a.conf.GroupRoles, a.conf.DefaultRole)
+	return role, allowed, nil
+}
+
+func (a *LdapAuthenticator) Authenticate(username string, password string) (bool, error) {
+	// an empty password would be an unauthenticated bind, which most directories accept
+	if password == "" {
+		return false, nil
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	dn, err := a.userDn(conn, username)
+	if err != nil {
+		return false, err
+	}
+	if dn == "" {
+		log.Println("INFO: No unique directory entry found for user '" + username + "'")
+		return false, nil
+	}
+
+	err = conn.Bind(dn, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// groups are looked up as the user, so the directory's own access rules apply
+	role, allowed, err := a.role(conn, dn)
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		log.Println("WARN: Directory user '" + username + "' is not in any group with access")
+		return false, nil
+	}
+
+	user, err := model.Repo.EnsureExternalUser(username, role, len(a.conf.GroupRoles) > 0)
+	if err != nil {
+		return false, err
+	}
+	if !CheckIsNotLocked(user) {
+		log.Println("WARN: User '" + username + "' is locked!")
+		return false, nil
+	}
+
+	return true, nil
+}
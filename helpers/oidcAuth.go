@@ -0,0 +1,179 @@
+package helpers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// OidcLogin runs the relying party side of the OpenID Connect authorization code flow. The
+// provider's discovery document is only fetched when the first login starts, so an identity
+// provider that's down doesn't stop the service from starting
+type OidcLogin struct {
+	conf globals.OidcConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOidcLogin(conf globals.OidcConfig) (*OidcLogin, error) {
+	if conf.ClientId == "" || conf.RedirectUrl == "" {
+		return nil, errors.New("oidc login needs clientId and redirectUrl")
+	}
+	if conf.DefaultRole != "" && !model.IsValidRole(conf.DefaultRole) {
+		return nil, errors.New("oidc defaultRole is not a valid role: " + conf.DefaultRole)
+	}
+	for group, role := range conf.GroupRoles {
+		if !model.IsValidRole(role) {
+			return nil, errors.New("oidc group " + group + " maps to an invalid role: " + role)
+		}
+	}
+	if conf.UsernameClaim == "" {
+		conf.UsernameClaim = "preferred_username"
+	}
+	if conf.GroupsClaim == "" {
+		conf.GroupsClaim = "groups"
+	}
+
+	return &OidcLogin{conf: conf}, nil
+}
+
+func (o *OidcLogin) PostLoginRedirect() string {
+	return o.conf.PostLoginRedirect
+}
+
+func (o *OidcLogin) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.oauth != nil {
+		return o.oauth, o.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, o.conf.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scopes := []string{oidc.ScopeOpenID, "profile", "email"}
+	if len(o.conf.Scopes) > 0 {
+		scopes = append([]string{oidc.ScopeOpenID}, o.conf.Scopes...)
+	}
+	o.oauth = &oauth2.Config{
+		ClientID:     o.conf.ClientId,
+		ClientSecret: o.conf.ClientSecret,
+		RedirectURL:  o.conf.RedirectUrl,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+	o.verifier = provider.Verifier(&oidc.Config{ClientID: o.conf.ClientId})
+
+	log.Println("NOTICE: Discovered OIDC provider " + o.conf.Issuer)
+	return o.oauth, o.verifier, nil
+}
+
+// AuthCodeURL returns the provider URL to send the browser to. The state, nonce and PKCE
+// verifier must be kept, in the session, for the callback
+func (o *OidcLogin) AuthCodeURL(ctx context.Context, state string, nonce string, pkceVerifier string) (string, error) {
+	oauth, _, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(pkceVerifier)), nil
+}
+
+// Exchange swaps the authorization code for tokens, checks the ID token and its nonce, and
+// maps the user to a local account by the token's issuer and subject. The username claim only
+// names a new account. The returned user name is empty if the user was refused
+func (o *OidcLogin) Exchange(ctx context.Context, code string, nonce string, pkceVerifier string) (string, error) {
+	oauth, verifier, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(pkceVerifier))
+	if err != nil {
+		return "", err
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", errors.New("token response has no id_token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return "", err
+	}
+	if idToken.Nonce != nonce {
+		return "", errors.New("id_token nonce does not match")
+	}
+
+	claims := make(map[string]any)
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return "", err
+	}
+
+	username, ok := claims[o.conf.UsernameClaim].(string)
+	if !ok || username == "" {
+		return "", errors.New("id_token has no " + o.conf.UsernameClaim + " claim")
+	}
+
+	groups := make([]string, 0)
+	if values, ok := claims[o.conf.GroupsClaim].([]any); ok {
+		for _, value := range values {
+			groups = append(groups, fmt.Sprintf("%v", value))
+		}
+	}
+
+	role := model.RoleReadOnly
+	if len(o.conf.GroupRoles) > 0 {
+		var allowed bool
+		role, allowed = roleForGroups(groups, o.conf.GroupRoles, o.conf.DefaultRole)
+		if !allowed {
+			log.Println("WARN: OIDC user '" + username + "' is not in any group with access")
+			return "", nil
+		}
+	} else if o.conf.DefaultRole != "" {
+		role = o.conf.DefaultRole
+	}
+
+	if idToken.Subject == "" {
+		return "", errors.New("id_token has no sub claim")
+	}
+	user, err := model.Repo.EnsureOidcUser(idToken.Issuer, idToken.Subject, username, role, len(o.conf.GroupRoles) > 0)
+	if err != nil {
+		return "", err
+	}
+	if !CheckIsNotLocked(user) {
+		log.Println("WARN: User '" + user.UserName + "' is locked!")
+		return "", nil
+	}
+
+	return user.UserName, nil
+}
@@ -0,0 +1,203 @@
+package helpers
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	gsessions "github.com/gorilla/sessions"
+
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// default session lifetime of 12 hours
+const defaultSessionMaxAge = 12 * 60 * 60
+
+// how often expired server side sessions are cleared out
+const sessionPurgeInterval = 10 * time.Minute
+
+// LoadSessionSecrets finds the session secrets, newest first. If none are configured a
+// random one is made up, which works but means sessions don't survive a restart
+func LoadSessionSecrets(conf globals.SessionConfig) ([]string, error) {
+	secrets := make([]string, 0)
+	source := ""
+	if env := os.Getenv(globals.SessionSecretsEnv); env != "" {
+		secrets = strings.Split(env, ",")
+		source = "environment"
+	} else if conf.SecretFile != "" {
+		content, err := os.ReadFile(conf.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		secrets = strings.Split(string(content), "\n")
+		source = conf.SecretFile
+	} else {
+		secrets = append(secrets, conf.Secrets...)
+		source = "configuration"
+	}
+
+	cleaned := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		secret = strings.TrimSpace(secret)
+		if secret == "" {
+			continue
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("session secrets must be at least 32 characters long")
+		}
+		cleaned = append(cleaned, secret)
+	}
+
+	if len(cleaned) == 0 {
+		log.Println("WARN: No session secret configured. Using a random one, so sessions won't survive a restart")
+		random := make([]byte, 32)
+		_, err := rand.Read(random)
+		if err != nil {
+			return nil, err
+		}
+		return []string{hex.EncodeToString(random)}, nil
+	}
+
+	log.Println("NOTICE: Loaded session secrets from " + source)
+	return cleaned, nil
+}
+
+func deriveKey(secret string, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// sessionKeyPairs turns each secret into an authentication and an encryption key, in the
+// pairs gorilla's securecookie expects
+func sessionKeyPairs(secrets []string) [][]byte {
+	pairs := make([][]byte, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, deriveKey(secret, "ipmanager session authentication"))
+		pairs = append(pairs, deriveKey(secret, "ipmanager session encryption"))
+	}
+	return pairs
+}
+
+// databaseStore adapts the database session store to gin-contrib/sessions
+type databaseStore struct {
+	*model.DatabaseSessionStore
+}
+
+func (s *databaseStore) Options(options sessions.Options) {
+	s.DatabaseSessionStore.Options = options.ToGorillaOptions()
+	s.DatabaseSessionStore.MaxAge(options.MaxAge)
+}
+
+// sessionStoreKind returns the configured session store, treating sqlite, its name from
+// before other databases were supported, as database
+func sessionStoreKind(conf globals.SessionConfig) string {
+	switch conf.Store {
+	case "":
+		return "cookie"
+	case "sqlite":
+		return "database"
+	}
+	return conf.Store
+}
+
+// NewSessionStore builds the configured session store
+func NewSessionStore(conf globals.SessionConfig) (sessions.Store, error) {
+	secrets, err := LoadSessionSecrets(conf)
+	if err != nil {
+		return nil, err
+	}
+	keyPairs := sessionKeyPairs(secrets)
+
+	maxAge := conf.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultSessionMaxAge
+	}
+	options := sessions.Options{
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   conf.SecureCookie,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	var store sessions.Store
+	kind := sessionStoreKind(conf)
+	switch kind {
+	case "cookie":
+		store = cookie.NewStore(keyPairs...)
+	case "database":
+		store = &databaseStore{model.NewDatabaseSessionStore(model.Repo, keyPairs...)}
+		go purgeExpiredSessions()
+	default:
+		return nil, errors.New("unknown session store: " + conf.Store)
+	}
+	store.Options(options)
+
+	log.Println("NOTICE: Using " + kind + " session store")
+	return store, nil
+}
+
+func purgeExpiredSessions() {
+	ticker := time.NewTicker(sessionPurgeInterval)
+	defer ticker.Stop()
+	for {
+		model.Repo.PurgeExpiredSessions()
+		<-ticker.C
+	}
+}
+
+// RenewSession empties a session and gives it a new key before a user is signed in to it, so
+// a session key planted in the browser beforehand can't be used to ride along on the login.
+// Cookie sessions carry no key, so emptying them is enough
+func RenewSession(session sessions.Session) error {
+	session.Clear()
+	inner, ok := session.(interface{ Session() *gsessions.Session })
+	if !ok {
+		return nil
+	}
+	gs := inner.Session()
+	if gs.ID == "" {
+		return nil
+	}
+	err := model.Repo.DeleteSessionKey(gs.ID)
+	if err != nil {
+		log.Println("ERROR: Failed to drop old session")
+		return err
+	}
+	gs.ID = ""
+	return nil
+}
+
+// ServerSideSessions reports whether sessions are kept where they can be listed and revoked
+func ServerSideSessions(conf globals.SessionConfig) bool {
+	return sessionStoreKind(conf) == "database"
+}
@@ -30,13 +30,13 @@ import (
 	"strconv"
 
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"github.com/greeneg/ipmanager/controllers"
+	"github.com/greeneg/ipmanager/db"
 	_ "github.com/greeneg/ipmanager/docs"
 	"github.com/greeneg/ipmanager/globals"
 	"github.com/greeneg/ipmanager/helpers"
@@ -54,6 +54,11 @@ import (
 
 //	@securityDefinitions.basic	BasicAuth
 
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				API token, sent as "Bearer <token>"
+
 //	@license.name	Apache 2.0
 //	@license.url	http://www.apache.org/licenses/LICENSE-2.0.html
 
@@ -62,9 +67,6 @@ import (
 
 // @schemas	http https
 func main() {
-	r := gin.Default()
-	r.SetTrustedProxies(nil)
-
 	// lets get our working directory
 	appdir, err := filepath.Abs(filepath.Dir(os.Args[0]))
 	helpers.CheckError(err)
@@ -86,18 +88,47 @@ func main() {
 	IpManager.ConfigPath = configDir
 	IpManager.ConfStruct = config
 
-	err = model.ConnectDatabase(IpManager.ConfStruct.DbPath)
+	// dbPath predates the database settings, and is still where a SQLite database lives
+	// unless they say otherwise
+	database := IpManager.ConfStruct.Database
+	if database.Driver == "" || database.Driver == db.Sqlite {
+		if database.Dsn == "" {
+			database.Dsn = IpManager.ConfStruct.DbPath
+		}
+	}
+
+	// "ipmanager migrate" updates the database schema and exits rather than serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(database, os.Args[2:]))
+	}
+
+	r := gin.Default()
+	r.SetTrustedProxies(nil)
+	r.Use(middleware.ErrorHandler)
+
+	// the schema is created or brought up to date on every start
+	err = model.ConnectDatabase(database)
+	helpers.CheckError(err)
+
+	err = helpers.ConfigureAuthenticators(IpManager.ConfStruct.Auth)
 	helpers.CheckError(err)
 
+	if IpManager.ConfStruct.Auth.Oidc.Issuer != "" {
+		IpManager.Oidc, err = helpers.NewOidcLogin(IpManager.ConfStruct.Auth.Oidc)
+		helpers.CheckError(err)
+	}
+
 	// some defaults for using session support
-	r.Use(sessions.Sessions("session", cookie.NewStore(globals.Secret)))
+	store, err := helpers.NewSessionStore(IpManager.ConfStruct.Session)
+	helpers.CheckError(err)
+	r.Use(sessions.Sessions("session", store))
 
 	// API
 	public := r.Group("/api/v1")
 	routes.PublicRoutes(public, IpManager)
 
 	private := r.Group("/api/v1")
-	private.Use(middleware.AuthCheck)
+	private.Use(middleware.AuthCheck, middleware.AuditLog)
 	routes.PrivateRoutes(private, IpManager)
 
 	// swagger doc
@@ -0,0 +1,204 @@
+package middleware
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// request bodies larger than this are left out of the audit log
+const maxAuditedBody = 64 * 1024
+
+// auditTarget says how to find the object a route acts upon: from a path parameter, or for
+// creation routes from a field of the request body, and how to take a snapshot of it
+type auditTarget struct {
+	param string
+	field string
+	load  func(key string) (any, error)
+}
+
+var auditTargets = map[string]auditTarget{
+	"address": {param: "address", field: "Address", load: func(key string) (any, error) {
+		address, err := model.Repo.GetAddressByIpAddress(key)
+		if err != nil || address.Address == "" {
+			return nil, err
+		}
+		return address, nil
+	}},
+	"domain": {param: "domainname", field: "DomainName", load: func(key string) (any, error) {
+		domain, err := model.Repo.GetDomainByDomainName(key)
+		if err != nil || domain.DomainName == "" {
+			return nil, err
+		}
+		return domain, nil
+	}},
+	"host": {param: "hostname", field: "HostName", load: func(key string) (any, error) {
+		host, err := model.Repo.GetHostByHostName(key)
+		if err != nil || host.HostName == "" {
+			return nil, err
+		}
+		return host, nil
+	}},
+	"subnet": {param: "networkname", field: "NetworkName", load: func(key string) (any, error) {
+		subnet, err := model.Repo.GetSubnetByNetworkName(key)
+		if err != nil || subnet.NetworkName == "" {
+			return nil, err
+		}
+		return subnet, nil
+	}},
+	"user": {param: "name", field: "UserName", load: func(key string) (any, error) {
+		user, err := model.Repo.GetUserByUserName(key)
+		if err != nil || user.UserName == "" {
+			return nil, err
+		}
+		return user, nil
+	}},
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// redact blanks out anything in a decoded JSON document that looks like a credential
+func redact(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		for key, value := range v {
+			lower := strings.ToLower(key)
+			if strings.Contains(lower, "password") || strings.Contains(lower, "secret") || strings.Contains(lower, "token") {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redact(value)
+			}
+		}
+	case []any:
+		for idx, value := range v {
+			v[idx] = redact(value)
+		}
+	}
+	return doc
+}
+
+// resolveAuditTarget works out the type and key of the object a request acts upon from the
+// first segment of its route that names one
+func resolveAuditTarget(c *gin.Context, body map[string]any) (string, string) {
+	for _, segment := range strings.Split(c.FullPath(), "/") {
+		target, ok := auditTargets[segment]
+		if !ok {
+			continue
+		}
+		key := c.Param(target.param)
+		if key == "" {
+			if value, ok := body[target.field].(string); ok {
+				key = value
+			}
+		}
+		return segment, key
+	}
+	return "", ""
+}
+
+func snapshot(objectType string, key string) json.RawMessage {
+	if objectType == "" || key == "" {
+		return nil
+	}
+	obj, err := auditTargets[objectType].load(key)
+	if err != nil {
+		log.Println("WARN: Unable to snapshot " + objectType + " '" + key + "' for the audit log: " + string(err.Error()))
+		return nil
+	}
+	if obj == nil {
+		return nil
+	}
+	doc, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	return doc
+}
+
+// AuditLog records every mutating call on the routes it guards, with snapshots of the
+// object the call targeted from before and after it ran. It must be used after AuthCheck
+func AuditLog(c *gin.Context) {
+	if !isMutating(c.Request.Method) {
+		c.Next()
+		return
+	}
+
+	// keep a copy of the body, putting it back for the handler to bind
+	var raw []byte
+	if c.Request.Body != nil {
+		var err error
+		raw, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			log.Println("ERROR: Unable to read request body: " + string(err.Error()))
+			c.Error(errors.New("unable to read request body")).SetType(gin.ErrorTypeBind)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	}
+
+	var request json.RawMessage
+	body := make(map[string]any)
+	var decoded any
+	if len(raw) > 0 && len(raw) <= maxAuditedBody && json.Unmarshal(raw, &decoded) == nil {
+		if obj, ok := decoded.(map[string]any); ok {
+			body = obj
+		}
+		request, _ = json.Marshal(redact(decoded))
+	}
+
+	objectType, objectId := resolveAuditTarget(c, body)
+	before := snapshot(objectType, objectId)
+
+	c.Next()
+
+	entry := model.AuditEntry{
+		UserName:   c.GetString(globals.UserKey),
+		Method:     c.Request.Method,
+		Route:      c.FullPath(),
+		Path:       c.Request.URL.Path,
+		ObjectType: objectType,
+		ObjectId:   objectId,
+		Before:     before,
+		After:      snapshot(objectType, objectId),
+		Request:    request,
+		StatusCode: ResponseStatus(c),
+		ClientIp:   c.ClientIP(),
+	}
+	_, err := model.Repo.CreateAuditEntry(entry)
+	if err != nil {
+		log.Println("ERROR: Unable to write audit entry for " + entry.Method + " " + entry.Path + ": " + string(err.Error()))
+	}
+}
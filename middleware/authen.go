@@ -20,9 +20,10 @@ package middleware
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-contrib/sessions"
@@ -32,20 +33,66 @@ import (
 	"github.com/greeneg/ipmanager/model"
 )
 
-func processAuthorizationHeader(authHeader string) (string, string) {
+func processAuthorizationHeader(authHeader string) (string, string, bool) {
 	// split the header value at the space
-	encodedString := strings.Split(authHeader, " ")
+	encodedString := strings.SplitN(authHeader, " ", 2)
+	if len(encodedString) != 2 || !strings.EqualFold(encodedString[0], "Basic") {
+		return "", "", false
+	}
 
 	// remove base64 encoding
-	decodedString, _ := base64.StdEncoding.DecodeString(encodedString[1])
+	decodedString, err := base64.StdEncoding.DecodeString(encodedString[1])
+	if err != nil {
+		return "", "", false
+	}
 
-	// now lets return both the
-	authValues := strings.Split(string(decodedString), ":")
+	// now lets return both the user name and password. Only the first colon separates them
+	authValues := strings.SplitN(string(decodedString), ":", 2)
+	if len(authValues) != 2 {
+		return "", "", false
+	}
 
-	return authValues[0], authValues[1]
+	return authValues[0], authValues[1], true
+}
+
+// processBearerToken authenticates a request by API token. Token requests never get a
+// session, so every request has to present the token, and the token's scopes are kept in
+// the context for RequireScope
+func processBearerToken(c *gin.Context, token string) bool {
+	apiToken, user, err := model.Repo.GetApiTokenOwner(token)
+	if err != nil {
+		log.Println("ERROR: API token authentication failed: " + string(err.Error()))
+		return false
+	}
+	if user.UserName == "" || !helpers.CheckIsNotLocked(user) {
+		log.Println("WARN: API token " + strconv.Itoa(apiToken.Id) + " belongs to a missing or locked user")
+		return false
+	}
+
+	c.Set(globals.UserKey, user.UserName)
+	c.Set(globals.RoleKey, user.Role)
+	c.Set(globals.ScopesKey, apiToken.Scopes)
+	log.Println("INFO: Authenticated user '" + user.UserName + "' by API token " + strconv.Itoa(apiToken.Id))
+	return true
+}
+
+// abortWith ends the chain with an error for ErrorHandler to answer the request with
+func abortWith(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
 }
 
 func AuthCheck(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	if token, found := strings.CutPrefix(authHeader, "Bearer "); found {
+		if !processBearerToken(c, strings.TrimSpace(token)) {
+			abortWith(c, &model.NotAuthenticated{Err: errors.New("not authorized!")})
+			return
+		}
+		c.Next()
+		return
+	}
+
 	session := sessions.Default(c)
 	user := session.Get("user")
 	if user == nil {
@@ -53,45 +100,67 @@ func AuthCheck(c *gin.Context) {
 		baHeader := c.GetHeader("Authorization")
 		if baHeader == "" {
 			log.Println("ERROR: No authentication header found. Aborting")
-			c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "not authorized!"})
-			c.Abort()
+			abortWith(c, &model.NotAuthenticated{Err: errors.New("not authorized!")})
 			return
 		}
 		// otherwise, lets process that header
-		username, password := processAuthorizationHeader(baHeader)
+		username, password, ok := processAuthorizationHeader(baHeader)
+		if !ok {
+			log.Println("ERROR: Malformed authentication header. Aborting")
+			abortWith(c, &model.NotAuthenticated{Err: errors.New("not authorized!")})
+			return
+		}
 		authStatus := helpers.CheckUserPass(username, password)
 		if authStatus {
+			user, err := model.Repo.GetUserByUserName(username)
+			if err != nil {
+				log.Println("ERROR: " + string(err.Error()))
+				abortWith(c, err)
+				return
+			}
+			c.Set(globals.UserKey, user.UserName)
+			c.Set(globals.RoleKey, user.Role)
+			c.Set(globals.ScopesKey, model.Scopes)
+
+			if err := helpers.RenewSession(session); err != nil {
+				log.Println("WARN: Failed to renew user session: " + string(err.Error()))
+			}
 			session.Set(globals.UserKey, username)
 			if err := session.Save(); err != nil {
-				c.IndentedJSON(http.StatusInternalServerError,
-					gin.H{"error": "failed to save user session"})
 				// session saving is not fatal, so allow them to proceed
+				log.Println("WARN: Failed to save user session: " + string(err.Error()))
 			}
 			log.Println("INFO: Authenticated")
 		} else {
 			log.Println("ERROR: Authentication failed. Aborting")
-			c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "not authorized!"})
-			c.Abort()
+			abortWith(c, &model.NotAuthenticated{Err: errors.New("not authorized!")})
 			return
 		}
 	} else {
 		userString := fmt.Sprintf("%v", user)
 		log.Println("INFO: Session found: User: " + userString)
 		log.Println("INFO: Checking if user is locked or not...")
-		user, err := model.GetUserByUserName(userString)
+		user, err := model.Repo.GetUserByUserName(userString)
 		if err != nil {
 			log.Println("ERROR: " + string(err.Error()))
-			c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "unable to authenticate: " + err.Error()})
-			c.Abort()
+			abortWith(c, err)
+			return
+		}
+		// the account may have been removed since the session was started
+		if user.UserName == "" {
+			log.Println("WARN: Session user '" + userString + "' no longer exists!")
+			abortWith(c, &model.NotAuthenticated{Err: errors.New("not authorized!")})
 			return
 		}
 		status := helpers.CheckIsNotLocked(user)
 		if status {
+			c.Set(globals.UserKey, user.UserName)
+			c.Set(globals.RoleKey, user.Role)
+			c.Set(globals.ScopesKey, model.Scopes)
 			log.Println("INFO: Authenticated")
 		} else {
 			log.Println("WARN: User '" + userString + "' is locked!")
-			c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "not authorized!"})
-			c.Abort()
+			abortWith(c, &model.NotAuthenticated{Err: errors.New("not authorized!")})
 			return
 		}
 	}
@@ -0,0 +1,62 @@
+package middleware
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+// RequireRole only lets a request through if AuthCheck found the user holds one of the given
+// roles. It must be used after AuthCheck
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString(globals.UserKey)
+		role := c.GetString(globals.RoleKey)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		log.Println("WARN: User '" + username + "' with role '" + role + "' denied access to " + c.Request.Method + " " + c.FullPath())
+		abortWith(c, &model.AccessDenied{Err: errors.New("Insufficient access. Access denied!")})
+	}
+}
+
+// RequireScope only lets a request through if its credentials carry the given scope, or one
+// that includes it. Basic and session authentication carry every scope, leaving the user's
+// role as the only restriction. It must be used after AuthCheck
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if model.ScopesGrant(c.GetStringSlice(globals.ScopesKey), scope) {
+			c.Next()
+			return
+		}
+
+		username := c.GetString(globals.UserKey)
+		log.Println("WARN: Credentials of user '" + username + "' lack the '" + scope + "' scope for " + c.Request.Method + " " + c.FullPath())
+		abortWith(c, &model.AccessDenied{Err: errors.New("Insufficient scope. Access denied!")})
+	}
+}
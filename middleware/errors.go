@@ -0,0 +1,99 @@
+package middleware
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/greeneg/ipmanager/model"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemStatus works out the HTTP status for an error left on the context. A handler can
+// pick the status itself by setting it as the error's meta, errors from binding a request
+// body are the client's fault whatever they are, and everything else goes by its kind
+func problemStatus(e *gin.Error) int {
+	if status, ok := e.Meta.(int); ok {
+		return status
+	}
+	if e.IsType(gin.ErrorTypeBind) {
+		return http.StatusBadRequest
+	}
+
+	switch model.KindOf(e.Err) {
+	case model.KindNotFound:
+		return http.StatusNotFound
+	case model.KindConflict, model.KindInUse:
+		return http.StatusConflict
+	case model.KindValidation:
+		return http.StatusUnprocessableEntity
+	case model.KindUnauthorized:
+		return http.StatusUnauthorized
+	case model.KindForbidden:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// ResponseStatus returns the status a request will be answered with, including one that's
+// only decided by ErrorHandler once the rest of the chain has returned
+func ResponseStatus(c *gin.Context) int {
+	if !c.Writer.Written() && len(c.Errors) > 0 {
+		return problemStatus(c.Errors.Last())
+	}
+	return c.Writer.Status()
+}
+
+// ErrorHandler answers requests that handlers finished by calling c.Error rather than
+// writing a response, with an RFC 7807 problem details body for the last error. Internal
+// errors are logged, and only described in general terms to the client. It must be the
+// first middleware so it sees every error
+func ErrorHandler(c *gin.Context) {
+	c.Next()
+
+	if c.Writer.Written() || len(c.Errors) == 0 {
+		return
+	}
+
+	last := c.Errors.Last()
+	status := problemStatus(last)
+	problem := model.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   model.Detail(last.Err),
+		Instance: c.Request.URL.Path,
+	}
+	if status == http.StatusInternalServerError {
+		log.Println("ERROR: " + c.Request.Method + " " + c.Request.URL.Path + " failed: " + last.Error())
+		problem.Detail = "The request could not be completed"
+	}
+
+	var validation *model.ValidationFailed
+	if errors.As(last.Err, &validation) {
+		problem.InvalidParams = validation.Params
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.IndentedJSON(status, problem)
+}
@@ -0,0 +1,80 @@
+package main
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/greeneg/ipmanager/db"
+	"github.com/greeneg/ipmanager/globals"
+	"github.com/greeneg/ipmanager/model"
+)
+
+const migrateUsage = "usage: ipmanager migrate [status]"
+
+// runMigrate handles the migrate command, which brings the database schema up to date
+// without starting the server, or with the status argument only reports where it stands.
+// It returns the process exit code
+func runMigrate(conf globals.DatabaseConfig, args []string) int {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "status") {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	err := model.OpenDatabase(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to open database: "+err.Error())
+		return 1
+	}
+
+	conn := model.DB.Pool()
+	driver := model.DB.Dialect().Name()
+	if len(args) == 0 {
+		applied, err := db.Migrate(conn, driver)
+		for _, version := range applied {
+			fmt.Printf("applied migration %d\n", version)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migration failed: "+err.Error())
+			return 1
+		}
+	}
+
+	current, err := db.CurrentVersion(conn, driver)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to read schema version: "+err.Error())
+		return 1
+	}
+	migrations, err := db.Migrations(driver)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	for _, migration := range migrations {
+		state := "pending"
+		if migration.Version <= current {
+			state = "applied"
+		}
+		fmt.Printf("%04d_%s\t%s\n", migration.Version, migration.Name, state)
+	}
+	fmt.Printf("schema version %d of %d\n", current, len(migrations))
+	return 0
+}
@@ -19,14 +19,19 @@ package model
 */
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"math/big"
 	"strconv"
 )
 
-func GetAddressById(id int) (Address, error) {
+func (r *SqlRepository) GetAddressById(id int) (Address, error) {
 	log.Println("INFO: Getting address by id: " + strconv.Itoa(id))
-	rec, err := DB.Prepare("SELECT * FROM AssignedAddresses WHERE id = ?")
+	rec, err := r.db.Prepare("SELECT * FROM AssignedAddresses WHERE id = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement for GetAddressById")
 		return Address{}, err
@@ -34,14 +39,7 @@ func GetAddressById(id int) (Address, error) {
 	defer rec.Close()
 
 	addr := Address{}
-	r, err := rec.Query(id)
-	if err != nil {
-		log.Println("ERROR: Failed to query address by id")
-		return Address{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(id).Scan(
 		&addr.Id,
 		&addr.Address,
 		&addr.HostNameId,
@@ -61,24 +59,17 @@ func GetAddressById(id int) (Address, error) {
 	return addr, nil
 }
 
-func GetHostIdByHostname(hostname string) (int, error) {
+func (r *SqlRepository) GetHostIdByHostname(hostname string) (int, error) {
 	var hostNameId int = 0
 	log.Println("INFO: Getting host id by hostname: " + hostname)
-	rec, err := DB.Prepare("SELECT Id FROM Hosts WHERE HostName = ?")
+	rec, err := r.db.Prepare("SELECT Id FROM Hosts WHERE HostName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement for GetHostIdByHostname")
 		return hostNameId, err
 	}
 	defer rec.Close()
 
-	r, err := rec.Query(hostname)
-	if err != nil {
-		log.Println("ERROR: Failed to query host id by hostname")
-		return hostNameId, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(hostname).Scan(
 		&hostNameId,
 	)
 	if err != nil {
@@ -94,9 +85,9 @@ func GetHostIdByHostname(hostname string) (int, error) {
 	return hostNameId, nil
 }
 
-func GetAddressByHostName(hostname string) (Address, error) {
+func (r *SqlRepository) GetAddressByHostName(hostname string) (Address, error) {
 	log.Println("INFO: Getting address by hostname: " + hostname)
-	hostNameId, err := GetHostIdByHostname(hostname)
+	hostNameId, err := r.GetHostIdByHostname(hostname)
 	if err != nil {
 		log.Println("ERROR: Failed to get host id by hostname")
 		return Address{}, err
@@ -106,7 +97,7 @@ func GetAddressByHostName(hostname string) (Address, error) {
 		return Address{}, nil
 	}
 
-	rec, err := DB.Prepare("SELECT * FROM AssignedAddresses WHERE HostNameId = ?")
+	rec, err := r.db.Prepare("SELECT * FROM AssignedAddresses WHERE HostNameId = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement for GetAddressByHostName")
 		return Address{}, err
@@ -115,14 +106,7 @@ func GetAddressByHostName(hostname string) (Address, error) {
 
 	addr := Address{}
 
-	r, err := rec.Query(hostNameId)
-	if err != nil {
-		log.Println("ERROR: Failed to query address by hostname")
-		return Address{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(hostNameId).Scan(
 		&addr.Id,
 		&addr.Address,
 		&addr.HostNameId,
@@ -144,9 +128,9 @@ func GetAddressByHostName(hostname string) (Address, error) {
 	return addr, nil
 }
 
-func GetAddressByHostNameId(id int) (Address, error) {
+func (r *SqlRepository) GetAddressByHostNameId(id int) (Address, error) {
 	log.Println("INFO: Getting address by hostname id: " + strconv.Itoa(id))
-	rec, err := DB.Prepare("SELECT * FROM AssignedAddresses WHERE HostNameId = ?")
+	rec, err := r.db.Prepare("SELECT * FROM AssignedAddresses WHERE HostNameId = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement for GetAddressByHostNameId")
 		return Address{}, err
@@ -155,14 +139,7 @@ func GetAddressByHostNameId(id int) (Address, error) {
 
 	addr := Address{}
 
-	r, err := rec.Query(id)
-	if err != nil {
-		log.Println("ERROR: Failed to query address by hostname id")
-		return Address{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(id).Scan(
 		&addr.Id,
 		&addr.Address,
 		&addr.HostNameId,
@@ -184,9 +161,10 @@ func GetAddressByHostNameId(id int) (Address, error) {
 	return addr, nil
 }
 
-func GetAddressByIpAddress(ip string) (Address, error) {
+func (r *SqlRepository) GetAddressByIpAddress(ip string) (Address, error) {
+	ip = normaliseAddress(ip)
 	log.Println("INFO: Getting address by ip address: " + ip)
-	rec, err := DB.Prepare("SELECT * FROM AssignedAddresses WHERE Address = ?")
+	rec, err := r.db.Prepare("SELECT * FROM AssignedAddresses WHERE Address = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement for GetAddressByIpAddress")
 		return Address{}, err
@@ -195,14 +173,7 @@ func GetAddressByIpAddress(ip string) (Address, error) {
 
 	addr := Address{}
 
-	r, err := rec.Query(ip)
-	if err != nil {
-		log.Println("ERROR: Failed to query address by ip address")
-		return Address{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(ip).Scan(
 		&addr.Id,
 		&addr.Address,
 		&addr.HostNameId,
@@ -224,9 +195,9 @@ func GetAddressByIpAddress(ip string) (Address, error) {
 	return addr, nil
 }
 
-func GetAddressesByDomainId(id int) ([]Address, error) {
+func (r *SqlRepository) GetAddressesByDomainId(id int) ([]Address, error) {
 	log.Println("INFO: Getting addresses by domain id: " + strconv.Itoa(id))
-	rows, err := DB.Query("SELECT * FROM AssignedAddresses WHERE DomainId = ?", id)
+	rows, err := r.db.Query("SELECT * FROM AssignedAddresses WHERE DomainId = ?", id)
 	if err != nil {
 		log.Println("ERROR: Failed to query addresses by domain id")
 		return nil, err
@@ -256,9 +227,9 @@ func GetAddressesByDomainId(id int) ([]Address, error) {
 	return addresses, nil
 }
 
-func GetDomainIdByDomainName(domainname string) (int, error) {
+func (r *SqlRepository) GetDomainIdByDomainName(domainname string) (int, error) {
 	log.Println("INFO: Getting domain id by domain name: " + domainname)
-	rec, err := DB.Prepare("SELECT Id FROM Domains WHERE DomainName = ?")
+	rec, err := r.db.Prepare("SELECT Id FROM Domains WHERE DomainName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement for GetDomainIdByDomainName")
 		return 0, err
@@ -282,9 +253,9 @@ func GetDomainIdByDomainName(domainname string) (int, error) {
 	return id, nil
 }
 
-func GetAddressesByDomainName(domainname string) ([]Address, error) {
+func (r *SqlRepository) GetAddressesByDomainName(domainname string) ([]Address, error) {
 	log.Println("INFO: Getting addresses by domain name: " + domainname)
-	id, err := GetDomainIdByDomainName(domainname)
+	id, err := r.GetDomainIdByDomainName(domainname)
 	if err != nil {
 		log.Println("ERROR: Failed to get domain id by domain name")
 		return nil, err
@@ -294,7 +265,7 @@ func GetAddressesByDomainName(domainname string) ([]Address, error) {
 		return nil, nil
 	}
 
-	rows, err := DB.Query("SELECT * FROM AssignedAddresses WHERE DomainId = ?", id)
+	rows, err := r.db.Query("SELECT * FROM AssignedAddresses WHERE DomainId = ?", id)
 	if err != nil {
 		log.Println("ERROR: Failed to query addresses by domain id")
 		return nil, err
@@ -324,9 +295,9 @@ func GetAddressesByDomainName(domainname string) ([]Address, error) {
 	return addresses, nil
 }
 
-func GetAddressesBySubnetId(id int) ([]Address, error) {
+func (r *SqlRepository) GetAddressesBySubnetId(id int) ([]Address, error) {
 	log.Println("INFO: Getting addresses by subnet id: " + strconv.Itoa(id))
-	rows, err := DB.Query("SELECT * FROM AssignedAddresses WHERE SubnetId = ?", id)
+	rows, err := r.db.Query("SELECT * FROM AssignedAddresses WHERE SubnetId = ?", id)
 	if err != nil {
 		log.Println("ERROR: Failed to query addresses by subnet id")
 		return nil, err
@@ -356,9 +327,9 @@ func GetAddressesBySubnetId(id int) ([]Address, error) {
 	return addresses, nil
 }
 
-func GetSubnetIdBySubnetName(snetname string) (int, error) {
+func (r *SqlRepository) GetSubnetIdBySubnetName(snetname string) (int, error) {
 	log.Println("INFO: Getting subnet id by subnet name: " + snetname)
-	rec, err := DB.Prepare("SELECT Id FROM Subnets WHERE NetworkName = ?")
+	rec, err := r.db.Prepare("SELECT Id FROM Subnets WHERE NetworkName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement for GetSubnetIdBySubnetName")
 		return 0, err
@@ -382,9 +353,9 @@ func GetSubnetIdBySubnetName(snetname string) (int, error) {
 	return id, nil
 }
 
-func GetAddressesBySubnetName(snetname string) ([]Address, error) {
+func (r *SqlRepository) GetAddressesBySubnetName(snetname string) ([]Address, error) {
 	log.Println("INFO: Getting addresses by subnet name: " + snetname)
-	id, err := GetSubnetIdBySubnetName(snetname)
+	id, err := r.GetSubnetIdBySubnetName(snetname)
 	if err != nil {
 		log.Println("ERROR: Failed to get subnet id by subnet name")
 		return nil, err
@@ -394,11 +365,12 @@ func GetAddressesBySubnetName(snetname string) ([]Address, error) {
 		return nil, nil
 	}
 
-	rows, err := DB.Query("SELECT * FROM AssignedAddresses WHERE SubnetId = ?", id)
+	rows, err := r.db.Query("SELECT * FROM AssignedAddresses WHERE SubnetId = ?", id)
 	if err != nil {
 		log.Println("ERROR: Failed to query addresses by subnet id")
 		return nil, err
 	}
+	defer rows.Close()
 
 	addresses := make([]Address, 0)
 	for rows.Next() {
@@ -423,12 +395,32 @@ func GetAddressesBySubnetName(snetname string) ([]Address, error) {
 	return addresses, nil
 }
 
-func GetAddresses() ([]Address, error) {
-	log.Println("INFO: Getting all addresses")
-	rows, err := DB.Query("SELECT * FROM AssignedAddresses")
+var addressListing = listTable{
+	table: "AssignedAddresses",
+	columns: []listColumn{
+		{name: "Id", kind: intColumn},
+		{name: "Address", kind: textColumn},
+		{name: "HostNameId", kind: intColumn},
+		{name: "DomainId", kind: intColumn},
+		{name: "SubnetId", kind: intColumn},
+		{name: "CreatorId", kind: intColumn},
+		{name: "CreationDate", kind: timeColumn, alias: "created"},
+	},
+}
+
+// GetAddresses returns a page of the assigned addresses, along with how many match the
+// filters overall
+func (r *SqlRepository) GetAddresses(o ListOptions) ([]Address, int, error) {
+	log.Println("INFO: Getting addresses")
+	query, args, total, err := r.list(addressListing, `SELECT Id, Address, HostNameId, DomainId, SubnetId, CreatorId, CreationDate
+	FROM AssignedAddresses`, o)
 	if err != nil {
-		log.Println("ERROR: Failed to query all addresses")
-		return nil, err
+		return nil, 0, err
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("ERROR: Failed to query addresses")
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -438,19 +430,599 @@ func GetAddresses() ([]Address, error) {
 		err = rows.Scan(
 			&address.Id,
 			&address.Address,
-			&address.SubnetId,
 			&address.HostNameId,
 			&address.DomainId,
+			&address.SubnetId,
 			&address.CreatorId,
 			&address.CreationDate,
 		)
 		if err != nil {
 			log.Println("ERROR: Failed to scan address")
+			return nil, 0, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	log.Println("INFO: Found " + strconv.Itoa(len(addresses)) + " of " + strconv.Itoa(total) + " addresses")
+	return addresses, total, nil
+}
+
+// checkNotGateway refuses a subnet's gateway address, which is never handed out to a host
+func checkNotGateway(s Subnet, address string) error {
+	if s.GatewayAddress == "" || normaliseAddress(s.GatewayAddress) != address {
+		return nil
+	}
+	log.Println("ERROR: Address " + address + " is the gateway of subnet " + s.NetworkName)
+	return invalidParam("Address", address+" is the gateway of subnet "+s.NetworkName)
+}
+
+func (r *SqlRepository) AssignAddress(a AddressAssignment, id int) (bool, error) {
+	log.Println("INFO: Assigning address " + a.Address + " to host " + a.HostName)
+	subnet, err := r.GetSubnetByNetworkName(a.NetworkName)
+	if err != nil {
+		log.Println("ERROR: Failed to get subnet by name")
+		return false, err
+	}
+	if subnet.NetworkName == "" {
+		log.Println("ERROR: No subnet found with name " + a.NetworkName)
+		return false, invalidParam("NetworkName", "no subnet found with name "+a.NetworkName)
+	}
+
+	hostNameId, err := r.GetHostIdByHostname(a.HostName)
+	if err != nil {
+		log.Println("ERROR: Failed to get host id by hostname")
+		return false, err
+	}
+	if hostNameId == 0 {
+		log.Println("ERROR: No host found with name " + a.HostName)
+		return false, invalidParam("HostName", "no host found with name "+a.HostName)
+	}
+
+	// default to the subnet's domain if one wasn't requested
+	domainId := subnet.DomainId
+	if a.DomainName != "" {
+		domainId, err = r.GetDomainIdByDomainName(a.DomainName)
+		if err != nil {
+			log.Println("ERROR: Failed to get domain id by domain name")
+			return false, err
+		}
+		if domainId == 0 {
+			log.Println("ERROR: No domain found with name " + a.DomainName)
+			return false, invalidParam("DomainName", "no domain found with name "+a.DomainName)
+		}
+	}
+
+	address := normaliseAddress(a.Address)
+	err = checkNotGateway(subnet, address)
+	if err != nil {
+		return false, err
+	}
+
+	// flip the address' row in SubnetAddresses first. If no row changed, the address is either
+	// already taken or doesn't belong to the subnet at all
+	claimStatement := "UPDATE SubnetAddresses SET AssignmentState = 1 WHERE SubnetId = ? AND IpAddress = ? AND AssignmentState = 0"
+	if isSparseSubnet(subnet) {
+		// sparse subnets only have rows for addresses in use, so claiming one means adding its row
+		space, err := newSparseRange(subnet)
+		if err != nil {
+			log.Println("ERROR: Failed to work out address range of subnet " + subnet.NetworkName)
+			return false, err
+		}
+		if !space.contains(address) {
+			log.Println("ERROR: Address " + address + " is not part of subnet " + subnet.NetworkName)
+			return false, &AddressNotAvailable{Err: errors.New("address not available: " + address)}
+		}
+		claimStatement = "INSERT INTO SubnetAddresses (SubnetId, IpAddress, AssignmentState) VALUES (?, ?, 1) ON CONFLICT DO NOTHING"
+	}
+
+	t, err := r.db.Begin()
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to assign address " + address)
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to assign address " + address)
+			t.Rollback()
+		}
+	}()
+
+	err = checkNotContainer(t.QueryRow(containerQuery, subnet.Id), subnet)
+	if err != nil {
+		return false, err
+	}
+
+	q, err := t.Prepare(claimStatement)
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	result, err := q.Exec(subnet.Id, address)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+	numberOfRows, err := result.RowsAffected()
+	if err != nil {
+		log.Println("ERROR: Failed to get number of rows affected")
+		return false, err
+	}
+	if numberOfRows != 1 {
+		log.Println("ERROR: Address " + address + " is not available in subnet " + subnet.NetworkName)
+		err = &AddressNotAvailable{Err: errors.New("address not available: " + address)}
+		return false, err
+	}
+
+	q, err = t.Prepare("INSERT INTO AssignedAddresses (Address, HostNameId, DomainId, SubnetId, CreatorId) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	_, err = q.Exec(address, hostNameId, domainId, subnet.Id, id)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return false, err
+	}
+
+	log.Println("INFO: Address " + address + " assigned to host " + a.HostName)
+	return true, nil
+}
+
+func (r *SqlRepository) ReassignAddress(address string, j AddressReassignment) (bool, error) {
+	address = normaliseAddress(address)
+	log.Println("INFO: Reassigning address " + address)
+	addr, err := r.GetAddressByIpAddress(address)
+	if err != nil {
+		log.Println("ERROR: Failed to get address by ip address")
+		return false, err
+	}
+	if addr.Address == "" {
+		log.Println("ERROR: Address " + address + " is not assigned")
+		return false, &AddressNotAssigned{Err: errors.New("address not assigned: " + address)}
+	}
+
+	subnet, err := r.GetSubnetById(addr.SubnetId)
+	if err != nil {
+		log.Println("ERROR: Failed to get subnet by id")
+		return false, err
+	}
+	err = checkNotGateway(subnet, address)
+	if err != nil {
+		return false, err
+	}
+
+	// only replace the parts of the assignment that were sent in
+	hostNameId := addr.HostNameId
+	if j.HostName != "" {
+		hostNameId, err = r.GetHostIdByHostname(j.HostName)
+		if err != nil {
+			log.Println("ERROR: Failed to get host id by hostname")
+			return false, err
+		}
+		if hostNameId == 0 {
+			log.Println("ERROR: No host found with name " + j.HostName)
+			return false, invalidParam("HostName", "no host found with name "+j.HostName)
+		}
+	}
+	domainId := addr.DomainId
+	if j.DomainName != "" {
+		domainId, err = r.GetDomainIdByDomainName(j.DomainName)
+		if err != nil {
+			log.Println("ERROR: Failed to get domain id by domain name")
+			return false, err
+		}
+		if domainId == 0 {
+			log.Println("ERROR: No domain found with name " + j.DomainName)
+			return false, invalidParam("DomainName", "no domain found with name "+j.DomainName)
+		}
+	}
+
+	t, err := r.db.Begin()
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to reassign address " + address)
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to reassign address " + address)
+			t.Rollback()
+		}
+	}()
+
+	q, err := t.Prepare("UPDATE AssignedAddresses SET HostNameId = ?, DomainId = ? WHERE Address = ?")
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	_, err = q.Exec(hostNameId, domainId, address)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return false, err
+	}
+
+	log.Println("INFO: Address " + address + " reassigned successfully")
+	return true, nil
+}
+
+func (r *SqlRepository) ReleaseAddress(address string) (bool, error) {
+	address = normaliseAddress(address)
+	log.Println("INFO: Releasing address " + address)
+	addr, err := r.GetAddressByIpAddress(address)
+	if err != nil {
+		log.Println("ERROR: Failed to get address by ip address")
+		return false, err
+	}
+	if addr.Address == "" {
+		log.Println("ERROR: Address " + address + " is not assigned")
+		return false, &AddressNotAssigned{Err: errors.New("address not assigned: " + address)}
+	}
+
+	subnet, err := r.GetSubnetById(addr.SubnetId)
+	if err != nil {
+		log.Println("ERROR: Failed to get subnet by id")
+		return false, err
+	}
+	if subnet.NetworkName == "" {
+		log.Println("ERROR: No subnet found with id " + strconv.Itoa(addr.SubnetId))
+		return false, &NotFound{Err: fmt.Errorf("no subnet found with id %d", addr.SubnetId)}
+	}
+
+	t, err := r.db.Begin()
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to release address " + address)
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to release address " + address)
+			t.Rollback()
+		}
+	}()
+
+	q, err := t.Prepare("DELETE FROM AssignedAddresses WHERE Address = ?")
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	_, err = q.Exec(address)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+
+	releaseStatement := "UPDATE SubnetAddresses SET AssignmentState = 0 WHERE SubnetId = ? AND IpAddress = ?"
+	if isSparseSubnet(subnet) {
+		releaseStatement = "DELETE FROM SubnetAddresses WHERE SubnetId = ? AND IpAddress = ?"
+	}
+	q, err = t.Prepare(releaseStatement)
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	_, err = q.Exec(subnet.Id, address)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return false, err
+	}
+
+	log.Println("INFO: Address " + address + " released successfully")
+	return true, nil
+}
+
+func (r *SqlRepository) AllocateAddress(subnetName string, a AddressAllocation, id int) (Address, error) {
+	log.Println("INFO: Allocating next free address in subnet " + subnetName + " for host " + a.HostName)
+	subnet, err := r.GetSubnetByNetworkName(subnetName)
+	if err != nil {
+		log.Println("ERROR: Failed to get subnet by name")
+		return Address{}, err
+	}
+	if subnet.NetworkName == "" {
+		log.Println("ERROR: No subnet found with name " + subnetName)
+		return Address{}, &NotFound{Err: fmt.Errorf("no subnet found with name %s", subnetName)}
+	}
+
+	hostNameId, err := r.GetHostIdByHostname(a.HostName)
+	if err != nil {
+		log.Println("ERROR: Failed to get host id by hostname")
+		return Address{}, err
+	}
+	if hostNameId == 0 {
+		log.Println("ERROR: No host found with name " + a.HostName)
+		return Address{}, invalidParam("HostName", "no host found with name "+a.HostName)
+	}
+
+	domainId := subnet.DomainId
+	if a.DomainName != "" {
+		domainId, err = r.GetDomainIdByDomainName(a.DomainName)
+		if err != nil {
+			log.Println("ERROR: Failed to get domain id by domain name")
+			return Address{}, err
+		}
+		if domainId == 0 {
+			log.Println("ERROR: No domain found with name " + a.DomainName)
+			return Address{}, invalidParam("DomainName", "no domain found with name "+a.DomainName)
+		}
+	}
+
+	// take the write lock before looking for a free row so that concurrent allocations
+	// queue up behind each other instead of picking the same address
+	conn, err := r.db.beginWrite("SubnetAddresses", "AssignedAddresses")
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return Address{}, err
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to allocate address in subnet " + subnetName)
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to allocate address in subnet " + subnetName)
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	err = checkNotContainer(conn.QueryRowContext(ctx, containerQuery, subnet.Id), subnet)
+	if err != nil {
+		return Address{}, err
+	}
+
+	var address string
+	if isSparseSubnet(subnet) {
+		address, err = allocateSparseAddress(ctx, conn, subnet)
+	} else {
+		address, err = allocatePopulatedAddress(ctx, conn, subnet)
+	}
+	if err != nil {
+		return Address{}, err
+	}
+
+	_, err = conn.ExecContext(ctx, "INSERT INTO AssignedAddresses (Address, HostNameId, DomainId, SubnetId, CreatorId) VALUES (?, ?, ?, ?, ?)",
+		address, hostNameId, domainId, subnet.Id, id)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return Address{}, err
+	}
+
+	_, err = conn.ExecContext(ctx, "COMMIT")
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return Address{}, err
+	}
+
+	log.Println("INFO: Address " + address + " allocated to host " + a.HostName)
+	return r.GetAddressByIpAddress(address)
+}
+
+func allocatePopulatedAddress(ctx context.Context, conn *Conn, subnet Subnet) (string, error) {
+	// a subnet's rows are populated in address order, so the lowest Id is the lowest address. The
+	// gateway is never handed out
+	var rowId int
+	var address string
+	err := conn.QueryRowContext(ctx, "SELECT Id, IpAddress FROM SubnetAddresses WHERE SubnetId = ? AND AssignmentState = 0 AND IpAddress != ? ORDER BY Id LIMIT 1",
+		subnet.Id, subnet.GatewayAddress).Scan(
+		&rowId,
+		&address,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Println("ERROR: No unassigned addresses left in subnet " + subnet.NetworkName)
+			return "", &SubnetExhausted{Err: errors.New("subnet exhausted: " + subnet.NetworkName)}
+		}
+		log.Println("ERROR: Failed to scan free address")
+		return "", err
+	}
+
+	_, err = conn.ExecContext(ctx, "UPDATE SubnetAddresses SET AssignmentState = 1 WHERE Id = ?", rowId)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return "", err
+	}
+
+	return address, nil
+}
+
+func allocateSparseAddress(ctx context.Context, conn *Conn, subnet Subnet) (string, error) {
+	space, err := newSparseRange(subnet)
+	if err != nil {
+		log.Println("ERROR: Failed to work out address range of subnet " + subnet.NetworkName)
+		return "", err
+	}
+
+	taken, err := getSparseTakenAddresses(ctx, conn, subnet, space)
+	if err != nil {
+		return "", err
+	}
+
+	address, ok := space.nextFree(taken)
+	if !ok {
+		log.Println("ERROR: No unassigned addresses left in subnet " + subnet.NetworkName)
+		return "", &SubnetExhausted{Err: errors.New("subnet exhausted: " + subnet.NetworkName)}
+	}
+
+	_, err = conn.ExecContext(ctx, "INSERT INTO SubnetAddresses (SubnetId, IpAddress, AssignmentState) VALUES (?, ?, 1)", subnet.Id, address)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return "", err
+	}
+
+	return address, nil
+}
+
+// getSparseTakenAddresses returns every address of a sparse subnet that can't be handed out,
+// which is the assigned ones plus the gateway
+func getSparseTakenAddresses(ctx context.Context, q queryer, subnet Subnet, space sparseRange) ([]*big.Int, error) {
+	rows, err := q.QueryContext(ctx, "SELECT IpAddress FROM SubnetAddresses WHERE SubnetId = ?", subnet.Id)
+	if err != nil {
+		log.Println("ERROR: Failed to query assigned addresses")
+		return nil, err
+	}
+	defer rows.Close()
+
+	inUse := []string{subnet.GatewayAddress}
+	for rows.Next() {
+		var address string
+		err = rows.Scan(
+			&address,
+		)
+		if err != nil {
+			log.Println("ERROR: Failed to scan assigned address")
+			return nil, err
+		}
+		inUse = append(inUse, address)
+	}
+
+	return space.taken(inUse...), nil
+}
+
+func (r *SqlRepository) GetUnassignedAddressesBySubnetName(snetname string, limit int, offset int) ([]string, error) {
+	log.Println("INFO: Getting unassigned addresses by subnet name: " + snetname)
+	subnet, err := r.GetSubnetByNetworkName(snetname)
+	if err != nil {
+		log.Println("ERROR: Failed to get subnet by subnet name")
+		return nil, err
+	}
+	if subnet.NetworkName == "" {
+		log.Println("ERROR: No subnet found")
+		return nil, &NotFound{Err: fmt.Errorf("no subnet found with name %s", snetname)}
+	}
+
+	// a container's addresses are handed out from the subnets nested in it
+	if subnet.Container {
+		log.Println("INFO: Subnet " + snetname + " is a container block with no addresses of its own")
+		return []string{}, nil
+	}
+
+	if isSparseSubnet(subnet) {
+		space, err := newSparseRange(subnet)
+		if err != nil {
+			log.Println("ERROR: Failed to work out address range of subnet " + snetname)
+			return nil, err
+		}
+		taken, err := getSparseTakenAddresses(context.Background(), r.db, subnet, space)
+		if err != nil {
+			return nil, err
+		}
+
+		// free space is computed rather than stored, so never list all of it
+		if limit <= 0 || limit > defaultSparseListingLimit {
+			limit = defaultSparseListingLimit
+		}
+		addresses := space.free(taken, limit, big.NewInt(int64(offset)))
+
+		log.Println("INFO: Found " + strconv.Itoa(len(addresses)) + " unassigned addresses in subnet " + snetname)
+		return addresses, nil
+	}
+
+	// SQLite can't take an OFFSET without a LIMIT, so no limit is asked for as the largest one
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	rows, err := r.db.Query("SELECT IpAddress FROM SubnetAddresses WHERE SubnetId = ? AND AssignmentState = 0 AND IpAddress != ? ORDER BY Id LIMIT ? OFFSET ?",
+		subnet.Id, subnet.GatewayAddress, limit, offset)
+	if err != nil {
+		log.Println("ERROR: Failed to query unassigned addresses by subnet name")
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := make([]string, 0)
+	for rows.Next() {
+		var address string
+		err = rows.Scan(
+			&address,
+		)
+		if err != nil {
+			log.Println("ERROR: Failed to scan unassigned address")
 			return nil, err
 		}
 		addresses = append(addresses, address)
 	}
 
-	log.Println("INFO: All addresses found")
+	log.Println("INFO: Found " + strconv.Itoa(len(addresses)) + " unassigned addresses in subnet " + snetname)
 	return addresses, nil
 }
+
+func (r *SqlRepository) CountUnassignedAddressesBySubnetName(snetname string) (*big.Int, error) {
+	log.Println("INFO: Counting unassigned addresses by subnet name: " + snetname)
+	subnet, err := r.GetSubnetByNetworkName(snetname)
+	if err != nil {
+		log.Println("ERROR: Failed to get subnet by subnet name")
+		return nil, err
+	}
+	if subnet.NetworkName == "" {
+		log.Println("ERROR: No subnet found")
+		return nil, &NotFound{Err: fmt.Errorf("no subnet found with name %s", snetname)}
+	}
+
+	if subnet.Container {
+		log.Println("INFO: Subnet " + snetname + " is a container block with no addresses of its own")
+		return big.NewInt(0), nil
+	}
+
+	if isSparseSubnet(subnet) {
+		space, err := newSparseRange(subnet)
+		if err != nil {
+			log.Println("ERROR: Failed to work out address range of subnet " + snetname)
+			return nil, err
+		}
+		taken, err := getSparseTakenAddresses(context.Background(), r.db, subnet, space)
+		if err != nil {
+			return nil, err
+		}
+
+		count := space.freeCount(taken)
+		log.Println("INFO: Subnet " + snetname + " has " + count.String() + " unassigned addresses")
+		return count, nil
+	}
+
+	var count int64
+	err = r.db.QueryRow("SELECT COUNT(*) FROM SubnetAddresses WHERE SubnetId = ? AND AssignmentState = 0 AND IpAddress != ?", subnet.Id, subnet.GatewayAddress).Scan(
+		&count,
+	)
+	if err != nil {
+		log.Println("ERROR: Failed to count unassigned addresses")
+		return nil, err
+	}
+
+	log.Println("INFO: Subnet " + snetname + " has " + strconv.FormatInt(count, 10) + " unassigned addresses")
+	return big.NewInt(count), nil
+}
@@ -0,0 +1,195 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+)
+
+// IPv6 subnets are far too large to hold a row per address, so their SubnetAddresses rows
+// are sparse: only addresses that are in use get a row, and the free space is worked out
+// from the prefix and the rows that do exist.
+
+// upper bound on how many free addresses we'll list from a sparse subnet in one request
+const defaultSparseListingLimit = 256
+
+// the largest IPv4 subnet that isn't a container is a /16, as it gets a row for each address
+const minIPv4SubnetBitMask = 16
+
+// reservesEnds reports whether a block of bitmask bits keeps its first address, and for IPv4
+// its last, back from hosts. Point to point links and single addresses use every address
+func reservesEnds(block *ipaddr.IPAddress, bitmask int) bool {
+	return block.GetBitCount()-bitmask > 1
+}
+
+type sparseRange struct {
+	first *big.Int
+	last  *big.Int
+	ipv6  bool
+}
+
+func isSparseSubnet(s Subnet) bool {
+	return ipaddr.NewIPAddressString(s.NetworkPrefix).IsIPv6()
+}
+
+// normaliseAddress returns the canonical string form of an address, so that differently
+// written forms of the same IPv6 address are stored and looked up identically
+func normaliseAddress(address string) string {
+	addr := ipaddr.NewIPAddressString(address).GetAddress()
+	if addr == nil {
+		return address
+	}
+	return addr.WithoutPrefixLen().String()
+}
+
+func newSparseRange(s Subnet) (sparseRange, error) {
+	addr, err := ipaddr.NewIPAddressString(s.NetworkPrefix + "/" + strconv.Itoa(s.BitMask)).ToAddress()
+	if err != nil {
+		return sparseRange{}, err
+	}
+	block := addr.ToPrefixBlock()
+
+	// the all-zeros address of an IPv6 subnet is the subnet-router anycast address, so
+	// hand out addresses from the one after it
+	r := sparseRange{
+		first: block.GetLower().GetValue(),
+		last:  block.GetUpper().GetValue(),
+		ipv6:  block.IsIPv6(),
+	}
+	if reservesEnds(block, s.BitMask) {
+		r.first.Add(r.first, big.NewInt(1))
+	}
+	if r.first.Cmp(r.last) > 0 {
+		return sparseRange{}, errors.New("subnet has no usable addresses")
+	}
+	return r, nil
+}
+
+func (r sparseRange) addressFromValue(value *big.Int) string {
+	size := net.IPv4len
+	if r.ipv6 {
+		size = net.IPv6len
+	}
+	bytes := make([]byte, size)
+	value.FillBytes(bytes)
+
+	if r.ipv6 {
+		addr, _ := ipaddr.NewIPv6AddressFromBytes(bytes)
+		return addr.String()
+	}
+	addr, _ := ipaddr.NewIPv4AddressFromBytes(bytes)
+	return addr.String()
+}
+
+// valueOf returns the numeric value of an address if it falls inside the usable range
+func (r sparseRange) valueOf(address string) (*big.Int, bool) {
+	addr := ipaddr.NewIPAddressString(address).GetAddress()
+	if addr == nil || addr.IsIPv6() != r.ipv6 {
+		return nil, false
+	}
+	value := addr.WithoutPrefixLen().GetValue()
+	if value.Cmp(r.first) < 0 || value.Cmp(r.last) > 0 {
+		return nil, false
+	}
+	return value, true
+}
+
+func (r sparseRange) contains(address string) bool {
+	_, ok := r.valueOf(address)
+	return ok
+}
+
+// taken returns the sorted, de-duplicated values of the given addresses that fall inside
+// the usable range
+func (r sparseRange) taken(addresses ...string) []*big.Int {
+	values := make([]*big.Int, 0, len(addresses))
+	for _, address := range addresses {
+		if value, ok := r.valueOf(address); ok {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Cmp(values[j]) < 0
+	})
+
+	unique := make([]*big.Int, 0, len(values))
+	for _, value := range values {
+		if len(unique) == 0 || unique[len(unique)-1].Cmp(value) != 0 {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// free walks the gaps between the taken addresses in order, skipping offset free
+// addresses before collecting up to limit of them
+func (r sparseRange) free(taken []*big.Int, limit int, offset *big.Int) []string {
+	addresses := make([]string, 0)
+	skip := new(big.Int).Set(offset)
+	cursor := new(big.Int).Set(r.first)
+	one := big.NewInt(1)
+
+	for idx := 0; len(addresses) < limit; idx++ {
+		// the gap runs from the cursor up to, but not including, the next taken address
+		gapEnd := new(big.Int).Add(r.last, one)
+		if idx < len(taken) {
+			gapEnd = taken[idx]
+		}
+
+		gapSize := new(big.Int).Sub(gapEnd, cursor)
+		if gapSize.Sign() > 0 {
+			if skip.Cmp(gapSize) >= 0 {
+				skip.Sub(skip, gapSize)
+			} else {
+				next := new(big.Int).Add(cursor, skip)
+				skip.SetInt64(0)
+				for ; next.Cmp(gapEnd) < 0 && len(addresses) < limit; next.Add(next, one) {
+					addresses = append(addresses, r.addressFromValue(next))
+				}
+			}
+		}
+
+		if idx >= len(taken) {
+			break
+		}
+		cursor = new(big.Int).Add(taken[idx], one)
+	}
+
+	return addresses
+}
+
+func (r sparseRange) nextFree(taken []*big.Int) (string, bool) {
+	addresses := r.free(taken, 1, big.NewInt(0))
+	if len(addresses) == 0 {
+		return "", false
+	}
+	return addresses[0], true
+}
+
+func (r sparseRange) freeCount(taken []*big.Int) *big.Int {
+	size := new(big.Int).Sub(r.last, r.first)
+	size.Add(size, big.NewInt(1))
+	return size.Sub(size, big.NewInt(int64(len(taken))))
+}
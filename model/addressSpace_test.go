@@ -0,0 +1,197 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestSparseRangeFree(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		bitmask int
+		taken   []string
+		limit   int
+		offset  int64
+		want    []string
+	}{
+		{
+			name:    "skips the anycast address",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			limit:   3,
+			want:    []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"},
+		},
+		{
+			name:    "skips taken addresses at the start",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			taken:   []string{"2001:db8::1", "2001:db8::2"},
+			limit:   2,
+			want:    []string{"2001:db8::3", "2001:db8::4"},
+		},
+		{
+			name:    "fills the gaps between taken addresses",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			taken:   []string{"2001:db8::4", "2001:db8::2"},
+			limit:   3,
+			want:    []string{"2001:db8::1", "2001:db8::3", "2001:db8::5"},
+		},
+		{
+			name:    "offset skips whole gaps",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			taken:   []string{"2001:db8::2", "2001:db8::4"},
+			limit:   2,
+			offset:  2,
+			want:    []string{"2001:db8::5", "2001:db8::6"},
+		},
+		{
+			name:    "offset ends part way through a gap",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			taken:   []string{"2001:db8::3"},
+			limit:   3,
+			offset:  1,
+			want:    []string{"2001:db8::2", "2001:db8::4", "2001:db8::5"},
+		},
+		{
+			name:    "stops at the end of the block",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			taken:   []string{"2001:db8::fe"},
+			limit:   5,
+			offset:  252,
+			want:    []string{"2001:db8::fd", "2001:db8::ff"},
+		},
+		{
+			name:    "offset past the end",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			limit:   5,
+			offset:  1000,
+			want:    []string{},
+		},
+		{
+			name:    "ignores addresses outside the block",
+			prefix:  "2001:db8::",
+			bitmask: 126,
+			taken:   []string{"2001:db8::2", "2001:db8::4", "10.0.0.1"},
+			limit:   5,
+			want:    []string{"2001:db8::1", "2001:db8::3"},
+		},
+		{
+			name:    "point to point link uses both addresses",
+			prefix:  "2001:db8::",
+			bitmask: 127,
+			limit:   5,
+			want:    []string{"2001:db8::", "2001:db8::1"},
+		},
+		{
+			name:    "single address",
+			prefix:  "2001:db8::7",
+			bitmask: 128,
+			limit:   5,
+			want:    []string{"2001:db8::7"},
+		},
+		{
+			name:    "fully taken",
+			prefix:  "2001:db8::",
+			bitmask: 127,
+			taken:   []string{"2001:db8::1", "2001:db8::"},
+			limit:   5,
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newSparseRange(Subnet{NetworkPrefix: tt.prefix, BitMask: tt.bitmask})
+			if err != nil {
+				t.Fatalf("newSparseRange: %v", err)
+			}
+			got := r.free(r.taken(tt.taken...), tt.limit, big.NewInt(tt.offset))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("free() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSparseRangeFreeCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		bitmask int
+		taken   []string
+		want    string
+	}{
+		{
+			name:    "empty block less its anycast address",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			want:    "255",
+		},
+		{
+			name:    "taken addresses counted once",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			taken:   []string{"2001:db8::2", "2001:db8::2", "2001:db8::80"},
+			want:    "253",
+		},
+		{
+			name:    "addresses outside the block not counted",
+			prefix:  "2001:db8::",
+			bitmask: 120,
+			taken:   []string{"2001:db8::", "2001:db8::1:1"},
+			want:    "255",
+		},
+		{
+			name:    "a /64 holds more than an int64",
+			prefix:  "2001:db8::",
+			bitmask: 64,
+			taken:   []string{"2001:db8::1"},
+			want:    "18446744073709551614",
+		},
+		{
+			name:    "point to point link",
+			prefix:  "2001:db8::",
+			bitmask: 127,
+			taken:   []string{"2001:db8::"},
+			want:    "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newSparseRange(Subnet{NetworkPrefix: tt.prefix, BitMask: tt.bitmask})
+			if err != nil {
+				t.Fatalf("newSparseRange: %v", err)
+			}
+			got := r.freeCount(r.taken(tt.taken...))
+			if got.String() != tt.want {
+				t.Errorf("freeCount() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+)
+
+// nullableJson stores an absent document as NULL rather than the string 'null'
+func nullableJson(doc json.RawMessage) any {
+	if len(doc) == 0 || string(doc) == "null" {
+		return nil
+	}
+	return string(doc)
+}
+
+func (r *SqlRepository) CreateAuditEntry(e AuditEntry) (bool, error) {
+	t, err := r.db.Begin()
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to record audit entry for " + e.Method + " " + e.Path)
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to record audit entry for " + e.Method + " " + e.Path)
+			t.Rollback()
+		}
+	}()
+
+	q, err := t.Prepare(`INSERT INTO AuditLog (UserName, Method, Route, Path, ObjectType, ObjectId, Before, After, Request, StatusCode, ClientIp)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	_, err = q.Exec(e.UserName, e.Method, e.Route, e.Path, e.ObjectType, e.ObjectId,
+		nullableJson(e.Before), nullableJson(e.After), nullableJson(e.Request), e.StatusCode, e.ClientIp)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetAuditEntries returns the audit entries matching every filter that is set, newest first
+func (r *SqlRepository) GetAuditEntries(f AuditFilter) ([]AuditEntry, error) {
+	log.Println("INFO: Getting audit entries")
+	query := `SELECT Id, UserName, Method, Route, Path, COALESCE(ObjectType, ''), COALESCE(ObjectId, ''),
+	COALESCE(Before, ''), COALESCE(After, ''), COALESCE(Request, ''), StatusCode, COALESCE(ClientIp, ''), CreationDate
+	FROM AuditLog WHERE 1 = 1`
+	args := make([]any, 0)
+	if f.UserName != "" {
+		query += " AND UserName = ?"
+		args = append(args, f.UserName)
+	}
+	if f.ObjectType != "" {
+		query += " AND ObjectType = ?"
+		args = append(args, f.ObjectType)
+	}
+	if f.ObjectId != "" {
+		query += " AND ObjectId = ?"
+		args = append(args, f.ObjectId)
+	}
+	if f.Method != "" {
+		query += " AND Method = ?"
+		args = append(args, f.Method)
+	}
+	if f.Since != "" {
+		query += " AND CreationDate >= ?"
+		args = append(args, f.Since)
+	}
+	if f.Until != "" {
+		query += " AND CreationDate < ?"
+		args = append(args, f.Until)
+	}
+	query += " ORDER BY Id DESC LIMIT ? OFFSET ?"
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		entry := AuditEntry{}
+		var before, after, request string
+		err = rows.Scan(
+			&entry.Id,
+			&entry.UserName,
+			&entry.Method,
+			&entry.Route,
+			&entry.Path,
+			&entry.ObjectType,
+			&entry.ObjectId,
+			&before,
+			&after,
+			&request,
+			&entry.StatusCode,
+			&entry.ClientIp,
+			&entry.CreationDate,
+		)
+		if err != nil {
+			log.Println("ERROR: Failed to scan rows")
+			return nil, err
+		}
+		if before != "" {
+			entry.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			entry.After = json.RawMessage(after)
+		}
+		if request != "" {
+			entry.Request = json.RawMessage(request)
+		}
+		entries = append(entries, entry)
+	}
+
+	log.Println("INFO: Found " + strconv.Itoa(len(entries)) + " audit entries")
+	return entries, nil
+}
@@ -0,0 +1,169 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Database wraps the connection pool so the model's SQL, written with ? placeholders, runs
+// unchanged on every backend. Only the methods the model needs are wrapped, so nothing can
+// reach the pool without going through the dialect
+type Database struct {
+	pool    *sql.DB
+	dialect Dialect
+}
+
+// Tx is a transaction on a Database
+type Tx struct {
+	tx      *sql.Tx
+	dialect Dialect
+}
+
+// Conn is a single connection reserved from a Database's pool
+type Conn struct {
+	conn    *sql.Conn
+	dialect Dialect
+}
+
+func (d *Database) Dialect() Dialect {
+	return d.dialect
+}
+
+// Pool returns the underlying connection pool, for code that writes SQL for a specific backend
+func (d *Database) Pool() *sql.DB {
+	return d.pool
+}
+
+func (d *Database) Close() error {
+	return d.pool.Close()
+}
+
+func (d *Database) Prepare(query string) (*sql.Stmt, error) {
+	return d.pool.Prepare(d.dialect.rebind(query))
+}
+
+func (d *Database) Exec(query string, args ...any) (sql.Result, error) {
+	return d.pool.Exec(d.dialect.rebind(query), args...)
+}
+
+func (d *Database) Query(query string, args ...any) (*sql.Rows, error) {
+	return d.pool.Query(d.dialect.rebind(query), args...)
+}
+
+func (d *Database) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.pool.QueryContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d *Database) QueryRow(query string, args ...any) *sql.Row {
+	return d.pool.QueryRow(d.dialect.rebind(query), args...)
+}
+
+func (d *Database) Begin() (*Tx, error) {
+	tx, err := d.pool.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, dialect: d.dialect}, nil
+}
+
+func (d *Database) Conn(ctx context.Context) (*Conn, error) {
+	conn, err := d.pool.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: conn, dialect: d.dialect}, nil
+}
+
+// beginWrite reserves a connection from the pool and opens a write transaction on it
+// straight away, holding off other writers to the given tables, so a read-then-write sequence
+// can't interleave with another writer. The caller is responsible for issuing COMMIT or
+// ROLLBACK and closing the connection
+func (d *Database) beginWrite(tables ...string) (*Conn, error) {
+	conn, err := d.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.dialect.beginWrite(context.Background(), conn, tables...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (t *Tx) Prepare(query string) (*sql.Stmt, error) {
+	return t.tx.Prepare(t.dialect.rebind(query))
+}
+
+func (t *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+// InsertId runs an INSERT and returns the Id of the row it added
+func (t *Tx) InsertId(query string, args ...any) (int64, error) {
+	return t.dialect.insertId(t, query, args...)
+}
+
+// lockTables holds off other writers to the given tables until the transaction ends, so
+// what it reads from them stays true while it writes
+func (t *Tx) lockTables(tables ...string) error {
+	return t.dialect.lockTables(t, tables...)
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (c *Conn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.conn.ExecContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c *Conn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.conn.QueryContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c *Conn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return c.conn.QueryRowContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// sqlTime formats a time the way the DATETIME columns hold it, in UTC
+func sqlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,255 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/greeneg/ipmanager/db"
+)
+
+// Dialect holds what differs between the database backends. The model's SQL is otherwise
+// written to run on all of them: ? placeholders, COALESCE rather than IFNULL, ON CONFLICT
+// rather than INSERT OR IGNORE, and timestamps passed in as parameters rather than worked
+// out by the database
+type Dialect interface {
+	// Name is the backend's name as used in the configuration, and by the db package to
+	// pick its migrations
+	Name() string
+	open(dsn string) (*sql.DB, error)
+	rebind(query string) string
+	insertId(t *Tx, query string, args ...any) (int64, error)
+	beginWrite(ctx context.Context, conn *Conn, tables ...string) error
+	lockTables(t *Tx, tables ...string) error
+}
+
+func dialectByName(name string) (Dialect, error) {
+	switch name {
+	case "", db.Sqlite:
+		return sqliteDialect{}, nil
+	case db.Postgres:
+		return postgresDialect{}, nil
+	}
+	return nil, errors.New("unknown database driver '" + name + "', expected " + db.Sqlite + " or " + db.Postgres)
+}
+
+// constraintErrorKind sorts out the constraint violations the backends report. A duplicate
+// is a conflict, and a broken reference is reported as something being in use, as that's
+// what it means when a row others still point at is removed. Inserts and updates that
+// take a reference from a request turn the violation into a validation failure of that
+// field with missingReference
+func constraintErrorKind(err error) (ErrorKind, bool) {
+	if foreignKeyViolation(err) {
+		return KindInUse, true
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return KindConflict, true
+		}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return KindConflict, true
+	}
+
+	return KindInternal, false
+}
+
+// foreignKeyViolation reports whether a backend refused a change for breaking a reference
+func foreignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23503"
+	}
+	return false
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return db.Sqlite
+}
+
+func (sqliteDialect) open(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_temp_store=MEMORY&_auto_vacuum=FULL&_synchronous=NORMAL&_tx_locking=IMMEDIATE")
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the appropriate pragmas for SQLite
+	log.Println("NOTICE: Setting foreign keys to ON")
+	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("NOTICE: Setting journal mode to WAL")
+	_, err = db.Exec("PRAGMA journal_mode = WAL")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("NOTICE: Settting tx_locking mode to EXCLUSIVE")
+	log.Println("NOTICE: Setting busy timeout to 5000ms")
+	_, err = db.Exec("PRAGMA busy_timeout = 5000")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("NOTICE: Setting temp store to MEMORY")
+	_, err = db.Exec("PRAGMA temp_store = MEMORY")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("NOTICE: Setting auto_vacuum to FULL")
+	_, err = db.Exec("PRAGMA auto_vacuum = FULL")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("NOTICE: Setting synchronous to NORMAL")
+	_, err = db.Exec("PRAGMA synchronous = NORMAL")
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) insertId(t *Tx, query string, args ...any) (int64, error) {
+	result, err := t.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// SQLite only has the one lock for the whole database, so taking the write lock up front
+// holds off every other writer
+func (sqliteDialect) beginWrite(ctx context.Context, conn *Conn, tables ...string) error {
+	_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+	return err
+}
+
+// a transaction already holds the database's write lock from the moment it begins
+func (sqliteDialect) lockTables(t *Tx, tables ...string) error {
+	return nil
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return db.Postgres
+}
+
+// open connects with a URL or keyword/value connection string. Sessions run in UTC, so
+// CURRENT_TIMESTAMP defaults and the timestamps the model passes in agree with each other
+func (postgresDialect) open(dsn string) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.RuntimeParams["timezone"] = "UTC"
+
+	log.Println("NOTICE: Connecting to PostgreSQL database '" + config.Database + "' on " + config.Host)
+	db := stdlib.OpenDB(*config)
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// rebind numbers the ? placeholders of a query as $1, $2 and so on, leaving alone any that
+// are inside quoted strings or identifiers
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	var quote rune
+	n := 0
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (postgresDialect) insertId(t *Tx, query string, args ...any) (int64, error) {
+	var id int64
+	err := t.QueryRow(query+" RETURNING Id", args...).Scan(&id)
+	return id, err
+}
+
+// PostgreSQL locks rows rather than the database, which doesn't stop two writers both seeing
+// the same row as free, so the tables are locked against other writers for the length of
+// the transaction. Readers aren't held up
+func (postgresDialect) beginWrite(ctx context.Context, conn *Conn, tables ...string) error {
+	_, err := conn.ExecContext(ctx, "BEGIN")
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		_, err = conn.ExecContext(ctx, "LOCK TABLE "+table+" IN SHARE ROW EXCLUSIVE MODE")
+		if err != nil {
+			conn.ExecContext(ctx, "ROLLBACK")
+			return err
+		}
+	}
+	return nil
+}
+
+func (postgresDialect) lockTables(t *Tx, tables ...string) error {
+	for _, table := range tables {
+		_, err := t.Exec("LOCK TABLE " + table + " IN SHARE ROW EXCLUSIVE MODE")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
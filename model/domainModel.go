@@ -20,13 +20,14 @@ package model
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"strconv"
 )
 
-func CreateDomain(d Domain, id int) (bool, error) {
+func (r *SqlRepository) CreateDomain(d Domain, id int) (bool, error) {
 	log.Println("INFO: Creating domain " + d.DomainName)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -64,9 +65,9 @@ func CreateDomain(d Domain, id int) (bool, error) {
 	return true, nil
 }
 
-func DeleteDomain(domain string) (bool, error) {
+func (r *SqlRepository) DeleteDomain(domain string) (bool, error) {
 	log.Println("INFO: Deleting domain " + domain)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -82,17 +83,22 @@ func DeleteDomain(domain string) (bool, error) {
 		}
 	}()
 
-	q, err := DB.Prepare("DELETE FROM Domains WHERE DomainName IS ?")
+	q, err := t.Prepare("DELETE FROM Domains WHERE DomainName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return false, err
 	}
 
-	_, err = q.Exec(domain)
+	result, err := q.Exec(domain)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
 		return false, err
 	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		log.Println("ERROR: No domain found with name " + domain)
+		err = &NotFound{Err: fmt.Errorf("no domain found with name %s", domain)}
+		return false, err
+	}
 
 	err = t.Commit()
 	if err != nil {
@@ -104,10 +110,10 @@ func DeleteDomain(domain string) (bool, error) {
 	return true, nil
 }
 
-func GetDomainById(id int) (Domain, error) {
+func (r *SqlRepository) GetDomainById(id int) (Domain, error) {
 	idStr := strconv.Itoa(id)
 	log.Println("INFO: Getting domain by id " + idStr)
-	rec, err := DB.Prepare("SELECT * FROM Domains WHERE Id = ?")
+	rec, err := r.db.Prepare("SELECT * FROM Domains WHERE Id = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return Domain{}, err
@@ -115,14 +121,7 @@ func GetDomainById(id int) (Domain, error) {
 	defer rec.Close()
 
 	domain := Domain{}
-	r, err := rec.Query(id)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return Domain{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(id).Scan(
 		&domain.Id,
 		&domain.DomainName,
 		&domain.CreatorId,
@@ -141,9 +140,9 @@ func GetDomainById(id int) (Domain, error) {
 	return domain, nil
 }
 
-func GetDomainByDomainName(domainname string) (Domain, error) {
+func (r *SqlRepository) GetDomainByDomainName(domainname string) (Domain, error) {
 	log.Println("INFO: Getting domain by name " + domainname)
-	rec, err := DB.Prepare("SELECT * FROM Domains WHERE DomainName = ?")
+	rec, err := r.db.Prepare("SELECT * FROM Domains WHERE DomainName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return Domain{}, err
@@ -152,14 +151,7 @@ func GetDomainByDomainName(domainname string) (Domain, error) {
 
 	domain := Domain{}
 
-	r, err := rec.Query(domainname)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return Domain{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(domainname).Scan(
 		&domain.Id,
 		&domain.DomainName,
 		&domain.CreatorId,
@@ -178,12 +170,27 @@ func GetDomainByDomainName(domainname string) (Domain, error) {
 	return domain, nil
 }
 
-func GetDomains() ([]Domain, error) {
-	log.Println("INFO: Getting all domains")
-	rows, err := DB.Query("SELECT * FROM Domains")
+var domainListing = listTable{
+	table: "Domains",
+	columns: []listColumn{
+		{name: "Id", kind: intColumn},
+		{name: "DomainName", kind: textColumn},
+		{name: "CreatorId", kind: intColumn},
+		{name: "CreationDate", kind: timeColumn, alias: "created"},
+	},
+}
+
+// GetDomains returns a page of the domains, along with how many match the filters overall
+func (r *SqlRepository) GetDomains(o ListOptions) ([]Domain, int, error) {
+	log.Println("INFO: Getting domains")
+	query, args, total, err := r.list(domainListing, "SELECT Id, DomainName, CreatorId, CreationDate FROM Domains", o)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -197,16 +204,12 @@ func GetDomains() ([]Domain, error) {
 			&domain.CreationDate,
 		)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				log.Println("ERROR: No rows found")
-				return nil, nil
-			}
 			log.Println("ERROR: Failed to scan rows")
-			return nil, err
+			return nil, 0, err
 		}
 		domains = append(domains, domain)
 	}
 
-	log.Println("INFO: Found " + strconv.Itoa(len(domains)) + " domains")
-	return domains, nil
+	log.Println("INFO: Found " + strconv.Itoa(len(domains)) + " of " + strconv.Itoa(total) + " domains")
+	return domains, total, nil
 }
@@ -1,5 +1,180 @@
 package model
 
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrorKind sorts errors by what went wrong, so that the API reports each kind the same way
+// whichever part of the model it came from
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindNotFound
+	KindConflict
+	KindInUse
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+)
+
+// KindOf returns the kind of an error, looking through any wrapping. Anything the model
+// doesn't know about is an internal error
+func KindOf(err error) ErrorKind {
+	var kinded interface{ Kind() ErrorKind }
+	if errors.As(err, &kinded) {
+		return kinded.Kind()
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return KindNotFound
+	}
+	if kind, ok := constraintErrorKind(err); ok {
+		return kind
+	}
+	return KindInternal
+}
+
+// Detail returns what a client is told about an error. The backends' own messages for
+// constraint violations name tables and constraints rather than anything in the request, so
+// they're described by what went wrong instead
+func Detail(err error) string {
+	var kinded interface{ Kind() ErrorKind }
+	if errors.As(err, &kinded) {
+		return err.Error()
+	}
+	if kind, ok := constraintErrorKind(err); ok {
+		switch kind {
+		case KindConflict:
+			return "a record with the same values already exists"
+		case KindInUse:
+			return "the record is still referred to by others"
+		}
+	}
+	return err.Error()
+}
+
+// NotFound is returned when something a request names doesn't exist
+type NotFound struct {
+	Err error
+}
+
+func (n *NotFound) Error() string {
+	return n.Err.Error()
+}
+
+func (n *NotFound) Unwrap() error {
+	return n.Err
+}
+
+func (n *NotFound) Kind() ErrorKind {
+	return KindNotFound
+}
+
+// Conflict is returned when a change clashes with something already stored
+type Conflict struct {
+	Err error
+}
+
+func (c *Conflict) Error() string {
+	return c.Err.Error()
+}
+
+func (c *Conflict) Unwrap() error {
+	return c.Err
+}
+
+func (c *Conflict) Kind() ErrorKind {
+	return KindConflict
+}
+
+// InUse is returned when something can't be changed or removed while other records depend
+// on it
+type InUse struct {
+	Err error
+}
+
+func (i *InUse) Error() string {
+	return i.Err.Error()
+}
+
+func (i *InUse) Unwrap() error {
+	return i.Err
+}
+
+func (i *InUse) Kind() ErrorKind {
+	return KindInUse
+}
+
+// InvalidParam names a field of a request and why its value was refused
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ValidationFailed is returned when a request is well formed but its values can't be
+// accepted. Params lists the fields at fault, where they're known
+type ValidationFailed struct {
+	Params []InvalidParam
+	Err    error
+}
+
+func (v *ValidationFailed) Error() string {
+	return v.Err.Error()
+}
+
+func (v *ValidationFailed) Unwrap() error {
+	return v.Err
+}
+
+func (v *ValidationFailed) Kind() ErrorKind {
+	return KindValidation
+}
+
+// invalidParam is the ValidationFailed for a single field
+func invalidParam(name string, reason string) *ValidationFailed {
+	return &ValidationFailed{
+		Params: []InvalidParam{{Name: name, Reason: reason}},
+		Err:    errors.New(reason),
+	}
+}
+
+// missingReference turns a foreign key violation from an insert or update into a validation
+// failure of the field the reference came from, as it means the request named a record that
+// doesn't exist. Any other error is returned as it is
+func missingReference(err error, name string) error {
+	if !foreignKeyViolation(err) {
+		return err
+	}
+	return invalidParam(name, "no record found for "+name)
+}
+
+// NotAuthenticated is returned when a request doesn't carry valid credentials
+type NotAuthenticated struct {
+	Err error
+}
+
+func (n *NotAuthenticated) Error() string {
+	return n.Err.Error()
+}
+
+func (n *NotAuthenticated) Kind() ErrorKind {
+	return KindUnauthorized
+}
+
+// AccessDenied is returned when the current user isn't allowed to do what was asked
+type AccessDenied struct {
+	Err error
+}
+
+func (a *AccessDenied) Error() string {
+	return a.Err.Error()
+}
+
+func (a *AccessDenied) Kind() ErrorKind {
+	return KindForbidden
+}
+
 type InvalidStatusValue struct {
 	Err error
 }
@@ -8,6 +183,58 @@ func (i *InvalidStatusValue) Error() string {
 	return "Invalid value! Must be either 'enabled' or 'locked'"
 }
 
+func (i *InvalidStatusValue) Kind() ErrorKind {
+	return KindValidation
+}
+
+type InvalidRoleValue struct {
+	Err error
+}
+
+func (i *InvalidRoleValue) Error() string {
+	return "Invalid value! Must be one of 'admin', 'network-operator' or 'read-only'"
+}
+
+func (i *InvalidRoleValue) Kind() ErrorKind {
+	return KindValidation
+}
+
+type InvalidScopeValue struct {
+	Err error
+}
+
+func (i *InvalidScopeValue) Error() string {
+	return "Invalid value! Scopes must be one or more of 'read', 'assign' or 'admin'"
+}
+
+func (i *InvalidScopeValue) Kind() ErrorKind {
+	return KindValidation
+}
+
+type InvalidExpiryValue struct {
+	Err error
+}
+
+func (i *InvalidExpiryValue) Error() string {
+	return "Invalid value! Expiry must be an RFC 3339 timestamp in the future"
+}
+
+func (i *InvalidExpiryValue) Kind() ErrorKind {
+	return KindValidation
+}
+
+type LastAdministrator struct {
+	Err error
+}
+
+func (l *LastAdministrator) Error() string {
+	return "Cannot remove the last enabled administrator"
+}
+
+func (l *LastAdministrator) Kind() ErrorKind {
+	return KindConflict
+}
+
 type AddressTableInUse struct {
 	Err error
 }
@@ -16,6 +243,10 @@ func (p *AddressTableInUse) Error() string {
 	return "Address table already in use. Cannot mutate subnet"
 }
 
+func (p *AddressTableInUse) Kind() ErrorKind {
+	return KindInUse
+}
+
 type PasswordHashMismatch struct {
 	Err error
 }
@@ -23,3 +254,43 @@ type PasswordHashMismatch struct {
 func (p *PasswordHashMismatch) Error() string {
 	return "Password hashes do not match!"
 }
+
+func (p *PasswordHashMismatch) Kind() ErrorKind {
+	return KindValidation
+}
+
+type AddressNotAvailable struct {
+	Err error
+}
+
+func (a *AddressNotAvailable) Error() string {
+	return "Address is either already assigned or not part of the subnet"
+}
+
+func (a *AddressNotAvailable) Kind() ErrorKind {
+	return KindConflict
+}
+
+type AddressNotAssigned struct {
+	Err error
+}
+
+func (a *AddressNotAssigned) Error() string {
+	return "Address is not currently assigned"
+}
+
+func (a *AddressNotAssigned) Kind() ErrorKind {
+	return KindNotFound
+}
+
+type SubnetExhausted struct {
+	Err error
+}
+
+func (s *SubnetExhausted) Error() string {
+	return "No unassigned addresses left in subnet"
+}
+
+func (s *SubnetExhausted) Kind() ErrorKind {
+	return KindConflict
+}
@@ -21,13 +21,14 @@ package model
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"strconv"
 )
 
-func CreateHost(h Host, id int) (bool, error) {
+func (r *SqlRepository) CreateHost(h Host, id int) (bool, error) {
 	log.Println("INFO: Creating host " + h.HostName)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -71,9 +72,9 @@ func CreateHost(h Host, id int) (bool, error) {
 	return true, nil
 }
 
-func DeleteHostname(hostname string) (bool, error) {
+func (r *SqlRepository) DeleteHostname(hostname string) (bool, error) {
 	log.Println("INFO: Deleting host " + hostname)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -95,11 +96,16 @@ func DeleteHostname(hostname string) (bool, error) {
 		return false, err
 	}
 
-	_, err = q.Exec(hostname)
+	result, err := q.Exec(hostname)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
 		return false, err
 	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		log.Println("ERROR: No host found with name " + hostname)
+		err = &NotFound{Err: fmt.Errorf("no host found with name %s", hostname)}
+		return false, err
+	}
 
 	err = t.Commit()
 	if err != nil {
@@ -111,9 +117,9 @@ func DeleteHostname(hostname string) (bool, error) {
 	return true, nil
 }
 
-func UpdateMacAddresses(hostname string, data []string) (bool, error) {
+func (r *SqlRepository) UpdateMacAddresses(hostname string, data []string) (bool, error) {
 	log.Println("INFO: Updating MAC addresses for host " + hostname)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -141,11 +147,16 @@ func UpdateMacAddresses(hostname string, data []string) (bool, error) {
 		return false, err
 	}
 
-	_, err = q.Exec(macAddressSlice, hostname)
+	result, err := q.Exec(macAddressSlice, hostname)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
 		return false, err
 	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		log.Println("ERROR: No host found with name " + hostname)
+		err = &NotFound{Err: fmt.Errorf("no host found with name %s", hostname)}
+		return false, err
+	}
 
 	err = t.Commit()
 	if err != nil {
@@ -157,10 +168,10 @@ func UpdateMacAddresses(hostname string, data []string) (bool, error) {
 	return true, nil
 }
 
-func GetHostById(id int) (Host, error) {
+func (r *SqlRepository) GetHostById(id int) (Host, error) {
 	idStr := strconv.Itoa(id)
 	log.Println("INFO: Getting host by ID: " + idStr)
-	rec, err := DB.Prepare("SELECT * FROM Hosts WHERE Id = ?")
+	rec, err := r.db.Prepare("SELECT * FROM Hosts WHERE Id = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return Host{}, err
@@ -169,14 +180,7 @@ func GetHostById(id int) (Host, error) {
 
 	strHost := StringHost{}
 
-	r, err := rec.Query(id)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return Host{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(id).Scan(
 		&strHost.Id,
 		&strHost.HostName,
 		&strHost.MacAddresses,
@@ -205,9 +209,9 @@ func GetHostById(id int) (Host, error) {
 	return host, nil
 }
 
-func GetHostByHostName(hostname string) (Host, error) {
+func (r *SqlRepository) GetHostByHostName(hostname string) (Host, error) {
 	log.Println("INFO: Getting host by name: " + hostname)
-	rec, err := DB.Prepare("SELECT * FROM Hosts WHERE HostName = ?")
+	rec, err := r.db.Prepare("SELECT * FROM Hosts WHERE HostName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return Host{}, err
@@ -215,14 +219,7 @@ func GetHostByHostName(hostname string) (Host, error) {
 	defer rec.Close()
 
 	strHost := StringHost{}
-	r, err := rec.Query(hostname)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return Host{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(hostname).Scan(
 		&strHost.Id,
 		&strHost.HostName,
 		&strHost.MacAddresses,
@@ -252,12 +249,28 @@ func GetHostByHostName(hostname string) (Host, error) {
 	return host, nil
 }
 
-func GetHosts() ([]Host, error) {
-	log.Println("INFO: Getting all hosts")
-	rows, err := DB.Query("SELECT * FROM Hosts")
+// MacAddresses is a JSON document, so hosts can't be sorted or filtered on it
+var hostListing = listTable{
+	table: "Hosts",
+	columns: []listColumn{
+		{name: "Id", kind: intColumn},
+		{name: "HostName", kind: textColumn},
+		{name: "CreatorId", kind: intColumn},
+		{name: "CreationDate", kind: timeColumn, alias: "created"},
+	},
+}
+
+// GetHosts returns a page of the hosts, along with how many match the filters overall
+func (r *SqlRepository) GetHosts(o ListOptions) ([]Host, int, error) {
+	log.Println("INFO: Getting hosts")
+	query, args, total, err := r.list(hostListing, "SELECT Id, HostName, MacAddresses, CreatorId, CreationDate FROM Hosts", o)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -272,12 +285,8 @@ func GetHosts() ([]Host, error) {
 			&strHost.CreationDate,
 		)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				log.Println("ERROR: No hosts found")
-				return nil, nil
-			}
 			log.Println("ERROR: Failed to scan rows")
-			return nil, err
+			return nil, 0, err
 		}
 
 		// process json of MacAddresses to remove unneeded escapes
@@ -297,6 +306,6 @@ func GetHosts() ([]Host, error) {
 		hosts = append(hosts, host)
 	}
 
-	log.Println("INFO: Found " + strconv.Itoa(len(hosts)) + " hosts")
-	return hosts, nil
+	log.Println("INFO: Found " + strconv.Itoa(len(hosts)) + " of " + strconv.Itoa(total) + " hosts")
+	return hosts, total, nil
 }
@@ -19,53 +19,54 @@ package model
 */
 
 import (
+	"context"
 	"database/sql"
 	"log"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/greeneg/ipmanager/db"
+	"github.com/greeneg/ipmanager/globals"
 )
 
-var DB *sql.DB
+var DB *Database
 
-func ConnectDatabase(dbPath string) error {
-	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_temp_store=MEMORY&_auto_vacuum=FULL&_synchronous=NORMAL&_tx_locking=IMMEDIATE")
-	if err != nil {
-		return err
-	}
+// queryer is satisfied by both the connection pool and a single reserved connection
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
 
-	// Set the appropriate pragmas for SQLite
-	log.Println("NOTICE: Setting foreign keys to ON")
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
-	if err != nil {
-		return err
-	}
-	log.Println("NOTICE: Setting journal mode to WAL")
-	_, err = db.Exec("PRAGMA journal_mode = WAL")
-	if err != nil {
-		return err
-	}
-	log.Println("NOTICE: Settting tx_locking mode to EXCLUSIVE")
-	log.Println("NOTICE: Setting busy timeout to 5000ms")
-	_, err = db.Exec("PRAGMA busy_timeout = 5000")
+// OpenDatabase opens the configured database without touching its schema
+func OpenDatabase(conf globals.DatabaseConfig) error {
+	dialect, err := dialectByName(conf.Driver)
 	if err != nil {
 		return err
 	}
-	log.Println("NOTICE: Setting temp store to MEMORY")
-	_, err = db.Exec("PRAGMA temp_store = MEMORY")
+
+	pool, err := dialect.open(conf.Dsn)
 	if err != nil {
 		return err
 	}
-	log.Println("NOTICE: Setting auto_vacuum to FULL")
-	_, err = db.Exec("PRAGMA auto_vacuum = FULL")
+
+	DB = &Database{pool: pool, dialect: dialect}
+	Repo = NewSqlRepository(DB)
+	return nil
+}
+
+// MigrateDatabase applies any schema migrations the open database is missing
+func MigrateDatabase() error {
+	_, err := db.Migrate(DB.Pool(), DB.Dialect().Name())
 	if err != nil {
+		log.Println("ERROR: Failed to migrate database schema")
 		return err
 	}
-	log.Println("NOTICE: Setting synchronous to NORMAL")
-	_, err = db.Exec("PRAGMA synchronous = NORMAL")
+	return nil
+}
+
+// ConnectDatabase opens the database and brings its schema up to date, creating it if it
+// doesn't exist yet
+func ConnectDatabase(conf globals.DatabaseConfig) error {
+	err := OpenDatabase(conf)
 	if err != nil {
 		return err
 	}
-
-	DB = db
-	return nil
+	return MigrateDatabase()
 }
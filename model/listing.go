@@ -0,0 +1,205 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListOptions picks the page of a list to return, the order it's sorted in and the filters
+// its rows have to match. Fields are named as in the JSON of the listed type, in any case,
+// so creatorId filters on CreatorId. Time fields are filtered by range instead, through
+// their alias with After or Before on the end, such as createdAfter
+type ListOptions struct {
+	// Limit is the most rows to return, with 0 returning every row
+	Limit  int
+	Offset int
+	// Sort holds the fields to sort on, most significant first, each prefixed with - to
+	// sort in descending order. Rows are always sorted by Id last, so pages are stable
+	Sort    []string
+	Filters map[string]string
+	// Fields, when set, is every field the caller may sort or filter on
+	Fields []string
+}
+
+type columnKind int
+
+const (
+	intColumn columnKind = iota
+	textColumn
+	timeColumn
+)
+
+// listColumn is a column a list can be sorted and filtered on
+type listColumn struct {
+	name  string
+	kind  columnKind
+	alias string
+}
+
+// listTable describes how a table is listed
+type listTable struct {
+	table   string
+	columns []listColumn
+}
+
+func (l listTable) allowed(o ListOptions, column listColumn) bool {
+	if o.Fields == nil {
+		return true
+	}
+	for _, field := range o.Fields {
+		if strings.EqualFold(field, column.name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l listTable) column(o ListOptions, field string) (listColumn, bool) {
+	for _, column := range l.columns {
+		if strings.EqualFold(field, column.name) && l.allowed(o, column) {
+			return column, true
+		}
+	}
+	return listColumn{}, false
+}
+
+// rangeColumn finds the time column a createdAfter or createdBefore style filter is on,
+// returning the comparison to make
+func (l listTable) rangeColumn(o ListOptions, field string) (listColumn, string, bool) {
+	lower := strings.ToLower(field)
+	for _, column := range l.columns {
+		if column.kind != timeColumn || !l.allowed(o, column) {
+			continue
+		}
+		switch lower {
+		case column.alias + "after":
+			return column, ">=", true
+		case column.alias + "before":
+			return column, "<", true
+		}
+	}
+	return listColumn{}, "", false
+}
+
+// listTime accepts an RFC 3339 timestamp or a plain date, returning it in the UTC form the
+// database stores
+func listTime(value string) (string, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return sqlTime(t), true
+		}
+	}
+	return "", false
+}
+
+// where builds the WHERE clause for the filters of a list. The filters are applied in name
+// order, so the same options always give the same query
+func (l listTable) where(o ListOptions) (string, []any, error) {
+	fields := make([]string, 0, len(o.Filters))
+	for field := range o.Filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	clause := " WHERE 1 = 1"
+	args := make([]any, 0, len(fields))
+	for _, field := range fields {
+		value := o.Filters[field]
+		if column, op, ok := l.rangeColumn(o, field); ok {
+			t, ok := listTime(value)
+			if !ok {
+				return "", nil, invalidParam(field, "expected an RFC 3339 timestamp or a date for "+field)
+			}
+			clause += " AND " + column.name + " " + op + " ?"
+			args = append(args, t)
+			continue
+		}
+
+		column, ok := l.column(o, field)
+		if !ok || column.kind == timeColumn {
+			return "", nil, invalidParam(field, "cannot filter on "+field)
+		}
+		if column.kind == intColumn {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return "", nil, invalidParam(field, "expected an integer for "+field)
+			}
+			clause += " AND " + column.name + " = ?"
+			args = append(args, n)
+			continue
+		}
+		clause += " AND " + column.name + " = ?"
+		args = append(args, value)
+	}
+
+	return clause, args, nil
+}
+
+func (l listTable) orderBy(o ListOptions) (string, error) {
+	terms := make([]string, 0, len(o.Sort)+1)
+	for _, field := range o.Sort {
+		direction := "ASC"
+		name := field
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			name = field[1:]
+		}
+		column, ok := l.column(o, name)
+		if !ok {
+			return "", invalidParam("sort", "cannot sort on "+name)
+		}
+		terms = append(terms, column.name+" "+direction)
+	}
+	terms = append(terms, "Id ASC")
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// list works out the query for a page of a table's rows, given the query selecting all of
+// them, and counts how many rows match the filters across every page
+func (r *SqlRepository) list(l listTable, query string, o ListOptions) (string, []any, int, error) {
+	where, args, err := l.where(o)
+	if err != nil {
+		log.Println("ERROR: Invalid filter for " + l.table + ": " + err.Error())
+		return "", nil, 0, err
+	}
+	order, err := l.orderBy(o)
+	if err != nil {
+		log.Println("ERROR: Invalid sort for " + l.table + ": " + err.Error())
+		return "", nil, 0, err
+	}
+
+	total := 0
+	err = r.db.QueryRow("SELECT COUNT(*) FROM "+l.table+where, args...).Scan(&total)
+	if err != nil {
+		log.Println("ERROR: Failed to count rows of " + l.table)
+		return "", nil, 0, err
+	}
+
+	query += where + order
+	if o.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, o.Limit, o.Offset)
+	}
+	return query, args, total, nil
+}
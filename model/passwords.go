@@ -0,0 +1,162 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Passwords are stored as argon2id hashes in the PHC string format, which carries the salt
+// and parameters alongside the hash, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// Accounts created before this used an unsalted hex encoded SHA-512. Those hashes are still
+// accepted, and are replaced with an argon2id hash the next time the user logs in.
+//
+// Accounts provisioned for users who log in through an external identity provider store
+// ExternalPasswordHash instead, which no password will ever match.
+
+const (
+	argon2Memory  = 64 * 1024
+	argon2Time    = 3
+	argon2Threads = 2
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+const ExternalPasswordHash = "!external"
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		log.Println("ERROR: Failed to generate password salt")
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func isLegacyPasswordHash(hash string) bool {
+	if len(hash) != sha512.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func decodeArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	// a leading $ leaves an empty first field
+	fields := strings.Split(hash, "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		return argon2Params{}, nil, nil, errors.New("unsupported password hash format")
+	}
+
+	version := 0
+	_, err := fmt.Sscanf(fields[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	p := argon2Params{}
+	_, err = fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads)
+	if err != nil {
+		return argon2Params{}, nil, nil, errors.New("malformed argon2 parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, errors.New("malformed argon2 salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil {
+		return argon2Params{}, nil, nil, errors.New("malformed argon2 hash")
+	}
+
+	return p, salt, key, nil
+}
+
+// VerifyPassword checks a password against a stored hash. needsRehash is set when the
+// password matched a hash that should be replaced, either because it's a legacy SHA-512
+// hash or because it was made with weaker parameters than we use now
+func VerifyPassword(password string, hash string) (matches bool, needsRehash bool, err error) {
+	if hash == ExternalPasswordHash {
+		return false, false, nil
+	}
+
+	if isLegacyPasswordHash(hash) {
+		sum := sha512.Sum512([]byte(password))
+		matches = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(hash))) == 1
+		return matches, matches, nil
+	}
+
+	p, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false, false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash = p.memory < argon2Memory || p.time < argon2Time || p.threads < argon2Threads ||
+		len(salt) < argon2SaltLen || len(key) < argon2KeyLen
+	return true, needsRehash, nil
+}
+
+// RehashPassword replaces a user's stored hash with a fresh argon2id hash of their password.
+// The password itself hasn't changed, so neither does LastChangedDate
+func (r *SqlRepository) RehashPassword(username string, password string) (bool, error) {
+	log.Println("INFO: Upgrading stored password hash for user: " + username)
+	hash, err := HashPassword(password)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.db.Exec("UPDATE Users SET PasswordHash = ? WHERE UserName = ?", hash, username)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+
+	log.Println("INFO: Upgraded stored password hash for user: " + username)
+	return true, nil
+}
@@ -0,0 +1,164 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func legacyHash(password string) string {
+	sum := sha512.Sum512([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// weakHash is an argon2id hash made with weaker parameters than HashPassword uses
+func weakHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 1024, 1, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=1024,t=1,p=1$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestVerifyPassword(t *testing.T) {
+	current, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		hash       string
+		wantMatch  bool
+		wantRehash bool
+		wantErr    bool
+	}{
+		{
+			name:      "current hash",
+			password:  "secret",
+			hash:      current,
+			wantMatch: true,
+		},
+		{
+			name:     "current hash, wrong password",
+			password: "guess",
+			hash:     current,
+		},
+		{
+			name:       "legacy hash",
+			password:   "secret",
+			hash:       legacyHash("secret"),
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:       "legacy hash in upper case",
+			password:   "secret",
+			hash:       strings.ToUpper(legacyHash("secret")),
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:     "legacy hash, wrong password",
+			password: "guess",
+			hash:     legacyHash("secret"),
+		},
+		{
+			name:       "argon2id hash with weaker parameters",
+			password:   "secret",
+			hash:       weakHash("secret"),
+			wantMatch:  true,
+			wantRehash: true,
+		},
+		{
+			name:     "argon2id hash with weaker parameters, wrong password",
+			password: "guess",
+			hash:     weakHash("secret"),
+		},
+		{
+			name:     "external account",
+			password: ExternalPasswordHash,
+			hash:     ExternalPasswordHash,
+		},
+		{
+			name:     "unknown hash format",
+			password: "secret",
+			hash:     "$2y$10$abcdefghijklmnopqrstuv",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, needsRehash, err := VerifyPassword(tt.password, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if matches != tt.wantMatch || needsRehash != tt.wantRehash {
+				t.Errorf("VerifyPassword() = %v, %v, want %v, %v", matches, needsRehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestRehashLegacyPassword(t *testing.T) {
+	d := openTestDatabase(t)
+	r := NewSqlRepository(d)
+	_, err := d.Exec("UPDATE Users SET PasswordHash = ? WHERE UserName = 'admin'", legacyHash("secret"))
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	user, err := r.GetUserByUserName("admin")
+	if err != nil {
+		t.Fatalf("GetUserByUserName: %v", err)
+	}
+	matches, needsRehash, err := VerifyPassword("secret", user.PasswordHash)
+	if err != nil || !matches || !needsRehash {
+		t.Fatalf("VerifyPassword() = %v, %v, %v, want a match needing a rehash", matches, needsRehash, err)
+	}
+
+	_, err = r.RehashPassword("admin", "secret")
+	if err != nil {
+		t.Fatalf("RehashPassword: %v", err)
+	}
+
+	user, err = r.GetUserByUserName("admin")
+	if err != nil {
+		t.Fatalf("GetUserByUserName: %v", err)
+	}
+	if !strings.HasPrefix(user.PasswordHash, "$argon2id$") {
+		t.Fatalf("stored hash %q isn't an argon2id hash", user.PasswordHash)
+	}
+	matches, needsRehash, err = VerifyPassword("secret", user.PasswordHash)
+	if err != nil || !matches || needsRehash {
+		t.Errorf("VerifyPassword() = %v, %v, %v after rehashing, want a match needing nothing more", matches, needsRehash, err)
+	}
+	matches, _, _ = VerifyPassword("guess", user.PasswordHash)
+	if matches {
+		t.Error("wrong password matched the new hash")
+	}
+}
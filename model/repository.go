@@ -0,0 +1,138 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"math/big"
+	"time"
+)
+
+type DomainRepository interface {
+	CreateDomain(d Domain, id int) (bool, error)
+	DeleteDomain(domain string) (bool, error)
+	GetDomainById(id int) (Domain, error)
+	GetDomainByDomainName(domainname string) (Domain, error)
+	GetDomainIdByDomainName(domainname string) (int, error)
+	GetDomains(o ListOptions) ([]Domain, int, error)
+}
+
+type HostRepository interface {
+	CreateHost(h Host, id int) (bool, error)
+	DeleteHostname(hostname string) (bool, error)
+	UpdateMacAddresses(hostname string, data []string) (bool, error)
+	GetHostById(id int) (Host, error)
+	GetHostByHostName(hostname string) (Host, error)
+	GetHostIdByHostname(hostname string) (int, error)
+	GetHosts(o ListOptions) ([]Host, int, error)
+}
+
+type SubnetRepository interface {
+	CreateSubnet(s Subnet, id int) (bool, error)
+	DeleteSubnet(subnetName string) (bool, error)
+	ModifySubnet(subnetName string, json SubnetUpdate) (bool, error)
+	GetSubnetById(id int) (Subnet, error)
+	GetSubnetByNetworkName(snetname string) (Subnet, error)
+	GetSubnetIdBySubnetName(snetname string) (int, error)
+	GetSubnestByDomainId(id int) ([]Subnet, error)
+	GetSubnestByDomainName(domainname string) ([]Subnet, error)
+	GetSubnets(o ListOptions) ([]Subnet, int, error)
+	GetSubnetContaining(ip string) (SubnetMatch, error)
+	GetSubnetTrees() ([]SubnetTree, error)
+	GetSubnetTreeByNetworkName(snetname string) (SubnetTree, error)
+}
+
+type AddressRepository interface {
+	GetAddressById(id int) (Address, error)
+	GetAddressByHostName(hostname string) (Address, error)
+	GetAddressByHostNameId(id int) (Address, error)
+	GetAddressByIpAddress(ip string) (Address, error)
+	GetAddressesByDomainId(id int) ([]Address, error)
+	GetAddressesByDomainName(domainname string) ([]Address, error)
+	GetAddressesBySubnetId(id int) ([]Address, error)
+	GetAddressesBySubnetName(snetname string) ([]Address, error)
+	GetAddresses(o ListOptions) ([]Address, int, error)
+	AssignAddress(a AddressAssignment, id int) (bool, error)
+	ReassignAddress(address string, j AddressReassignment) (bool, error)
+	ReleaseAddress(address string) (bool, error)
+	AllocateAddress(subnetName string, a AddressAllocation, id int) (Address, error)
+	GetUnassignedAddressesBySubnetName(snetname string, limit int, offset int) ([]string, error)
+	CountUnassignedAddressesBySubnetName(snetname string) (*big.Int, error)
+}
+
+type UserRepository interface {
+	ChangeAccountPassword(username string, oldPassword string, newPassword string) (bool, error)
+	RehashPassword(username string, password string) (bool, error)
+	GetUserById(id int) (User, error)
+	GetUserByUserName(username string) (User, error)
+	CreateUser(p ProposedUser) (bool, error)
+	DeleteUser(username string) (bool, error)
+	GetUsers(o ListOptions) ([]User, int, error)
+	GetUserStatus(username string) (string, error)
+	SetUserStatus(username string, j UserStatus) (bool, error)
+	SetUserRole(username string, j UserRole) (bool, error)
+	EnsureExternalUser(username string, role string, syncRole bool) (User, error)
+	EnsureOidcUser(issuer string, subject string, username string, role string, syncRole bool) (User, error)
+}
+
+type TokenRepository interface {
+	CreateApiToken(p ProposedApiToken, userId int) (NewApiToken, error)
+	GetApiTokensByUserId(userId int) ([]ApiToken, error)
+	GetApiTokenOwner(token string) (ApiToken, User, error)
+	DeleteApiToken(tokenId int, userId int) (bool, error)
+}
+
+type SessionRepository interface {
+	SaveSessionData(key string, username any, data string, clientIp string, expiresAt time.Time) error
+	LoadSessionData(key string) (string, bool, error)
+	PurgeExpiredSessions() (int64, error)
+	GetSessionsByUserName(username string) ([]Session, error)
+	DeleteSessionKey(key string) error
+	DeleteSessions(username string, sessionId int) (int64, error)
+}
+
+type AuditRepository interface {
+	CreateAuditEntry(e AuditEntry) (bool, error)
+	GetAuditEntries(f AuditFilter) ([]AuditEntry, error)
+}
+
+// Repository is the storage behind the API's domains, hosts, subnets, addresses and users,
+// along with the tokens, sessions and audit log that go with them
+type Repository interface {
+	DomainRepository
+	HostRepository
+	SubnetRepository
+	AddressRepository
+	UserRepository
+	TokenRepository
+	SessionRepository
+	AuditRepository
+}
+
+// Repo is the repository the controllers work against, set up by ConnectDatabase
+var Repo Repository
+
+// SqlRepository is the Repository for the SQL backends. The SQL is shared between them,
+// with the differences kept to the Database's dialect
+type SqlRepository struct {
+	db *Database
+}
+
+func NewSqlRepository(db *Database) *SqlRepository {
+	return &SqlRepository{db: db}
+}
@@ -0,0 +1,76 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+// Roles are cumulative: a network operator can do everything a read-only user can, and an
+// admin everything a network operator can
+const (
+	RoleAdmin           = "admin"
+	RoleNetworkOperator = "network-operator"
+	RoleReadOnly        = "read-only"
+)
+
+var Roles = []string{RoleAdmin, RoleNetworkOperator, RoleReadOnly}
+
+func IsValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// API token scopes narrow what a token can do on top of its owner's role. Like roles, each
+// scope includes the ones below it
+const (
+	ScopeAdmin  = "admin"
+	ScopeAssign = "assign"
+	ScopeRead   = "read"
+)
+
+var Scopes = []string{ScopeAdmin, ScopeAssign, ScopeRead}
+
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopesGrant reports whether any of the held scopes covers the required one
+func ScopesGrant(held []string, required string) bool {
+	rank := func(scope string) int {
+		for idx, s := range Scopes {
+			if s == scope {
+				return len(Scopes) - idx
+			}
+		}
+		return 0
+	}
+
+	for _, scope := range held {
+		if rank(scope) >= rank(required) && rank(required) > 0 {
+			return true
+		}
+	}
+	return false
+}
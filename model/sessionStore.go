@@ -0,0 +1,284 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+
+	"github.com/greeneg/ipmanager/globals"
+)
+
+// DatabaseSessionStore keeps session data in the Sessions table, leaving only a signed session
+// key in the cookie, so sessions can be listed and revoked server side. It's modelled on
+// gorilla's FilesystemStore
+type DatabaseSessionStore struct {
+	Codecs  []securecookie.Codec
+	Options *gsessions.Options
+	repo    SessionRepository
+}
+
+func NewDatabaseSessionStore(repo SessionRepository, keyPairs ...[]byte) *DatabaseSessionStore {
+	s := &DatabaseSessionStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		repo:   repo,
+		Options: &gsessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+	s.MaxAge(s.Options.MaxAge)
+	return s
+}
+
+// MaxAge sets the lifetime of new sessions, and of the signed keys in their cookies
+func (s *DatabaseSessionStore) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+func (s *DatabaseSessionStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session named by the request's cookie, or a fresh one if there's no
+// cookie or its session has expired or been revoked
+func (s *DatabaseSessionStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
+	if err != nil {
+		// a cookie we can't verify is treated as no session at all
+		session.ID = ""
+		return session, nil
+	}
+
+	found, err := s.load(session)
+	if err != nil {
+		return session, err
+	}
+	if !found {
+		session.ID = ""
+		session.Values = make(map[interface{}]interface{})
+		return session, nil
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// Save writes the session to the table, or deletes it if its MaxAge is negative
+func (s *DatabaseSessionStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			err := s.repo.DeleteSessionKey(session.ID)
+			if err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+	err := s.save(r, session)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func (s *DatabaseSessionStore) save(r *http.Request, session *gsessions.Session) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	var username any
+	if user, ok := session.Values[globals.UserKey]; ok {
+		username = fmt.Sprintf("%v", user)
+	}
+	clientIp := r.RemoteAddr
+	if idx := strings.LastIndex(clientIp, ":"); idx >= 0 {
+		clientIp = strings.Trim(clientIp[:idx], "[]")
+	}
+	expiresAt := time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second)
+
+	return s.repo.SaveSessionData(session.ID, username, encoded, clientIp, expiresAt)
+}
+
+func (s *DatabaseSessionStore) load(session *gsessions.Session) (bool, error) {
+	data, found, err := s.repo.LoadSessionData(session.ID)
+	if err != nil || !found {
+		return false, err
+	}
+
+	err = securecookie.DecodeMulti(session.Name(), data, &session.Values, s.Codecs...)
+	if err != nil {
+		// stored with a key that has since been retired
+		return false, nil
+	}
+	return true, nil
+}
+
+// SaveSessionData stores a session's encoded data under its key, replacing what was there
+func (r *SqlRepository) SaveSessionData(key string, username any, data string, clientIp string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO Sessions (SessionKey, UserName, Data, ClientIp, ExpiresAt) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (SessionKey) DO UPDATE SET UserName = excluded.UserName, Data = excluded.Data, ClientIp = excluded.ClientIp,
+	LastSeenDate = CURRENT_TIMESTAMP, ExpiresAt = excluded.ExpiresAt`,
+		key, username, data, clientIp, sqlTime(expiresAt))
+	if err != nil {
+		log.Println("ERROR: Failed to save session")
+	}
+	return err
+}
+
+// LoadSessionData returns the encoded data of a session that hasn't expired, and whether
+// there was one
+func (r *SqlRepository) LoadSessionData(key string) (string, bool, error) {
+	data := ""
+	err := r.db.QueryRow("SELECT Data FROM Sessions WHERE SessionKey = ? AND ExpiresAt > ?", key, sqlTime(time.Now())).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		log.Println("ERROR: Failed to load session")
+		return "", false, err
+	}
+
+	// recording activity at most once a minute keeps reads from turning into writes
+	_, err = r.db.Exec("UPDATE Sessions SET LastSeenDate = CURRENT_TIMESTAMP WHERE SessionKey = ? AND LastSeenDate < ?", key,
+		sqlTime(time.Now().Add(-time.Minute)))
+	if err != nil {
+		log.Println("WARN: Failed to update session last seen date")
+	}
+	return data, true, nil
+}
+
+// PurgeExpiredSessions removes sessions that are past their expiry
+func (r *SqlRepository) PurgeExpiredSessions() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM Sessions WHERE ExpiresAt <= ?", sqlTime(time.Now()))
+	if err != nil {
+		log.Println("ERROR: Failed to purge expired sessions")
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if purged > 0 {
+		log.Println("INFO: Purged " + strconv.FormatInt(purged, 10) + " expired sessions")
+	}
+	return purged, nil
+}
+
+func (r *SqlRepository) GetSessionsByUserName(username string) ([]Session, error) {
+	log.Println("INFO: Getting sessions for user: " + username)
+	rows, err := r.db.Query("SELECT Id, UserName, ClientIp, CreationDate, LastSeenDate, ExpiresAt FROM Sessions WHERE UserName = ? AND ExpiresAt > ? ORDER BY Id", username, sqlTime(time.Now()))
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]Session, 0)
+	for rows.Next() {
+		session := Session{}
+		var clientIp sql.NullString
+		err = rows.Scan(
+			&session.Id,
+			&session.UserName,
+			&clientIp,
+			&session.CreationDate,
+			&session.LastSeenDate,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			log.Println("ERROR: Failed to scan rows")
+			return nil, err
+		}
+		session.ClientIp = clientIp.String
+		sessions = append(sessions, session)
+	}
+
+	log.Println("INFO: Found " + strconv.Itoa(len(sessions)) + " sessions")
+	return sessions, nil
+}
+
+// DeleteSessionKey drops a session by its key, so the key can't be used again
+func (r *SqlRepository) DeleteSessionKey(key string) error {
+	_, err := r.db.Exec("DELETE FROM Sessions WHERE SessionKey = ?", key)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+	}
+	return err
+}
+
+// DeleteSessions revokes a user's sessions, or only the one with the given Id if it's
+// non-zero, returning how many were revoked
+func (r *SqlRepository) DeleteSessions(username string, sessionId int) (int64, error) {
+	log.Println("INFO: Revoking sessions of user: " + username)
+	query := "DELETE FROM Sessions WHERE UserName = ?"
+	args := []any{username}
+	if sessionId != 0 {
+		query += " AND Id = ?"
+		args = append(args, sessionId)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return 0, err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		log.Println("ERROR: Failed to get number of rows affected")
+		return 0, err
+	}
+
+	log.Println("INFO: Revoked " + strconv.FormatInt(revoked, 10) + " sessions of user " + username)
+	return revoked, nil
+}
@@ -27,116 +27,81 @@ import (
 	"github.com/seancfoley/ipaddress-go/ipaddr"
 )
 
-func createDynamicNetworkTable(networkName string, networkPrefix string, bitmask int) (bool, error) {
-	log.Println("INFO: Creating dynamic table for '" + networkName + "' addresses")
-	t, err := DB.Begin()
-	if err != nil {
-		log.Println("ERROR: Failed to begin transaction")
-		return false, err
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			log.Println("ERROR: Failed to create dynamic table for '" + networkName + "' addresses")
-			t.Rollback()
-		}
-		if err != nil {
-			log.Println("ERROR: Failed to create dynamic table for '" + networkName + "' addresses")
-			t.Rollback()
-		}
-	}()
-
-	createStatement := `CREATE TABLE IF NOT EXISTS ` + networkName + `(
-		Id	            INTEGER PRIMARY KEY AUTOINCREMENT
-						UNIQUE	NOT NULL,
-		IpAddress		STRING	UNIQUE	NOT NULL,
-		AssignmentState	BOOL	NOT NULL	DEFAULT (0)
-	)`
+// subnetColumns selects a subnet in the order its fields are scanned. A subnet that isn't
+// nested under another has a ParentId of 0
+const subnetColumns = "Id, NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, CreationDate, COALESCE(ParentId, 0), Container"
 
-	q, err := t.Prepare(createStatement)
-	if err != nil {
-		log.Println("ERROR: Failed to prepare statement")
-		return false, err
-	}
-
-	_, err = q.Exec()
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return false, err
+// parentId is the value ParentId is stored as, with subnets that aren't nested holding NULL
+// so the column can reference Subnets
+func parentId(s Subnet) any {
+	if s.ParentId == 0 {
+		return nil
 	}
+	return s.ParentId
+}
 
-	err = t.Commit()
-	if err != nil {
-		log.Println("ERROR: Failed to commit transaction")
-		return false, err
+// adoptSubnets nests subnets under the container that's taken them in
+func adoptSubnets(t *Tx, containerId int, adopted []Subnet) error {
+	for _, o := range adopted {
+		log.Println("INFO: Nesting subnet " + o.NetworkName + " under subnet " + strconv.Itoa(containerId))
+		_, err := t.Exec("UPDATE Subnets SET ParentId = ? WHERE Id = ?", containerId, o.Id)
+		if err != nil {
+			log.Println("ERROR: Failed to nest subnet " + o.NetworkName)
+			return err
+		}
 	}
+	return nil
+}
 
-	log.Println("INFO: Populating addresses for dynamic table '" + networkName + "'")
-	_, err = populateAddresses(networkName, networkPrefix, bitmask)
-	if err != nil {
-		log.Println("ERROR: Failed to populate addresses for dynamic table '" + networkName + "'")
-		return false, err
+// populateAddresses adds a row to SubnetAddresses for every host address of an IPv4 subnet.
+// IPv6 prefixes are far too large to hold a row per address, so their rows are only ever
+// added as addresses get assigned
+func populateAddresses(t *Tx, subnetId int, networkPrefix string, bitmask int) error {
+	if ipaddr.NewIPAddressString(networkPrefix).IsIPv6() {
+		log.Println("INFO: Subnet " + strconv.Itoa(subnetId) + " is sparse. Skipping population")
+		return nil
 	}
 
-	log.Println("INFO: Dynamic table for '" + networkName + "' addresses created successfully")
-	return true, nil
-}
-
-func populateAddresses(networkName string, networkPrefix string, bitmask int) (bool, error) {
-	log.Println("INFO: Populating addresses for dynamic table '" + networkName + "'")
-	t, err := DB.Begin()
+	log.Println("INFO: Populating addresses for subnet " + strconv.Itoa(subnetId))
+	q, err := t.Prepare("INSERT INTO SubnetAddresses (SubnetId, IpAddress) VALUES (?, ?)")
 	if err != nil {
-		log.Println("ERROR: Failed to begin transaction")
-		return false, err
+		log.Println("ERROR: Failed to prepare statement")
+		return err
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			log.Println("ERROR: Failed to populate addresses for dynamic table '" + networkName + "'")
-			t.Rollback()
-		}
-		if err != nil {
-			log.Println("ERROR: Failed to populate addresses for dynamic table '" + networkName + "'")
-			t.Rollback()
-		}
-	}()
+	defer q.Close()
 
 	subnet := ipaddr.NewIPAddressString(networkPrefix + "/" + strconv.Itoa(bitmask)).GetAddress().WithoutPrefixLen()
 	netAddr := subnet.GetNetIP()
 	bcastAddr := subnet.GetUpper()
+	reserved := reservesEnds(subnet, bitmask)
 	iterator := subnet.Iterator()
 	for next := iterator.Next(); next != nil; next = iterator.Next() {
-		q, err := t.Prepare("INSERT INTO " + networkName + " (IpAddress) VALUES (?)")
-		if err != nil {
-			log.Println("ERROR: Failed to prepare statement")
-			return false, err
-		}
-
 		address := fmt.Sprintf("%s", next)
-		if address == netAddr.String() {
+		if reserved && address == netAddr.String() {
 			continue
 		}
-		if address == bcastAddr.String() {
+		if reserved && address == bcastAddr.String() {
 			continue
 		}
-		_, err = q.Exec(address)
+		_, err = q.Exec(subnetId, address)
 		if err != nil {
 			log.Println("ERROR: Failed to execute statement")
-			return false, err
+			return err
 		}
 	}
 
-	err = t.Commit()
+	log.Println("INFO: Addresses for subnet " + strconv.Itoa(subnetId) + " populated successfully")
+	return nil
+}
+
+func (r *SqlRepository) CreateSubnet(s Subnet, id int) (bool, error) {
+	log.Println("INFO: Creating subnet " + s.NetworkName)
+	s, block, err := checkSubnetAddressing(s)
 	if err != nil {
-		log.Println("ERROR: Failed to commit transaction")
 		return false, err
 	}
 
-	log.Println("INFO: Addresses for dynamic table '" + networkName + "' populated successfully")
-	return true, nil
-}
-
-func CreateSubnet(s Subnet, id int) (bool, error) {
-	log.Println("INFO: Creating subnet " + s.NetworkName)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -152,64 +117,42 @@ func CreateSubnet(s Subnet, id int) (bool, error) {
 		}
 	}()
 
-	q, err := t.Prepare("INSERT INTO Subnets (NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId) VALUES (?, ?, ?, ?, ?, ?)")
+	err = t.lockTables("Subnets", "SubnetAddresses")
 	if err != nil {
-		log.Println("ERROR: Failed to prepare statement")
+		log.Println("ERROR: Failed to lock subnets")
 		return false, err
 	}
-
-	_, err = q.Exec(s.NetworkName, s.NetworkPrefix, s.BitMask, s.GatewayAddress, s.DomainId, id)
+	s.Id = 0
+	err = checkSubnetDomain(t, s)
 	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
 		return false, err
 	}
-
-	err = t.Commit()
+	adopted, err := checkSubnetPlacement(t, s, block)
 	if err != nil {
-		log.Println("ERROR: Failed to commit transaction")
 		return false, err
 	}
 
-	// now create the dynamic table for the ip range
-	log.Printf("INFO: Creating dynamic table for '" + s.NetworkName + "' addresses")
-	_, err = createDynamicNetworkTable(s.NetworkName, s.NetworkPrefix, s.BitMask)
+	subnetId, err := t.InsertId("INSERT INTO Subnets (NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, ParentId, Container) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		s.NetworkName, s.NetworkPrefix, s.BitMask, s.GatewayAddress, s.DomainId, id, parentId(s), s.Container)
 	if err != nil {
-		log.Println("ERROR: Failed to create dynamic table for '" + s.NetworkName + "' addresses")
+		log.Println("ERROR: Failed to execute statement")
+		err = missingReference(err, "DomainId")
 		return false, err
 	}
 
-	log.Println("INFO: Subnet " + s.NetworkName + " created successfully")
-	return true, nil
-}
-
-func dropDynamicNetworkTable(subnetName string) (bool, error) {
-	log.Println("INFO: Dropping dynamic table for '" + subnetName + "' addresses")
-	t, err := DB.Begin()
+	err = adoptSubnets(t, int(subnetId), adopted)
 	if err != nil {
-		log.Println("ERROR: Failed to begin transaction")
 		return false, err
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			log.Println("ERROR: Failed to drop dynamic table for '" + subnetName + "' addresses")
-			t.Rollback()
-		}
+
+	// the subnet and its addresses are created together, or not at all. A container's
+	// addresses belong to the subnets nested in it
+	if !s.Container {
+		err = populateAddresses(t, int(subnetId), s.NetworkPrefix, s.BitMask)
 		if err != nil {
-			log.Println("ERROR: Failed to drop dynamic table for '" + subnetName + "' addresses")
-			t.Rollback()
+			log.Println("ERROR: Failed to populate addresses for subnet " + s.NetworkName)
+			return false, err
 		}
-	}()
-
-	q, err := DB.Prepare("DROP TABLE IF EXISTS " + subnetName)
-	if err != nil {
-		log.Println("ERROR: Failed to prepare statement")
-		return false, err
-	}
-
-	_, err = q.Exec()
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return false, err
 	}
 
 	err = t.Commit()
@@ -218,13 +161,13 @@ func dropDynamicNetworkTable(subnetName string) (bool, error) {
 		return false, err
 	}
 
-	log.Println("INFO: Dynamic table for '" + subnetName + "' addresses dropped successfully")
+	log.Println("INFO: Subnet " + s.NetworkName + " created successfully")
 	return true, nil
 }
 
-func DeleteSubnet(subnetName string) (bool, error) {
+func (r *SqlRepository) DeleteSubnet(subnetName string) (bool, error) {
 	log.Println("INFO: Deleting subnet " + subnetName)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -240,29 +183,44 @@ func DeleteSubnet(subnetName string) (bool, error) {
 		}
 	}()
 
-	q, err := DB.Prepare("DELETE FROM Subnets WHERE NetworkName IS ?")
+	// a subnet with addresses still assigned can't go, nor can one others are nested under
+	err = checkAddressTableInUse(t, subnetName)
 	if err != nil {
-		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+	var nested int
+	err = t.QueryRow("SELECT COUNT(*) FROM Subnets WHERE ParentId IN (SELECT Id FROM Subnets WHERE NetworkName = ?)", subnetName).Scan(&nested)
+	if err != nil {
+		log.Println("ERROR: Failed to count subnets nested under " + subnetName)
+		return false, err
+	}
+	if nested != 0 {
+		log.Println("ERROR: Subnet " + subnetName + " has subnets nested under it")
+		err = &InUse{Err: fmt.Errorf("subnet %s has %d subnets nested under it", subnetName, nested)}
 		return false, err
 	}
 
-	_, err = q.Exec(subnetName)
+	// the subnet's rows in SubnetAddresses go with it
+	q, err := t.Prepare("DELETE FROM Subnets WHERE NetworkName = ?")
 	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
+		log.Println("ERROR: Failed to prepare statement")
 		return false, err
 	}
 
-	err = t.Commit()
+	result, err := q.Exec(subnetName)
 	if err != nil {
-		log.Println("ERROR: Failed to commit transaction")
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		log.Println("ERROR: No subnet found with name " + subnetName)
+		err = &NotFound{Err: fmt.Errorf("no subnet found with name %s", subnetName)}
 		return false, err
 	}
 
-	// now drop the network's table
-	log.Println("INFO: Dropping dynamic table '" + subnetName + "'")
-	_, err = dropDynamicNetworkTable(subnetName)
+	err = t.Commit()
 	if err != nil {
-		log.Println("ERROR: Failed to drop dynamic table '" + subnetName + "'")
+		log.Println("ERROR: Failed to commit transaction")
 		return false, err
 	}
 
@@ -270,50 +228,70 @@ func DeleteSubnet(subnetName string) (bool, error) {
 	return true, nil
 }
 
-func checkAddressTableInUse(subnetName string) error {
-	log.Println("INFO: Checking if address table '" + subnetName + "' is in use")
-	q, err := DB.Prepare("SELECT COUNT(*) FROM " + subnetName + " WHERE AssignmentState = 1")
-	if err != nil {
-		log.Println("ERROR: Failed to prepare statement")
-		return err
-	}
-	defer q.Close()
-
-	var val string
-	r, err := q.Query()
+// checkAddressTableInUse returns AddressTableInUse if any of the subnet's addresses are
+// assigned. It runs inside the caller's transaction so the answer holds until it commits
+func checkAddressTableInUse(t *Tx, subnetName string) error {
+	log.Println("INFO: Checking if addresses of subnet '" + subnetName + "' are in use")
+	var num int
+	err := t.QueryRow(`SELECT COUNT(*) FROM SubnetAddresses
+	INNER JOIN Subnets ON Subnets.Id = SubnetAddresses.SubnetId
+	WHERE Subnets.NetworkName = ? AND SubnetAddresses.AssignmentState = 1`, subnetName).Scan(&num)
 	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return err
-	}
-	defer r.Close()
-
-	err = r.Scan(&val)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Println("ERROR: No rows found")
-			return err
-		}
 		log.Println("ERROR: Failed to scan result")
 		return err
 	}
-
-	num, err := strconv.Atoi(val)
-	if err != nil {
-		log.Println("ERROR: Failed to convert string to int")
-		return err
-	}
 	if num != 0 {
+		log.Println("ERROR: Addresses of subnet '" + subnetName + "' are in use")
 		a := new(AddressTableInUse)
 		return a
 	}
 
-	log.Println("INFO: Address table '" + subnetName + "' is not in use")
+	log.Println("INFO: Addresses of subnet '" + subnetName + "' are not in use")
 	return nil
 }
 
-func ModifySubnet(subnetName string, json SubnetUpdate) (bool, error) {
+// applyTo returns a subnet with the fields set in the update changed
+func (u SubnetUpdate) applyTo(s Subnet) Subnet {
+	if u.NetworkPrefix != nil {
+		s.NetworkPrefix = *u.NetworkPrefix
+	}
+	if u.BitMask != nil {
+		s.BitMask = *u.BitMask
+	}
+	if u.GatewayAddress != nil {
+		s.GatewayAddress = *u.GatewayAddress
+	}
+	if u.ParentId != nil {
+		s.ParentId = *u.ParentId
+	}
+	if u.Container != nil {
+		s.Container = *u.Container
+	}
+	return s
+}
+
+// ModifySubnet changes the fields of a subnet set in the update, checking the result as a
+// new subnet would be. The address rows are only rebuilt when the block changes or the
+// subnet becomes or stops being a container, which isn't possible while any are assigned
+func (r *SqlRepository) ModifySubnet(subnetName string, json SubnetUpdate) (bool, error) {
 	log.Println("INFO: Modifying subnet " + subnetName)
-	t, err := DB.Begin()
+
+	// get the DomainId from the DomainName
+	domainId := 0
+	if json.DomainName != nil {
+		d, err := r.GetDomainByDomainName(*json.DomainName)
+		if err != nil {
+			log.Println("ERROR: Failed to get DomainId from DomainName")
+			return false, err
+		}
+		if d.DomainName == "" {
+			log.Println("ERROR: No domain found with name " + *json.DomainName)
+			return false, invalidParam("DomainName", "no domain found with name "+*json.DomainName)
+		}
+		domainId = d.Id
+	}
+
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -329,59 +307,103 @@ func ModifySubnet(subnetName string, json SubnetUpdate) (bool, error) {
 		}
 	}()
 
-	// check if the address table for the network has any entries that are in use. if so, error out with
-	// network in use error
-	err = checkAddressTableInUse(subnetName)
-	if err != nil { // first deal with any errors
-		if _, ok := err.(*AddressTableInUse); ok {
-			log.Println("ERROR: Address table '" + subnetName + "' is in use")
-			return false, err
-		}
-		log.Println("ERROR: Failed to check address table '" + subnetName + "'")
+	err = t.lockTables("Subnets", "SubnetAddresses")
+	if err != nil {
+		log.Println("ERROR: Failed to lock subnets")
 		return false, err
 	}
 
-	q, err := t.Prepare("UPDATE Subnets SET NetworkPrefix =?, BitMask = ?, GatewayAddress = ?, DomainId =? WHERE NetworkName = ?")
+	rows, err := t.Query("SELECT "+subnetColumns+" FROM Subnets WHERE NetworkName = ?", subnetName)
 	if err != nil {
-		log.Println("ERROR: Failed to prepare statement")
+		log.Println("ERROR: Failed to query subnet")
+		return false, err
+	}
+	found, err := scanSubnets(rows)
+	if err != nil {
+		return false, err
+	}
+	if len(found) == 0 {
+		log.Println("ERROR: No subnet found with name " + subnetName)
+		err = &NotFound{Err: fmt.Errorf("no subnet found with name %s", subnetName)}
+		return false, err
+	}
+	stored := found[0]
+	storedBlock, err := subnetBlock(stored)
+	if err != nil {
+		log.Println("ERROR: Unable to parse prefix of subnet " + subnetName)
 		return false, err
 	}
 
-	// get our needed values from the JSON sent in
-	s := new(SubnetUpdate)
-	s.NetworkPrefix = json.NetworkPrefix
-	s.BitMask = json.BitMask
-	s.GatewayAddress = json.GatewayAddress
-	s.DomainName = json.DomainName
-
-	// get the DomainId from the DomainName
-	d, err := GetDomainByDomainName(s.DomainName)
+	s, block, err := checkSubnetAddressing(json.applyTo(stored))
 	if err != nil {
-		log.Println("ERROR: Failed to get DomainId from DomainName")
 		return false, err
 	}
+	if domainId != 0 {
+		s.DomainId = domainId
+	}
 
-	_, err = q.Exec(s.NetworkPrefix, s.BitMask, s.GatewayAddress, d.Id, subnetName)
+	rebuild := !block.Equal(storedBlock) || s.Container != stored.Container
+	if rebuild {
+		err = checkAddressTableInUse(t, subnetName)
+		if err != nil {
+			return false, err
+		}
+	} else if s.GatewayAddress != stored.GatewayAddress {
+		// the addresses stay, so the new gateway mustn't be one already handed out
+		var assigned int
+		err = t.QueryRow("SELECT COUNT(*) FROM AssignedAddresses WHERE Address = ?", s.GatewayAddress).Scan(&assigned)
+		if err != nil {
+			log.Println("ERROR: Failed to check whether gateway " + s.GatewayAddress + " is assigned")
+			return false, err
+		}
+		if assigned != 0 {
+			log.Println("ERROR: Gateway " + s.GatewayAddress + " is assigned to a host")
+			err = invalidParam("GatewayAddress", s.GatewayAddress+" is assigned to a host")
+			return false, err
+		}
+	}
+
+	err = checkSubnetDomain(t, s)
+	if err != nil {
+		return false, err
+	}
+	adopted, err := checkSubnetPlacement(t, s, block)
+	if err != nil {
+		return false, err
+	}
+	err = adoptSubnets(t, s.Id, adopted)
 	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
 		return false, err
 	}
 
-	// Now that we've updated the prefix, we need to drop the address table and recreate it with the new
-	// settings
-	// first, drop old table
-	_, err = dropDynamicNetworkTable(subnetName)
+	q, err := t.Prepare("UPDATE Subnets SET NetworkPrefix = ?, BitMask = ?, GatewayAddress = ?, DomainId = ?, ParentId = ?, Container = ? WHERE Id = ?")
 	if err != nil {
-		log.Println("ERROR: Failed to drop dynamic table '" + subnetName + "'")
+		log.Println("ERROR: Failed to prepare statement")
 		return false, err
 	}
-	// now create the new dynamic table
-	_, err = createDynamicNetworkTable(subnetName, s.NetworkPrefix, s.BitMask)
+
+	_, err = q.Exec(s.NetworkPrefix, s.BitMask, s.GatewayAddress, s.DomainId, parentId(s), s.Container, s.Id)
 	if err != nil {
-		log.Println("ERROR: Failed to create dynamic table for '" + subnetName + "' addresses")
+		log.Println("ERROR: Failed to execute statement")
+		err = missingReference(err, "DomainName")
 		return false, err
 	}
 
+	if rebuild {
+		_, err = t.Exec("DELETE FROM SubnetAddresses WHERE SubnetId = ?", s.Id)
+		if err != nil {
+			log.Println("ERROR: Failed to clear addresses of subnet '" + subnetName + "'")
+			return false, err
+		}
+		if !s.Container {
+			err = populateAddresses(t, s.Id, s.NetworkPrefix, s.BitMask)
+			if err != nil {
+				log.Println("ERROR: Failed to populate addresses for subnet '" + subnetName + "'")
+				return false, err
+			}
+		}
+	}
+
 	err = t.Commit()
 	if err != nil {
 		log.Println("ERROR: Failed to commit transaction")
@@ -392,9 +414,9 @@ func ModifySubnet(subnetName string, json SubnetUpdate) (bool, error) {
 	return true, nil
 }
 
-func GetSubnetById(id int) (Subnet, error) {
+func (r *SqlRepository) GetSubnetById(id int) (Subnet, error) {
 	log.Println("INFO: Getting subnet by id " + strconv.Itoa(id))
-	rec, err := DB.Prepare("SELECT * FROM Subnets WHERE Id = ?")
+	rec, err := r.db.Prepare("SELECT " + subnetColumns + " FROM Subnets WHERE Id = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return Subnet{}, err
@@ -402,14 +424,7 @@ func GetSubnetById(id int) (Subnet, error) {
 	defer rec.Close()
 
 	subnet := Subnet{}
-	r, err := rec.Query(id)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return Subnet{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(id).Scan(
 		&subnet.Id,
 		&subnet.NetworkName,
 		&subnet.NetworkPrefix,
@@ -418,11 +433,13 @@ func GetSubnetById(id int) (Subnet, error) {
 		&subnet.DomainId,
 		&subnet.CreatorId,
 		&subnet.CreationDate,
+		&subnet.ParentId,
+		&subnet.Container,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Println("ERROR: No rows found")
-			return Subnet{}, err
+			return Subnet{}, nil
 		}
 		log.Println("ERROR: Failed to scan rows")
 		return Subnet{}, err
@@ -432,9 +449,9 @@ func GetSubnetById(id int) (Subnet, error) {
 	return subnet, nil
 }
 
-func GetSubnetByNetworkName(snetname string) (Subnet, error) {
+func (r *SqlRepository) GetSubnetByNetworkName(snetname string) (Subnet, error) {
 	log.Println("INFO: Getting subnet by name " + snetname)
-	rec, err := DB.Prepare("SELECT * FROM Subnets WHERE NetworkName = ?")
+	rec, err := r.db.Prepare("SELECT " + subnetColumns + " FROM Subnets WHERE NetworkName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return Subnet{}, err
@@ -443,18 +460,7 @@ func GetSubnetByNetworkName(snetname string) (Subnet, error) {
 
 	subnet := Subnet{}
 
-	r, err := rec.Query(snetname)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Println("ERROR: No rows found")
-			return Subnet{}, err
-		}
-		log.Println("ERROR: Failed to execute statement")
-		return Subnet{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(snetname).Scan(
 		&subnet.Id,
 		&subnet.NetworkName,
 		&subnet.NetworkPrefix,
@@ -463,11 +469,13 @@ func GetSubnetByNetworkName(snetname string) (Subnet, error) {
 		&subnet.DomainId,
 		&subnet.CreatorId,
 		&subnet.CreationDate,
+		&subnet.ParentId,
+		&subnet.Container,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Println("ERROR: No rows found")
-			return Subnet{}, err
+			return Subnet{}, nil
 		}
 		log.Println("ERROR: Failed to scan rows")
 		return Subnet{}, err
@@ -477,9 +485,9 @@ func GetSubnetByNetworkName(snetname string) (Subnet, error) {
 	return subnet, nil
 }
 
-func GetSubnestByDomainId(id int) ([]Subnet, error) {
+func (r *SqlRepository) GetSubnestByDomainId(id int) ([]Subnet, error) {
 	log.Println("INFO: Getting subnets by domain id " + strconv.Itoa(id))
-	rows, err := DB.Query("SELECT * FROM Subnets WHERE DomainId = ?", id)
+	rows, err := r.db.Query("SELECT "+subnetColumns+" FROM Subnets WHERE DomainId = ?", id)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
 		return nil, err
@@ -498,6 +506,8 @@ func GetSubnestByDomainId(id int) ([]Subnet, error) {
 			&snet.DomainId,
 			&snet.CreatorId,
 			&snet.CreationDate,
+			&snet.ParentId,
+			&snet.Container,
 		)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -514,19 +524,19 @@ func GetSubnestByDomainId(id int) ([]Subnet, error) {
 	return subnets, nil
 }
 
-func GetSubnestByDomainName(domainname string) ([]Subnet, error) {
+func (r *SqlRepository) GetSubnestByDomainName(domainname string) ([]Subnet, error) {
 	log.Println("INFO: Getting subnets by domain name " + domainname)
-	id, err := GetDomainIdByDomainName(domainname)
+	id, err := r.GetDomainIdByDomainName(domainname)
 	if err != nil {
 		log.Println("ERROR: Failed to get domain id by domain name")
 		return nil, err
 	}
 	if id == 0 {
 		log.Println("ERROR: No domain found with name " + domainname)
-		return nil, fmt.Errorf("no domain found with name %s", domainname)
+		return nil, &NotFound{Err: fmt.Errorf("no domain found with name %s", domainname)}
 	}
 
-	rows, err := DB.Query("SELECT * FROM Subnets WHERE DomainId = ?", id)
+	rows, err := r.db.Query("SELECT "+subnetColumns+" FROM Subnets WHERE DomainId = ?", id)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
 		return nil, err
@@ -545,6 +555,8 @@ func GetSubnestByDomainName(domainname string) ([]Subnet, error) {
 			&snet.DomainId,
 			&snet.CreatorId,
 			&snet.CreationDate,
+			&snet.ParentId,
+			&snet.Container,
 		)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -561,12 +573,32 @@ func GetSubnestByDomainName(domainname string) ([]Subnet, error) {
 	return subnets, nil
 }
 
-func GetSubnets() ([]Subnet, error) {
-	log.Println("INFO: Getting all subnets")
-	rows, err := DB.Query("SELECT * FROM Subnets")
+var subnetListing = listTable{
+	table: "Subnets",
+	columns: []listColumn{
+		{name: "Id", kind: intColumn},
+		{name: "NetworkName", kind: textColumn},
+		{name: "NetworkPrefix", kind: textColumn},
+		{name: "BitMask", kind: intColumn},
+		{name: "GatewayAddress", kind: textColumn},
+		{name: "DomainId", kind: intColumn},
+		{name: "CreatorId", kind: intColumn},
+		{name: "CreationDate", kind: timeColumn, alias: "created"},
+		{name: "ParentId", kind: intColumn},
+	},
+}
+
+// GetSubnets returns a page of the subnets, along with how many match the filters overall
+func (r *SqlRepository) GetSubnets(o ListOptions) ([]Subnet, int, error) {
+	log.Println("INFO: Getting subnets")
+	query, args, total, err := r.list(subnetListing, "SELECT "+subnetColumns+" FROM Subnets", o)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -582,18 +614,16 @@ func GetSubnets() ([]Subnet, error) {
 			&snet.DomainId,
 			&snet.CreatorId,
 			&snet.CreationDate,
+			&snet.ParentId,
+			&snet.Container,
 		)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				log.Println("ERROR: No rows found")
-				return nil, err
-			}
 			log.Println("ERROR: Failed to scan rows")
-			return nil, err
+			return nil, 0, err
 		}
 		subnets = append(subnets, snet)
 	}
 
-	log.Println("INFO: Found " + strconv.Itoa(len(subnets)) + " subnets")
-	return subnets, nil
+	log.Println("INFO: Found " + strconv.Itoa(len(subnets)) + " of " + strconv.Itoa(total) + " subnets")
+	return subnets, total, nil
 }
@@ -0,0 +1,230 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+)
+
+// subnetForest holds every subnet by the subnet it's nested under, in address order, along
+// with how many addresses each has assigned
+type subnetForest struct {
+	children map[int][]Subnet
+	assigned map[int]int64
+}
+
+// usage is a block's utilisation while it's being added up
+type usage struct {
+	size      *big.Int
+	allocated *big.Int
+	usable    *big.Int
+	assigned  *big.Int
+}
+
+// usableAddresses is how many addresses of a subnet's block can be assigned. The network
+// address, an IPv4 block's broadcast address and the gateway are never handed out, though
+// point to point links and single addresses only hold back their gateway
+func usableAddresses(s Subnet, block *ipaddr.IPAddress) *big.Int {
+	var reserved []*ipaddr.IPAddress
+	if reservesEnds(block, s.BitMask) {
+		reserved = append(reserved, block.GetLower().WithoutPrefixLen())
+		if block.IsIPv4() {
+			reserved = append(reserved, block.GetUpper().WithoutPrefixLen())
+		}
+	}
+	usable := new(big.Int).Sub(block.GetCount(), big.NewInt(int64(len(reserved))))
+
+	gateway := ipaddr.NewIPAddressString(s.GatewayAddress).GetAddress()
+	if gateway == nil || !block.Contains(gateway) {
+		return usable
+	}
+	for _, addr := range reserved {
+		if addr.Equal(gateway.WithoutPrefixLen()) {
+			return usable
+		}
+	}
+	return usable.Sub(usable, big.NewInt(1))
+}
+
+// percent is part as a percentage of whole, to two decimal places
+func percent(part *big.Int, whole *big.Int) float64 {
+	if whole.Sign() == 0 {
+		return 0
+	}
+	f, _ := new(big.Rat).SetFrac(new(big.Int).Mul(part, big.NewInt(100)), whole).Float64()
+	return math.Round(f*100) / 100
+}
+
+func (r *SqlRepository) loadSubnetForest() (subnetForest, error) {
+	subnets, _, err := r.GetSubnets(ListOptions{})
+	if err != nil {
+		log.Println("ERROR: Failed to get subnets")
+		return subnetForest{}, err
+	}
+
+	// order the subnets by where their blocks start, IPv4 first, so each level of the tree
+	// comes out in address order
+	blocks := make(map[int]*ipaddr.IPAddress)
+	for _, s := range subnets {
+		block, err := subnetBlock(s)
+		if err != nil {
+			log.Println("ERROR: Unable to parse prefix of subnet " + s.NetworkName)
+			return subnetForest{}, err
+		}
+		blocks[s.Id] = block
+	}
+	sort.SliceStable(subnets, func(i, j int) bool {
+		a, b := blocks[subnets[i].Id], blocks[subnets[j].Id]
+		if a.IsIPv6() != b.IsIPv6() {
+			return b.IsIPv6()
+		}
+		if c := a.GetValue().Cmp(b.GetValue()); c != 0 {
+			return c < 0
+		}
+		return subnets[i].BitMask < subnets[j].BitMask
+	})
+
+	f := subnetForest{
+		children: make(map[int][]Subnet),
+		assigned: make(map[int]int64),
+	}
+	for _, s := range subnets {
+		f.children[s.ParentId] = append(f.children[s.ParentId], s)
+	}
+
+	rows, err := r.db.Query("SELECT SubnetId, COUNT(*) FROM AssignedAddresses GROUP BY SubnetId")
+	if err != nil {
+		log.Println("ERROR: Failed to count assigned addresses")
+		return subnetForest{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var count int64
+		err = rows.Scan(&id, &count)
+		if err != nil {
+			log.Println("ERROR: Failed to scan rows")
+			return subnetForest{}, err
+		}
+		f.assigned[id] = count
+	}
+
+	return f, rows.Err()
+}
+
+// build puts together the tree under a subnet. A subnet's utilisation is its own, while a
+// container's is added up from the subnets nested in it
+func (f subnetForest) build(s Subnet) (SubnetTree, usage, error) {
+	block, err := subnetBlock(s)
+	if err != nil {
+		log.Println("ERROR: Unable to parse prefix of subnet " + s.NetworkName)
+		return SubnetTree{}, usage{}, err
+	}
+
+	tree := SubnetTree{Subnet: s, Children: make([]SubnetTree, 0)}
+	u := usage{
+		size:      block.GetCount(),
+		allocated: new(big.Int),
+		usable:    new(big.Int),
+		assigned:  new(big.Int),
+	}
+	if !s.Container {
+		u.allocated.Set(u.size)
+		u.usable = usableAddresses(s, block)
+		u.assigned.SetInt64(f.assigned[s.Id])
+	}
+
+	for _, child := range f.children[s.Id] {
+		childTree, childUsage, err := f.build(child)
+		if err != nil {
+			return SubnetTree{}, usage{}, err
+		}
+		tree.Children = append(tree.Children, childTree)
+		if s.Container {
+			u.allocated.Add(u.allocated, childUsage.size)
+			u.usable.Add(u.usable, childUsage.usable)
+			u.assigned.Add(u.assigned, childUsage.assigned)
+		}
+	}
+
+	tree.Utilisation = SubnetUtilisation{
+		Size:             u.size.String(),
+		Allocated:        u.allocated.String(),
+		Usable:           u.usable.String(),
+		Assigned:         u.assigned.String(),
+		AllocatedPercent: percent(u.allocated, u.size),
+		AssignedPercent:  percent(u.assigned, u.usable),
+	}
+	return tree, u, nil
+}
+
+// GetSubnetTrees returns every subnet that isn't nested in another, with the subnets nested
+// under each
+func (r *SqlRepository) GetSubnetTrees() ([]SubnetTree, error) {
+	log.Println("INFO: Getting subnet trees")
+	f, err := r.loadSubnetForest()
+	if err != nil {
+		return nil, err
+	}
+
+	trees := make([]SubnetTree, 0, len(f.children[0]))
+	for _, s := range f.children[0] {
+		tree, _, err := f.build(s)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+
+	log.Println("INFO: Found " + strconv.Itoa(len(trees)) + " top level subnets")
+	return trees, nil
+}
+
+// GetSubnetTreeByNetworkName returns a subnet with the subnets nested under it
+func (r *SqlRepository) GetSubnetTreeByNetworkName(snetname string) (SubnetTree, error) {
+	log.Println("INFO: Getting subnet tree of " + snetname)
+	subnet, err := r.GetSubnetByNetworkName(snetname)
+	if err != nil {
+		log.Println("ERROR: Failed to get subnet by name")
+		return SubnetTree{}, err
+	}
+	if subnet.NetworkName == "" {
+		log.Println("ERROR: No subnet found with name " + snetname)
+		return SubnetTree{}, &NotFound{Err: fmt.Errorf("no subnet found with name %s", snetname)}
+	}
+
+	f, err := r.loadSubnetForest()
+	if err != nil {
+		return SubnetTree{}, err
+	}
+	tree, _, err := f.build(subnet)
+	if err != nil {
+		return SubnetTree{}, err
+	}
+
+	log.Println("INFO: Subnet " + snetname + " has " + tree.Utilisation.Assigned + " of " + tree.Utilisation.Usable + " addresses assigned")
+	return tree, nil
+}
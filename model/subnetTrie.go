@@ -0,0 +1,201 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+)
+
+// what an address is used for within the subnet holding it
+const (
+	AddressAssigned   = "assigned"
+	AddressUnassigned = "unassigned"
+	AddressGateway    = "gateway"
+	// the network and broadcast addresses of an IPv4 subnet, and the subnet-router
+	// anycast address of an IPv6 one, which are never handed out
+	AddressReserved = "reserved"
+	// inside a container block, but not any of the subnets nested in it
+	AddressUnallocated = "unallocated"
+)
+
+// subnetTrie indexes subnets by their prefix block. A trie only holds the one IP version,
+// so there's one for each
+type subnetTrie struct {
+	v4 ipaddr.AssociativeTrie[*ipaddr.IPAddress, Subnet]
+	v6 ipaddr.AssociativeTrie[*ipaddr.IPAddress, Subnet]
+}
+
+func subnetBlock(s Subnet) (*ipaddr.IPAddress, error) {
+	addr, err := ipaddr.NewIPAddressString(s.NetworkPrefix + "/" + strconv.Itoa(s.BitMask)).ToAddress()
+	if err != nil {
+		return nil, err
+	}
+	return addr.ToPrefixBlock(), nil
+}
+
+func newSubnetTrie(subnets []Subnet) (*subnetTrie, error) {
+	trie := &subnetTrie{}
+	for _, s := range subnets {
+		block, err := subnetBlock(s)
+		if err != nil {
+			log.Println("ERROR: Unable to parse prefix of subnet " + s.NetworkName)
+			return nil, err
+		}
+		trie.forVersion(block).Put(block, s)
+	}
+	return trie, nil
+}
+
+func (t *subnetTrie) forVersion(addr *ipaddr.IPAddress) *ipaddr.AssociativeTrie[*ipaddr.IPAddress, Subnet] {
+	if addr.IsIPv6() {
+		return &t.v6
+	}
+	return &t.v4
+}
+
+// longestMatch returns the most specific subnet holding an address
+func (t *subnetTrie) longestMatch(addr *ipaddr.IPAddress) (Subnet, bool) {
+	node := t.forVersion(addr).LongestPrefixMatchNode(addr)
+	if node == nil {
+		return Subnet{}, false
+	}
+	return node.GetValue(), true
+}
+
+// containing returns the subnets whose block holds the given one, including one with the
+// same block, from the least to the most specific
+func (t *subnetTrie) containing(block *ipaddr.IPAddress) []Subnet {
+	var subnets []Subnet
+	trie := t.forVersion(block)
+	// the lookup doesn't cope with a trie that has nothing in it
+	if trie.IsEmpty() {
+		return nil
+	}
+	for node := trie.ElementsContaining(block).ShortestPrefixMatch(); node != nil; node = node.Next() {
+		subnets = append(subnets, node.GetValue())
+	}
+	return subnets
+}
+
+// containedBy returns the subnets whose block lies inside the given one, leaving out one
+// with the same block
+func (t *subnetTrie) containedBy(block *ipaddr.IPAddress) []Subnet {
+	var subnets []Subnet
+	root := t.forVersion(block).ElementsContainedBy(block)
+	if root == nil {
+		return nil
+	}
+	iterator := root.NodeIterator(true)
+	for node := iterator.Next(); node != nil; node = iterator.Next() {
+		if node.GetKey().Equal(block) {
+			continue
+		}
+		subnets = append(subnets, node.GetValue())
+	}
+	return subnets
+}
+
+// addressState works out what an address that isn't assigned is used for in its subnet
+func addressState(s Subnet, block *ipaddr.IPAddress, addr *ipaddr.IPAddress) string {
+	if s.Container {
+		return AddressUnallocated
+	}
+	if normaliseAddress(s.GatewayAddress) == addr.String() {
+		return AddressGateway
+	}
+	if !reservesEnds(block, s.BitMask) {
+		return AddressUnassigned
+	}
+	if addr.Equal(block.GetLower().WithoutPrefixLen()) {
+		return AddressReserved
+	}
+	if block.IsIPv4() && addr.Equal(block.GetUpper().WithoutPrefixLen()) {
+		return AddressReserved
+	}
+	return AddressUnassigned
+}
+
+// GetSubnetContaining finds the most specific subnet an address falls in, along with the
+// subnet's domain and what the address is used for
+func (r *SqlRepository) GetSubnetContaining(ip string) (SubnetMatch, error) {
+	log.Println("INFO: Getting subnet containing " + ip)
+	addr := ipaddr.NewIPAddressString(ip).GetAddress()
+	if addr == nil || addr.IsMultiple() {
+		log.Println("ERROR: Invalid IP address " + ip)
+		return SubnetMatch{}, invalidParam("ip", "'"+ip+"' is not an IP address")
+	}
+	addr = addr.WithoutPrefixLen()
+
+	subnets, _, err := r.GetSubnets(ListOptions{})
+	if err != nil {
+		log.Println("ERROR: Failed to get subnets")
+		return SubnetMatch{}, err
+	}
+	trie, err := newSubnetTrie(subnets)
+	if err != nil {
+		return SubnetMatch{}, err
+	}
+
+	subnet, ok := trie.longestMatch(addr)
+	if !ok {
+		log.Println("ERROR: No subnet contains " + ip)
+		return SubnetMatch{}, &NotFound{Err: errors.New("no subnet contains " + addr.String())}
+	}
+
+	domain, err := r.GetDomainById(subnet.DomainId)
+	if err != nil {
+		log.Println("ERROR: Failed to get domain of subnet " + subnet.NetworkName)
+		return SubnetMatch{}, err
+	}
+
+	match := SubnetMatch{
+		Address: addr.String(),
+		Subnet:  subnet,
+		Domain:  domain,
+	}
+
+	assigned, err := r.GetAddressByIpAddress(match.Address)
+	if err != nil {
+		log.Println("ERROR: Failed to get assignment of " + match.Address)
+		return SubnetMatch{}, err
+	}
+	if assigned.Address != "" {
+		host, err := r.GetHostById(assigned.HostNameId)
+		if err != nil {
+			log.Println("ERROR: Failed to get host " + strconv.Itoa(assigned.HostNameId))
+			return SubnetMatch{}, err
+		}
+		match.AssignmentState = AddressAssigned
+		match.Assignment = &assigned
+		match.HostName = host.HostName
+	} else {
+		block, err := subnetBlock(subnet)
+		if err != nil {
+			return SubnetMatch{}, err
+		}
+		match.AssignmentState = addressState(subnet, block, addr)
+	}
+
+	log.Println("INFO: Address " + match.Address + " is " + match.AssignmentState + " in subnet " + subnet.NetworkName)
+	return match, nil
+}
@@ -0,0 +1,348 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/seancfoley/ipaddress-go/ipaddr"
+)
+
+// containerQuery reads whether a subnet is a container block
+const containerQuery = "SELECT Container FROM Subnets WHERE Id = ?"
+
+// validationFailed gathers every field found at fault into the one error
+func validationFailed(params []InvalidParam) error {
+	if len(params) == 0 {
+		return nil
+	}
+	reasons := make([]string, 0, len(params))
+	for _, p := range params {
+		reasons = append(reasons, p.Reason)
+	}
+	return &ValidationFailed{Params: params, Err: errors.New(strings.Join(reasons, "; "))}
+}
+
+func blockString(s Subnet) string {
+	return s.NetworkPrefix + "/" + strconv.Itoa(s.BitMask)
+}
+
+// checkSubnetAddressing checks that a subnet's prefix is the network address of a block of
+// BitMask bits, and that its gateway is a host address inside that block, or that it has none
+// if it's a container. It returns the subnet with both addresses in canonical form, along with
+// the block
+func checkSubnetAddressing(s Subnet) (Subnet, *ipaddr.IPAddress, error) {
+	var params []InvalidParam
+	fail := func(name string, reason string) {
+		params = append(params, InvalidParam{Name: name, Reason: reason})
+	}
+
+	var block *ipaddr.IPAddress
+	addr := ipaddr.NewIPAddressString(s.NetworkPrefix).GetAddress()
+	switch {
+	case strings.Contains(s.NetworkPrefix, "/"):
+		fail("NetworkPrefix", "NetworkPrefix takes the address alone, with its length in BitMask")
+	case addr == nil || addr.IsMultiple():
+		fail("NetworkPrefix", "'"+s.NetworkPrefix+"' is not an IP address")
+	case s.BitMask < 0 || s.BitMask > addr.GetBitCount():
+		fail("BitMask", "BitMask must be between 0 and "+strconv.Itoa(addr.GetBitCount())+" for "+addr.GetIPVersion().String())
+	default:
+		addr = addr.WithoutPrefixLen()
+		block = addr.ToPrefixBlockLen(s.BitMask)
+		network := block.GetLower().WithoutPrefixLen()
+		if !network.Equal(addr) {
+			fail("NetworkPrefix", addr.String()+" is not on a /"+strconv.Itoa(s.BitMask)+" boundary, the network address is "+network.String())
+		}
+		s.NetworkPrefix = network.String()
+		if block.IsIPv4() && !s.Container && s.BitMask < minIPv4SubnetBitMask {
+			fail("BitMask", "an IPv4 subnet can be no larger than a /"+strconv.Itoa(minIPv4SubnetBitMask)+"; make a larger block a container and nest subnets under it")
+		}
+	}
+
+	gateway := ipaddr.NewIPAddressString(s.GatewayAddress).GetAddress()
+	switch {
+	case s.Container:
+		if s.GatewayAddress != "" {
+			fail("GatewayAddress", "a container block has no gateway")
+		}
+	case s.GatewayAddress == "":
+		fail("GatewayAddress", "GatewayAddress is required")
+	case strings.Contains(s.GatewayAddress, "/") || gateway == nil || gateway.IsMultiple():
+		fail("GatewayAddress", "'"+s.GatewayAddress+"' is not an IP address")
+	case block == nil:
+		// nothing to check the gateway against
+	case gateway.GetIPVersion() != block.GetIPVersion():
+		fail("GatewayAddress", "GatewayAddress must be an "+block.GetIPVersion().String()+" address, like the prefix")
+	case !block.Contains(gateway):
+		fail("GatewayAddress", gateway.WithoutPrefixLen().String()+" is outside "+blockString(s))
+	default:
+		gateway = gateway.WithoutPrefixLen()
+		// point to point links use both of their addresses, as do single addresses
+		reserved := reservesEnds(block, s.BitMask)
+		if reserved && gateway.Equal(block.GetLower().WithoutPrefixLen()) {
+			if block.IsIPv4() {
+				fail("GatewayAddress", gateway.String()+" is the network address of "+blockString(s))
+			} else {
+				fail("GatewayAddress", gateway.String()+" is the subnet-router anycast address of "+blockString(s))
+			}
+		}
+		if reserved && block.IsIPv4() && gateway.Equal(block.GetUpper().WithoutPrefixLen()) {
+			fail("GatewayAddress", gateway.String()+" is the broadcast address of "+blockString(s))
+		}
+		s.GatewayAddress = gateway.String()
+	}
+
+	err := validationFailed(params)
+	if err != nil {
+		log.Println("ERROR: Invalid subnet " + s.NetworkName + ": " + err.Error())
+		return s, nil, err
+	}
+	return s, block, nil
+}
+
+func scanSubnets(rows *sql.Rows) ([]Subnet, error) {
+	defer rows.Close()
+	subnets := make([]Subnet, 0)
+	for rows.Next() {
+		var s Subnet
+		err := rows.Scan(
+			&s.Id,
+			&s.NetworkName,
+			&s.NetworkPrefix,
+			&s.BitMask,
+			&s.GatewayAddress,
+			&s.DomainId,
+			&s.CreatorId,
+			&s.CreationDate,
+			&s.ParentId,
+			&s.Container,
+		)
+		if err != nil {
+			log.Println("ERROR: Failed to scan rows")
+			return nil, err
+		}
+		subnets = append(subnets, s)
+	}
+	return subnets, rows.Err()
+}
+
+// descendantsOf returns the Ids of every subnet nested under a subnet, however deeply
+func descendantsOf(id int, subnets []Subnet) map[int]bool {
+	children := make(map[int][]int)
+	for _, s := range subnets {
+		children[s.ParentId] = append(children[s.ParentId], s.Id)
+	}
+	descendants := make(map[int]bool)
+	if id == 0 {
+		return descendants
+	}
+	pending := children[id]
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+		if descendants[next] {
+			continue
+		}
+		descendants[next] = true
+		pending = append(pending, children[next]...)
+	}
+	return descendants
+}
+
+// checkSubnetDomain makes sure the domain a subnet is put in exists
+func checkSubnetDomain(t *Tx, s Subnet) error {
+	var count int
+	err := t.QueryRow("SELECT COUNT(*) FROM Domains WHERE Id = ?", s.DomainId).Scan(&count)
+	if err != nil {
+		log.Println("ERROR: Failed to look up domain of subnet " + s.NetworkName)
+		return err
+	}
+	if count == 0 {
+		log.Println("ERROR: No domain found with Id " + strconv.Itoa(s.DomainId))
+		return invalidParam("DomainId", "no domain found with Id "+strconv.Itoa(s.DomainId))
+	}
+	return nil
+}
+
+// checkSubnetPlacement makes sure a subnet's block only overlaps the container it's nested
+// under and that container's own ancestors or, when an existing subnet is changed, the
+// subnets nested under it, which have to stay inside it. The parent has to be the most
+// specific subnet holding the block. A container can also take in the subnets alongside it
+// that fall in its block, which are returned for the caller to nest under it. It runs inside
+// the caller's transaction once Subnets is locked, so nothing can be placed in the way before
+// it commits
+func checkSubnetPlacement(t *Tx, s Subnet, block *ipaddr.IPAddress) ([]Subnet, error) {
+	log.Println("INFO: Checking placement of subnet " + s.NetworkName + " at " + blockString(s))
+	rows, err := t.Query("SELECT " + subnetColumns + " FROM Subnets")
+	if err != nil {
+		log.Println("ERROR: Failed to query subnets")
+		return nil, err
+	}
+	subnets, err := scanSubnets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	descendants := descendantsOf(s.Id, subnets)
+	others := make([]Subnet, 0, len(subnets))
+	byId := make(map[int]Subnet)
+	for _, o := range subnets {
+		if o.Id == s.Id {
+			continue
+		}
+		others = append(others, o)
+		byId[o.Id] = o
+	}
+	trie, err := newSubnetTrie(others)
+	if err != nil {
+		return nil, err
+	}
+
+	var params []InvalidParam
+	overlaps := func(o Subnet, detail string) {
+		params = append(params, InvalidParam{
+			Name:   "NetworkPrefix",
+			Reason: blockString(s) + " overlaps subnet " + o.NetworkName + " (" + blockString(o) + ")" + detail,
+		})
+	}
+
+	// the subnets holding the block, least specific first. The last one that isn't nested
+	// under this subnet is where it belongs
+	var enclosing *Subnet
+	ancestors := make(map[int]bool)
+	for _, o := range trie.containing(block) {
+		switch {
+		case o.BitMask == s.BitMask:
+			overlaps(o, ", which has the same block")
+		case descendants[o.Id]:
+			overlaps(o, ", which is nested under it")
+		default:
+			o := o
+			enclosing = &o
+			ancestors[o.Id] = true
+		}
+	}
+
+	if s.ParentId == 0 {
+		if enclosing != nil {
+			overlaps(*enclosing, "; set ParentId to "+strconv.Itoa(enclosing.Id)+" to nest it there")
+		}
+	} else {
+		parent, ok := byId[s.ParentId]
+		switch {
+		case !ok:
+			params = append(params, InvalidParam{Name: "ParentId", Reason: "no subnet found with Id " + strconv.Itoa(s.ParentId)})
+		case descendants[parent.Id]:
+			params = append(params, InvalidParam{Name: "ParentId", Reason: "subnet " + parent.NetworkName + " is nested under " + s.NetworkName})
+		case !parent.Container:
+			params = append(params, InvalidParam{Name: "ParentId", Reason: "subnet " + parent.NetworkName + " isn't a container block, so nothing can be nested under it"})
+		case !ancestors[parent.Id]:
+			params = append(params, InvalidParam{Name: "ParentId", Reason: "subnet " + parent.NetworkName + " (" + blockString(parent) + ") doesn't contain " + blockString(s)})
+		case enclosing.Id != parent.Id:
+			params = append(params, InvalidParam{
+				Name:   "ParentId",
+				Reason: "subnet " + enclosing.NetworkName + " (" + blockString(*enclosing) + ") lies between " + blockString(s) + " and " + parent.NetworkName + "; set ParentId to " + strconv.Itoa(enclosing.Id),
+			})
+		}
+	}
+
+	// a container takes in the subnets alongside it that fall in its block, along with
+	// whatever is nested under them
+	var adopted []Subnet
+	inside := make(map[int]bool)
+	contained := trie.containedBy(block)
+	for _, o := range contained {
+		inside[o.Id] = true
+	}
+	for _, o := range contained {
+		if descendants[o.Id] {
+			continue
+		}
+		if !s.Container {
+			overlaps(o, ", which would lie inside it; only a container block can hold other subnets")
+			continue
+		}
+		if o.ParentId == s.ParentId {
+			adopted = append(adopted, o)
+			continue
+		}
+		top := o
+		for top.ParentId != s.ParentId && inside[top.ParentId] {
+			top = byId[top.ParentId]
+		}
+		if top.ParentId != s.ParentId {
+			overlaps(o, ", which would lie inside it")
+		}
+	}
+
+	// the subnets nested under it stay where they are, so have to fit in the new block.
+	// Those nested further down are inside these
+	nested := len(adopted)
+	for _, o := range others {
+		if o.ParentId != s.Id || s.Id == 0 {
+			continue
+		}
+		nested++
+		child, err := subnetBlock(o)
+		if err != nil {
+			return nil, err
+		}
+		if !block.Contains(child) {
+			params = append(params, InvalidParam{
+				Name:   "NetworkPrefix",
+				Reason: "subnet " + o.NetworkName + " (" + blockString(o) + ") is nested under " + s.NetworkName + " and would fall outside " + blockString(s),
+			})
+		}
+	}
+	if nested != 0 && !s.Container {
+		params = append(params, InvalidParam{
+			Name:   "Container",
+			Reason: s.NetworkName + " has " + strconv.Itoa(nested) + " subnets nested under it, so has to stay a container",
+		})
+	}
+
+	err = validationFailed(params)
+	if err != nil {
+		log.Println("ERROR: Subnet " + s.NetworkName + " can't be placed at " + blockString(s) + ": " + err.Error())
+		return nil, err
+	}
+
+	return adopted, nil
+}
+
+// checkNotContainer refuses to hand out addresses from a container block, as its addresses
+// belong to the subnets nested in it. It takes the row read with containerQuery, so the
+// answer comes from the caller's transaction
+func checkNotContainer(row *sql.Row, s Subnet) error {
+	var container bool
+	err := row.Scan(&container)
+	if err != nil {
+		log.Println("ERROR: Failed to check whether subnet " + s.NetworkName + " is a container")
+		return err
+	}
+	if container {
+		log.Println("ERROR: Subnet " + s.NetworkName + " is a container block")
+		return &Conflict{Err: errors.New("subnet " + s.NetworkName + " is a container block, so addresses are assigned from the subnets in it")}
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/greeneg/ipmanager/db"
+)
+
+// paramNames returns the names of the fields a validation error found at fault
+func paramNames(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var failed *ValidationFailed
+	if !errors.As(err, &failed) {
+		t.Fatalf("got %T (%v), want a validation failure", err, err)
+	}
+	names := make([]string, 0, len(failed.Params))
+	for _, p := range failed.Params {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestCheckSubnetAddressing(t *testing.T) {
+	tests := []struct {
+		name        string
+		subnet      Subnet
+		wantParams  []string
+		wantReason  string
+		wantPrefix  string
+		wantGateway string
+	}{
+		{
+			name:        "IPv4 subnet",
+			subnet:      Subnet{NetworkPrefix: "10.1.2.0", BitMask: 24, GatewayAddress: "10.1.2.1"},
+			wantPrefix:  "10.1.2.0",
+			wantGateway: "10.1.2.1",
+		},
+		{
+			name:       "prefix given with its length",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.0/24", BitMask: 24, GatewayAddress: "10.1.2.1"},
+			wantParams: []string{"NetworkPrefix"},
+		},
+		{
+			name:       "prefix that isn't an address",
+			subnet:     Subnet{NetworkPrefix: "lab", BitMask: 24, GatewayAddress: "10.1.2.1"},
+			wantParams: []string{"NetworkPrefix"},
+		},
+		{
+			name:       "IPv4 mask too long",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.0", BitMask: 33, GatewayAddress: "10.1.2.1"},
+			wantParams: []string{"BitMask"},
+		},
+		{
+			name:       "prefix off its boundary",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.5", BitMask: 24, GatewayAddress: "10.1.2.1"},
+			wantParams: []string{"NetworkPrefix"},
+			wantReason: "the network address is 10.1.2.0",
+		},
+		{
+			name:       "IPv4 subnet larger than a /16",
+			subnet:     Subnet{NetworkPrefix: "10.0.0.0", BitMask: 8, GatewayAddress: "10.0.0.1"},
+			wantParams: []string{"BitMask"},
+		},
+		{
+			name:       "gateway missing",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.0", BitMask: 24},
+			wantParams: []string{"GatewayAddress"},
+		},
+		{
+			name:       "gateway outside the block",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.0", BitMask: 24, GatewayAddress: "10.1.3.1"},
+			wantParams: []string{"GatewayAddress"},
+			wantReason: "is outside 10.1.2.0/24",
+		},
+		{
+			name:       "gateway of the other IP version",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.0", BitMask: 24, GatewayAddress: "2001:db8::1"},
+			wantParams: []string{"GatewayAddress"},
+		},
+		{
+			name:       "gateway on the network address",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.0", BitMask: 24, GatewayAddress: "10.1.2.0"},
+			wantParams: []string{"GatewayAddress"},
+			wantReason: "network address",
+		},
+		{
+			name:       "gateway on the broadcast address",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.0", BitMask: 24, GatewayAddress: "10.1.2.255"},
+			wantParams: []string{"GatewayAddress"},
+			wantReason: "broadcast address",
+		},
+		{
+			name:        "point to point link gateway on its first address",
+			subnet:      Subnet{NetworkPrefix: "10.1.2.6", BitMask: 31, GatewayAddress: "10.1.2.6"},
+			wantPrefix:  "10.1.2.6",
+			wantGateway: "10.1.2.6",
+		},
+		{
+			name:        "point to point link gateway on its last address",
+			subnet:      Subnet{NetworkPrefix: "10.1.2.6", BitMask: 31, GatewayAddress: "10.1.2.7"},
+			wantPrefix:  "10.1.2.6",
+			wantGateway: "10.1.2.7",
+		},
+		{
+			name:        "single address",
+			subnet:      Subnet{NetworkPrefix: "10.1.2.9", BitMask: 32, GatewayAddress: "10.1.2.9"},
+			wantPrefix:  "10.1.2.9",
+			wantGateway: "10.1.2.9",
+		},
+		{
+			name:       "IPv6 gateway on the anycast address",
+			subnet:     Subnet{NetworkPrefix: "2001:db8::", BitMask: 64, GatewayAddress: "2001:db8::"},
+			wantParams: []string{"GatewayAddress"},
+			wantReason: "subnet-router anycast address",
+		},
+		{
+			name:        "IPv6 has no broadcast address",
+			subnet:      Subnet{NetworkPrefix: "2001:db8::", BitMask: 64, GatewayAddress: "2001:db8::ffff:ffff:ffff:ffff"},
+			wantPrefix:  "2001:db8::",
+			wantGateway: "2001:db8::ffff:ffff:ffff:ffff",
+		},
+		{
+			name:        "IPv6 point to point link",
+			subnet:      Subnet{NetworkPrefix: "2001:db8::", BitMask: 127, GatewayAddress: "2001:db8::"},
+			wantPrefix:  "2001:db8::",
+			wantGateway: "2001:db8::",
+		},
+		{
+			name:        "IPv6 addresses made canonical",
+			subnet:      Subnet{NetworkPrefix: "2001:DB8:0:0::", BitMask: 48, GatewayAddress: "2001:db8:0::0001"},
+			wantPrefix:  "2001:db8::",
+			wantGateway: "2001:db8::1",
+		},
+		{
+			name:       "container with a gateway",
+			subnet:     Subnet{NetworkPrefix: "10.0.0.0", BitMask: 8, GatewayAddress: "10.0.0.1", Container: true},
+			wantParams: []string{"GatewayAddress"},
+		},
+		{
+			name:       "container larger than a /16",
+			subnet:     Subnet{NetworkPrefix: "10.0.0.0", BitMask: 8, Container: true},
+			wantPrefix: "10.0.0.0",
+		},
+		{
+			name:       "every fault reported",
+			subnet:     Subnet{NetworkPrefix: "10.1.2.5", BitMask: 24, GatewayAddress: "10.1.2.255"},
+			wantParams: []string{"NetworkPrefix", "GatewayAddress"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := checkSubnetAddressing(tt.subnet)
+			if names := paramNames(t, err); !reflect.DeepEqual(names, tt.wantParams) {
+				t.Fatalf("fields at fault = %v, want %v (%v)", names, tt.wantParams, err)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.wantReason) {
+					t.Errorf("error %q doesn't mention %q", err, tt.wantReason)
+				}
+				return
+			}
+			if got.NetworkPrefix != tt.wantPrefix {
+				t.Errorf("NetworkPrefix = %s, want %s", got.NetworkPrefix, tt.wantPrefix)
+			}
+			if got.GatewayAddress != tt.wantGateway {
+				t.Errorf("GatewayAddress = %s, want %s", got.GatewayAddress, tt.wantGateway)
+			}
+		})
+	}
+}
+
+// openTestDatabase migrates a new SQLite database holding these subnets:
+//
+//	1 corp  10.0.0.0/8        container
+//	2 site  10.1.0.0/16       container, under corp
+//	3 lab   10.1.2.0/24       under site
+//	4 dmz   192.168.0.0/24
+//	5 v6    2001:db8::/48     container
+func openTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	dialect := sqliteDialect{}
+	pool, err := dialect.open(filepath.Join(t.TempDir(), "ipmanager.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	_, err = db.Migrate(pool, db.Sqlite)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	d := &Database{pool: pool, dialect: dialect}
+	statements := []string{
+		"INSERT INTO Users (UserName, PasswordHash) VALUES ('admin', 'x')",
+		"INSERT INTO Domains (DomainName, CreatorId) VALUES ('example.com', 1)",
+		"INSERT INTO Subnets (NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, ParentId, Container) VALUES ('corp', '10.0.0.0', 8, '', 1, 1, NULL, 1)",
+		"INSERT INTO Subnets (NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, ParentId, Container) VALUES ('site', '10.1.0.0', 16, '', 1, 1, 1, 1)",
+		"INSERT INTO Subnets (NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, ParentId, Container) VALUES ('lab', '10.1.2.0', 24, '10.1.2.1', 1, 1, 2, 0)",
+		"INSERT INTO Subnets (NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, ParentId, Container) VALUES ('dmz', '192.168.0.0', 24, '192.168.0.1', 1, 1, NULL, 0)",
+		"INSERT INTO Subnets (NetworkName, NetworkPrefix, BitMask, GatewayAddress, DomainId, CreatorId, ParentId, Container) VALUES ('v6', '2001:db8::', 48, '', 1, 1, NULL, 1)",
+	}
+	for _, statement := range statements {
+		_, err = d.Exec(statement)
+		if err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	return d
+}
+
+func TestCheckSubnetPlacement(t *testing.T) {
+	d := openTestDatabase(t)
+
+	tests := []struct {
+		name        string
+		subnet      Subnet
+		wantParams  []string
+		wantReason  string
+		wantAdopted []string
+	}{
+		{
+			name:   "top level subnet clear of the others",
+			subnet: Subnet{NetworkName: "new", NetworkPrefix: "172.16.0.0", BitMask: 24, GatewayAddress: "172.16.0.1"},
+		},
+		{
+			name:       "same block as another subnet",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "192.168.0.0", BitMask: 24, GatewayAddress: "192.168.0.1"},
+			wantParams: []string{"NetworkPrefix"},
+			wantReason: "which has the same block",
+		},
+		{
+			name:       "inside a container but not nested in it",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "10.1.3.0", BitMask: 24, GatewayAddress: "10.1.3.1"},
+			wantParams: []string{"NetworkPrefix"},
+			wantReason: "set ParentId to 2",
+		},
+		{
+			name:   "nested in the most specific container",
+			subnet: Subnet{NetworkName: "new", NetworkPrefix: "10.1.3.0", BitMask: 24, GatewayAddress: "10.1.3.1", ParentId: 2},
+		},
+		{
+			name:       "another container lies between it and its parent",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "10.1.3.0", BitMask: 24, GatewayAddress: "10.1.3.1", ParentId: 1},
+			wantParams: []string{"ParentId"},
+			wantReason: "subnet site (10.1.0.0/16) lies between 10.1.3.0/24 and corp",
+		},
+		{
+			name:       "parent isn't a container",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "10.1.2.128", BitMask: 25, GatewayAddress: "10.1.2.129", ParentId: 3},
+			wantParams: []string{"ParentId"},
+			wantReason: "isn't a container block",
+		},
+		{
+			name:       "parent doesn't exist",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "172.16.0.0", BitMask: 24, GatewayAddress: "172.16.0.1", ParentId: 99},
+			wantParams: []string{"ParentId"},
+		},
+		{
+			name:       "parent doesn't hold the block",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "10.2.0.0", BitMask: 24, GatewayAddress: "10.2.0.1", ParentId: 2},
+			wantParams: []string{"ParentId"},
+			wantReason: "doesn't contain 10.2.0.0/24",
+		},
+		{
+			name:       "subnet that would hold another",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "10.1.0.0", BitMask: 20, GatewayAddress: "10.1.0.1", ParentId: 2},
+			wantParams: []string{"NetworkPrefix"},
+			wantReason: "only a container block can hold other subnets",
+		},
+		{
+			name:        "container takes in the subnets alongside it",
+			subnet:      Subnet{NetworkName: "new", NetworkPrefix: "10.1.0.0", BitMask: 20, ParentId: 2, Container: true},
+			wantAdopted: []string{"lab"},
+		},
+		{
+			name:        "top level container takes in a top level subnet",
+			subnet:      Subnet{NetworkName: "new", NetworkPrefix: "192.168.0.0", BitMask: 16, Container: true},
+			wantAdopted: []string{"dmz"},
+		},
+		{
+			name:       "container can't take in subnets nested elsewhere",
+			subnet:     Subnet{NetworkName: "new", NetworkPrefix: "10.1.2.0", BitMask: 23, Container: true},
+			wantParams: []string{"NetworkPrefix", "NetworkPrefix"},
+		},
+		{
+			name:   "nested IPv6 subnet",
+			subnet: Subnet{NetworkName: "new", NetworkPrefix: "2001:db8:0:1::", BitMask: 64, GatewayAddress: "2001:db8:0:1::1", ParentId: 5},
+		},
+		{
+			name:   "subnet moved within its container",
+			subnet: Subnet{Id: 3, NetworkName: "lab", NetworkPrefix: "10.1.4.0", BitMask: 24, GatewayAddress: "10.1.4.1", ParentId: 2},
+		},
+		{
+			name:       "container shrunk away from what's nested in it",
+			subnet:     Subnet{Id: 2, NetworkName: "site", NetworkPrefix: "10.1.128.0", BitMask: 17, ParentId: 1, Container: true},
+			wantParams: []string{"NetworkPrefix"},
+			wantReason: "subnet lab (10.1.2.0/24) is nested under site and would fall outside 10.1.128.0/17",
+		},
+		{
+			name:       "container holding subnets stops being one",
+			subnet:     Subnet{Id: 2, NetworkName: "site", NetworkPrefix: "10.1.0.0", BitMask: 16, GatewayAddress: "10.1.0.1", ParentId: 1},
+			wantParams: []string{"Container"},
+			wantReason: "site has 1 subnets nested under it",
+		},
+		{
+			name:       "container nested under one nested in it",
+			subnet:     Subnet{Id: 1, NetworkName: "corp", NetworkPrefix: "10.0.0.0", BitMask: 8, ParentId: 2, Container: true},
+			wantParams: []string{"ParentId"},
+			wantReason: "subnet site is nested under corp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, block, err := checkSubnetAddressing(tt.subnet)
+			if err != nil {
+				t.Fatalf("checkSubnetAddressing: %v", err)
+			}
+			tx, err := d.Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			defer tx.Rollback()
+
+			adopted, err := checkSubnetPlacement(tx, tt.subnet, block)
+			if names := paramNames(t, err); !reflect.DeepEqual(names, tt.wantParams) {
+				t.Fatalf("fields at fault = %v, want %v (%v)", names, tt.wantParams, err)
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), tt.wantReason) {
+					t.Errorf("error %q doesn't mention %q", err, tt.wantReason)
+				}
+				return
+			}
+			var adoptedNames []string
+			for _, s := range adopted {
+				adoptedNames = append(adoptedNames, s.NetworkName)
+			}
+			if !reflect.DeepEqual(adoptedNames, tt.wantAdopted) {
+				t.Errorf("adopted = %v, want %v", adoptedNames, tt.wantAdopted)
+			}
+		})
+	}
+}
+
+func TestCheckSubnetDomain(t *testing.T) {
+	d := openTestDatabase(t)
+
+	tests := []struct {
+		name       string
+		subnet     Subnet
+		wantParams []string
+	}{
+		{
+			name:   "domain that exists",
+			subnet: Subnet{NetworkName: "new", DomainId: 1},
+		},
+		{
+			name:       "domain that doesn't exist",
+			subnet:     Subnet{NetworkName: "new", DomainId: 42},
+			wantParams: []string{"DomainId"},
+		},
+		{
+			name:       "no domain given",
+			subnet:     Subnet{NetworkName: "new"},
+			wantParams: []string{"DomainId"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := d.Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			defer tx.Rollback()
+
+			err = checkSubnetDomain(tx, tt.subnet)
+			if names := paramNames(t, err); !reflect.DeepEqual(names, tt.wantParams) {
+				t.Errorf("fields at fault = %v, want %v (%v)", names, tt.wantParams, err)
+			}
+		})
+	}
+}
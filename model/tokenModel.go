@@ -0,0 +1,242 @@
+package model
+
+/*
+
+  Copyright 2024, YggdrasilSoft, LLC.
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// tokens are prefixed so they're easy to recognise, and to spot in secret scanners
+const apiTokenPrefix = "ipm_"
+
+func hashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func scanApiToken(scan func(dest ...any) error) (ApiToken, error) {
+	token := ApiToken{}
+	scopes := ""
+	var expiresAt, lastUsed sql.NullString
+	err := scan(
+		&token.Id,
+		&token.UserId,
+		&token.Name,
+		&scopes,
+		&expiresAt,
+		&lastUsed,
+		&token.CreationDate,
+	)
+	if err != nil {
+		return ApiToken{}, err
+	}
+
+	err = json.Unmarshal([]byte(scopes), &token.Scopes)
+	if err != nil {
+		log.Println("ERROR: Failed to unmarshal token scopes")
+		return ApiToken{}, err
+	}
+	token.ExpiresAt = expiresAt.String
+	token.LastUsedDate = lastUsed.String
+	return token, nil
+}
+
+func (r *SqlRepository) CreateApiToken(p ProposedApiToken, userId int) (NewApiToken, error) {
+	log.Println("INFO: Creating API token '" + p.Name + "' for user ID: " + strconv.Itoa(userId))
+	if len(p.Scopes) == 0 {
+		log.Println("ERROR: No scopes requested")
+		return NewApiToken{}, &InvalidScopeValue{Err: errors.New("no scopes requested")}
+	}
+	for _, scope := range p.Scopes {
+		if !IsValidScope(scope) {
+			log.Println("ERROR: Invalid scope value: " + scope)
+			return NewApiToken{}, &InvalidScopeValue{Err: errors.New("invalid value: " + scope)}
+		}
+	}
+
+	var expiresAt any
+	if p.ExpiresAt != "" {
+		expiry, err := time.Parse(time.RFC3339, p.ExpiresAt)
+		if err != nil || !expiry.After(time.Now()) {
+			log.Println("ERROR: Invalid expiry value: " + p.ExpiresAt)
+			return NewApiToken{}, &InvalidExpiryValue{Err: errors.New("invalid value: " + p.ExpiresAt)}
+		}
+		expiresAt = sqlTime(expiry)
+	}
+
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		log.Println("ERROR: Failed to generate token")
+		return NewApiToken{}, err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(secret)
+
+	t, err := r.db.Begin()
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return NewApiToken{}, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to create API token '" + p.Name + "'")
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to create API token '" + p.Name + "'")
+			t.Rollback()
+		}
+	}()
+
+	strJsonScopes, err := json.Marshal(p.Scopes)
+	if err != nil {
+		log.Println("ERROR: Failed to marshal scopes")
+		return NewApiToken{}, err
+	}
+
+	id, err := t.InsertId("INSERT INTO ApiTokens (UserId, Name, TokenHash, Scopes, ExpiresAt) VALUES (?, ?, ?, ?, ?)",
+		userId, p.Name, hashApiToken(token), strJsonScopes, expiresAt)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return NewApiToken{}, err
+	}
+
+	rec := t.QueryRow("SELECT Id, UserId, Name, Scopes, ExpiresAt, LastUsedDate, CreationDate FROM ApiTokens WHERE Id = ?", id)
+	created, err := scanApiToken(rec.Scan)
+	if err != nil {
+		log.Println("ERROR: Failed to scan rows")
+		return NewApiToken{}, err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return NewApiToken{}, err
+	}
+
+	log.Println("INFO: API token " + strconv.Itoa(created.Id) + " created successfully")
+	return NewApiToken{ApiToken: created, Token: token}, nil
+}
+
+func (r *SqlRepository) GetApiTokensByUserId(userId int) ([]ApiToken, error) {
+	log.Println("INFO: Getting API tokens for user ID: " + strconv.Itoa(userId))
+	rows, err := r.db.Query("SELECT Id, UserId, Name, Scopes, ExpiresAt, LastUsedDate, CreationDate FROM ApiTokens WHERE UserId = ? ORDER BY Id", userId)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]ApiToken, 0)
+	for rows.Next() {
+		token, err := scanApiToken(rows.Scan)
+		if err != nil {
+			log.Println("ERROR: Failed to scan rows")
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	log.Println("INFO: Found " + strconv.Itoa(len(tokens)) + " API tokens")
+	return tokens, nil
+}
+
+// GetApiTokenOwner looks up the token and its owner, refusing tokens that have expired. The
+// token's last used date is updated on every successful lookup
+func (r *SqlRepository) GetApiTokenOwner(token string) (ApiToken, User, error) {
+	rec := r.db.QueryRow("SELECT Id, UserId, Name, Scopes, ExpiresAt, LastUsedDate, CreationDate FROM ApiTokens WHERE TokenHash = ? AND (ExpiresAt IS NULL OR ExpiresAt > ?)", hashApiToken(token), sqlTime(time.Now()))
+	apiToken, err := scanApiToken(rec.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Println("ERROR: No valid API token found")
+		}
+		return ApiToken{}, User{}, err
+	}
+
+	user, err := r.GetUserById(apiToken.UserId)
+	if err != nil {
+		return ApiToken{}, User{}, err
+	}
+
+	_, err = r.db.Exec("UPDATE ApiTokens SET LastUsedDate = CURRENT_TIMESTAMP WHERE Id = ?", apiToken.Id)
+	if err != nil {
+		// not being able to record use shouldn't stop the request
+		log.Println("WARN: Failed to update last used date of API token " + strconv.Itoa(apiToken.Id))
+	}
+
+	return apiToken, user, nil
+}
+
+func (r *SqlRepository) DeleteApiToken(tokenId int, userId int) (bool, error) {
+	log.Println("INFO: Deleting API token " + strconv.Itoa(tokenId))
+	t, err := r.db.Begin()
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to delete API token " + strconv.Itoa(tokenId))
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to delete API token " + strconv.Itoa(tokenId))
+			t.Rollback()
+		}
+	}()
+
+	q, err := t.Prepare("DELETE FROM ApiTokens WHERE Id = ? AND UserId = ?")
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	result, err := q.Exec(tokenId, userId)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+	numberOfRows, err := result.RowsAffected()
+	if err != nil {
+		log.Println("ERROR: Failed to get number of rows affected")
+		return false, err
+	}
+	if numberOfRows == 0 {
+		log.Println("ERROR: No API token found with ID: " + strconv.Itoa(tokenId))
+		err = sql.ErrNoRows
+		return false, err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return false, err
+	}
+
+	log.Println("INFO: API token " + strconv.Itoa(tokenId) + " deleted successfully")
+	return true, nil
+}
@@ -18,6 +18,8 @@ package model
 
 */
 
+import "encoding/json"
+
 type Address struct {
 	Id           int    `json:"Id"`
 	Address      string `json:"Address"`
@@ -28,6 +30,23 @@ type Address struct {
 	CreationDate string `json:"CreationDate"`
 }
 
+type AddressAllocation struct {
+	HostName   string `json:"HostName"`
+	DomainName string `json:"DomainName"`
+}
+
+type AddressAssignment struct {
+	Address     string `json:"Address"`
+	HostName    string `json:"HostName"`
+	DomainName  string `json:"DomainName"`
+	NetworkName string `json:"NetworkName"`
+}
+
+type AddressReassignment struct {
+	HostName   string `json:"HostName"`
+	DomainName string `json:"DomainName"`
+}
+
 type Domain struct {
 	Id           int    `json:"Id"`
 	DomainName   string `json:"DomainName"`
@@ -60,22 +79,31 @@ type Subnet struct {
 	DomainId       int    `json:"DomainId"`
 	CreatorId      int    `json:"CreatorId"`
 	CreationDate   string `json:"CreationDate"`
+	ParentId       int    `json:"ParentId"`
+	// Container is set for a block that holds other subnets rather than addresses. It has
+	// no gateway
+	Container bool `json:"Container"`
 }
 
 type User struct {
 	Id              int    `json:"Id"`
 	UserName        string `json:"UserName"`
 	Status          string `json:"Status"`
-	PasswordHash    string `json:"PasswordHash"`
+	PasswordHash    string `json:"-"`
+	Role            string `json:"Role"`
 	CreationDate    string `json:"CreationDate"`
 	LastChangedDate string `json:"LastChangedDate"`
 }
 
+// SubnetUpdate holds the fields of a subnet to change. A field left out keeps its value, so
+// a zero ParentId or an empty GatewayAddress has to be given to clear one
 type SubnetUpdate struct {
-	NetworkPrefix  string `json:"NetworkPrefix"`
-	BitMask        int    `json:"BitMask"`
-	GatewayAddress string `json:"GatewayAddress"`
-	DomainName     string `json:"DomainName"`
+	NetworkPrefix  *string `json:"NetworkPrefix"`
+	BitMask        *int    `json:"BitMask"`
+	GatewayAddress *string `json:"GatewayAddress"`
+	DomainName     *string `json:"DomainName"`
+	ParentId       *int    `json:"ParentId"`
+	Container      *bool   `json:"Container"`
 }
 
 type ProposedUser struct {
@@ -83,6 +111,7 @@ type ProposedUser struct {
 	UserName     string `json:"UserName"`
 	Status       string `json:"Status"`
 	Password     string `json:"Password"`
+	Role         string `json:"Role"`
 	CreationDate string `json:"CreationDate"`
 }
 
@@ -90,8 +119,92 @@ type UserStatus struct {
 	Status string `json:"status"`
 }
 
-type FailureMsg struct {
-	Error string `json:"error"`
+// ApiToken describes a bearer token. The token itself is only ever handed out once, when
+// it's created, as only a hash of it is stored
+type ApiToken struct {
+	Id           int      `json:"Id"`
+	UserId       int      `json:"UserId"`
+	Name         string   `json:"Name"`
+	Scopes       []string `json:"Scopes"`
+	ExpiresAt    string   `json:"ExpiresAt,omitempty"`
+	LastUsedDate string   `json:"LastUsedDate,omitempty"`
+	CreationDate string   `json:"CreationDate"`
+}
+
+type ProposedApiToken struct {
+	Name      string   `json:"Name"`
+	Scopes    []string `json:"Scopes"`
+	ExpiresAt string   `json:"ExpiresAt"`
+}
+
+type NewApiToken struct {
+	ApiToken
+	Token string `json:"Token"`
+}
+
+type ApiTokenList struct {
+	Data []ApiToken `json:"data"`
+}
+
+// Session describes a server side session. The session key itself is never exposed
+type Session struct {
+	Id           int    `json:"Id"`
+	UserName     string `json:"UserName"`
+	ClientIp     string `json:"ClientIp"`
+	CreationDate string `json:"CreationDate"`
+	LastSeenDate string `json:"LastSeenDate"`
+	ExpiresAt    string `json:"ExpiresAt"`
+}
+
+type SessionList struct {
+	Data []Session `json:"data"`
+}
+
+// AuditEntry records a mutating API call. Before and After are snapshots of the object
+// the call targeted, and Request is its body with credentials redacted
+type AuditEntry struct {
+	Id           int             `json:"Id"`
+	UserName     string          `json:"UserName"`
+	Method       string          `json:"Method"`
+	Route        string          `json:"Route"`
+	Path         string          `json:"Path"`
+	ObjectType   string          `json:"ObjectType"`
+	ObjectId     string          `json:"ObjectId"`
+	Before       json.RawMessage `json:"Before" swaggertype:"object"`
+	After        json.RawMessage `json:"After" swaggertype:"object"`
+	Request      json.RawMessage `json:"Request" swaggertype:"object"`
+	StatusCode   int             `json:"StatusCode"`
+	ClientIp     string          `json:"ClientIp"`
+	CreationDate string          `json:"CreationDate"`
+}
+
+type AuditEntryList struct {
+	Data []AuditEntry `json:"data"`
+}
+
+type AuditFilter struct {
+	UserName   string
+	ObjectType string
+	ObjectId   string
+	Method     string
+	Since      string
+	Until      string
+	Limit      int
+	Offset     int
+}
+
+type UserRole struct {
+	Role string `json:"role"`
+}
+
+// Problem is an RFC 7807 problem details body, which every error response carries
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	InvalidParams []InvalidParam `json:"invalidParams,omitempty"`
 }
 
 type SuccessMsg struct {
@@ -103,12 +216,30 @@ type UserStatusMsg struct {
 	UserStatus string `json:"userStatus"`
 }
 
+type UnassignedAddressList struct {
+	Data []string `json:"data"`
+}
+
+// Page says where a page of a list falls in the whole of it
+type Page struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type AddressList struct {
+	Data []Address `json:"data"`
+	Page
+}
+
 type DomainList struct {
 	Data []Domain `json:"data"`
+	Page
 }
 
 type HostList struct {
 	Data []Host `json:"data"`
+	Page
 }
 
 type MacAddressList struct {
@@ -124,6 +255,46 @@ type Subnets struct {
 	Data []Subnet `json:"data"`
 }
 
-type UsersList struct {
-	Data []User `json:"data"`
+// SubnetMatch is the most specific subnet an address falls in, and what the address is
+// used for there. Assignment and HostName are only set for an assigned address
+type SubnetMatch struct {
+	Address         string   `json:"Address"`
+	Subnet          Subnet   `json:"Subnet"`
+	Domain          Domain   `json:"Domain"`
+	AssignmentState string   `json:"AssignmentState"`
+	Assignment      *Address `json:"Assignment,omitempty"`
+	HostName        string   `json:"HostName,omitempty"`
+}
+
+// SubnetUtilisation is how much of a block is in use. The counts are strings, as an IPv6
+// block holds more addresses than fit in a number
+type SubnetUtilisation struct {
+	// Size is every address in the block
+	Size string `json:"Size"`
+	// Allocated is the addresses given over to subnets: all of a subnet's block, and the
+	// blocks of the subnets nested in a container
+	Allocated string `json:"Allocated"`
+	// Usable is the addresses that can be assigned, leaving out gateways and the network,
+	// broadcast and anycast addresses
+	Usable           string  `json:"Usable"`
+	Assigned         string  `json:"Assigned"`
+	AllocatedPercent float64 `json:"AllocatedPercent"`
+	AssignedPercent  float64 `json:"AssignedPercent"`
+}
+
+// SubnetTree is a subnet along with the subnets nested under it. The utilisation of a
+// container is rolled up from everything nested in it
+type SubnetTree struct {
+	Subnet      Subnet            `json:"Subnet"`
+	Utilisation SubnetUtilisation `json:"Utilisation"`
+	Children    []SubnetTree      `json:"Children"`
+}
+
+type SubnetTrees struct {
+	Data []SubnetTree `json:"data"`
+}
+
+type SubnetList struct {
+	Data []Subnet `json:"data"`
+	Page
 }
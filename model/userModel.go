@@ -19,18 +19,17 @@ package model
 */
 
 import (
-	"crypto/sha512"
 	"database/sql"
-	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"time"
 )
 
-func getStoredPasswordHash(username string) (string, error) {
+func (r *SqlRepository) getStoredPasswordHash(username string) (string, error) {
 	log.Println("INFO: Getting stored password hash for user: " + username)
-	q, err := DB.Prepare("SELECT PasswordHash FROM Users WHERE UserName = ?")
+	q, err := r.db.Prepare("SELECT PasswordHash FROM Users WHERE UserName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return "", err
@@ -38,13 +37,7 @@ func getStoredPasswordHash(username string) (string, error) {
 	defer q.Close()
 
 	passwordHash := ""
-	r, err := q.Query(username)
-	if err != nil {
-		log.Println("ERROR: Failed to query for password hash")
-		return "", err
-	}
-	defer r.Close()
-	err = r.Scan(
+	err = q.QueryRow(username).Scan(
 		&passwordHash,
 	)
 	if err != nil {
@@ -60,9 +53,9 @@ func getStoredPasswordHash(username string) (string, error) {
 	return passwordHash, nil
 }
 
-func storeNewPassword(hashedPassword string, username string) (bool, error) {
+func (r *SqlRepository) storeNewPassword(hashedPassword string, username string) (bool, error) {
 	log.Println("INFO: Storing new password hash for user: " + username)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		return false, err
 	}
@@ -78,7 +71,7 @@ func storeNewPassword(hashedPassword string, username string) (bool, error) {
 	}()
 
 	// now we need to create a new transaction to SET the password hash into the DB
-	q, err := DB.Prepare("UPDATE Users SET PasswordHash = ?, LastChangedDate = ? WHERE UserName = ?")
+	q, err := t.Prepare("UPDATE Users SET PasswordHash = ?, LastChangedDate = ? WHERE UserName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return false, err
@@ -103,26 +96,29 @@ func storeNewPassword(hashedPassword string, username string) (bool, error) {
 	return true, nil
 }
 
-func ChangeAccountPassword(username string, oldPassword string, newPassword string) (bool, error) {
-	hashedOldPassword := sha512.Sum512([]byte(oldPassword))
-	encodedHashedOldPassword := hex.EncodeToString(hashedOldPassword[:])
-
-	storedHash, err := getStoredPasswordHash(username)
+func (r *SqlRepository) ChangeAccountPassword(username string, oldPassword string, newPassword string) (bool, error) {
+	storedHash, err := r.getStoredPasswordHash(username)
 	if err != nil {
 		return false, err
 	}
 	log.Println("INFO: Retrieved stored hash")
 
-	// now get password hash from the db
-	if storedHash != encodedHashedOldPassword {
+	// now check the old password against the hash from the db
+	matches, _, err := VerifyPassword(oldPassword, storedHash)
+	if err != nil {
+		return false, err
+	}
+	if !matches {
 		p := new(PasswordHashMismatch)
 		return false, p
 	}
 
 	// matches, so hash new password
-	hashedNewPassword := sha512.Sum512([]byte(newPassword))
-	encodedHashedNewPassword := hex.EncodeToString(hashedNewPassword[:])
-	_, err = storeNewPassword(encodedHashedNewPassword, username)
+	hashedNewPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return false, err
+	}
+	_, err = r.storeNewPassword(hashedNewPassword, username)
 	if err != nil {
 		return false, err
 	}
@@ -131,10 +127,10 @@ func ChangeAccountPassword(username string, oldPassword string, newPassword stri
 	return true, nil
 }
 
-func GetUserById(id int) (User, error) {
+func (r *SqlRepository) GetUserById(id int) (User, error) {
 	log.Println("INFO: Getting user by ID: " + strconv.Itoa(id))
 	idStr := strconv.Itoa(id)
-	rec, err := DB.Prepare("SELECT * FROM Users WHERE Id = ?")
+	rec, err := r.db.Prepare("SELECT Id, UserName, Status, PasswordHash, Role, CreationDate, LastChangedDate FROM Users WHERE Id = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return User{}, err
@@ -142,17 +138,12 @@ func GetUserById(id int) (User, error) {
 	defer rec.Close()
 
 	user := User{}
-	r, err := rec.Query(id)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return User{}, err
-	}
-	defer r.Close()
-	err = r.Scan(
+	err = rec.QueryRow(id).Scan(
 		&user.Id,
 		&user.UserName,
 		&user.Status,
 		&user.PasswordHash,
+		&user.Role,
 		&user.CreationDate,
 		&user.LastChangedDate,
 	)
@@ -169,9 +160,9 @@ func GetUserById(id int) (User, error) {
 	return user, nil
 }
 
-func GetUserByUserName(username string) (User, error) {
+func (r *SqlRepository) GetUserByUserName(username string) (User, error) {
 	log.Println("INFO: Getting user by name: " + username)
-	rec, err := DB.Prepare("SELECT * FROM Users WHERE UserName = ?")
+	rec, err := r.db.Prepare("SELECT Id, UserName, Status, PasswordHash, Role, CreationDate, LastChangedDate FROM Users WHERE UserName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return User{}, err
@@ -179,18 +170,12 @@ func GetUserByUserName(username string) (User, error) {
 	defer rec.Close()
 
 	user := User{}
-	r, err := rec.Query(username)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return User{}, err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = rec.QueryRow(username).Scan(
 		&user.Id,
 		&user.UserName,
 		&user.Status,
 		&user.PasswordHash,
+		&user.Role,
 		&user.CreationDate,
 		&user.LastChangedDate,
 	)
@@ -207,9 +192,9 @@ func GetUserByUserName(username string) (User, error) {
 	return user, nil
 }
 
-func CreateUser(p ProposedUser) (bool, error) {
+func (r *SqlRepository) CreateUser(p ProposedUser) (bool, error) {
 	log.Println("INFO: Creating user: " + p.UserName)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -225,17 +210,31 @@ func CreateUser(p ProposedUser) (bool, error) {
 		}
 	}()
 
-	q, err := t.Prepare("INSERT INTO Users (UserName, PasswordHash) VALUES (?, ?)")
+	q, err := t.Prepare("INSERT INTO Users (UserName, PasswordHash, Role) VALUES (?, ?, ?)")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return false, err
 	}
 
+	// new accounts can only read unless they're explicitly given more
+	role := p.Role
+	if role == "" {
+		role = RoleReadOnly
+	}
+	if !IsValidRole(role) {
+		log.Println("ERROR: Invalid role value: " + role)
+		err = &InvalidRoleValue{Err: errors.New("invalid value: " + role)}
+		return false, err
+	}
+
 	// take password and hash it
-	hash := sha512.Sum512([]byte(p.Password))
-	passwdHash := hex.EncodeToString(hash[:])
+	passwdHash, err := HashPassword(p.Password)
+	if err != nil {
+		log.Println("ERROR: Failed to hash password")
+		return false, err
+	}
 
-	_, err = q.Exec(p.UserName, passwdHash)
+	_, err = q.Exec(p.UserName, passwdHash, role)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
 		return false, err
@@ -251,9 +250,9 @@ func CreateUser(p ProposedUser) (bool, error) {
 	return true, nil
 }
 
-func DeleteUser(username string) (bool, error) {
+func (r *SqlRepository) DeleteUser(username string) (bool, error) {
 	log.Println("INFO: Deleting user: " + username)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -269,17 +268,22 @@ func DeleteUser(username string) (bool, error) {
 		}
 	}()
 
-	q, err := DB.Prepare("DELETE FROM Users WHERE UserName IS ?")
+	q, err := t.Prepare("DELETE FROM Users WHERE UserName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return false, err
 	}
 
-	_, err = q.Exec(username)
+	result, err := q.Exec(username)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
 		return false, err
 	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		log.Println("ERROR: No user found with name " + username)
+		err = &NotFound{Err: fmt.Errorf("no user found with name %s", username)}
+		return false, err
+	}
 
 	err = t.Commit()
 	if err != nil {
@@ -291,12 +295,30 @@ func DeleteUser(username string) (bool, error) {
 	return true, nil
 }
 
-func GetUsers() ([]User, error) {
-	log.Println("INFO: Getting all users")
-	rows, err := DB.Query("SELECT * FROM Users")
+// PasswordHash is never something users can be sorted or filtered on
+var userListing = listTable{
+	table: "Users",
+	columns: []listColumn{
+		{name: "Id", kind: intColumn},
+		{name: "UserName", kind: textColumn},
+		{name: "Status", kind: textColumn},
+		{name: "Role", kind: textColumn},
+		{name: "CreationDate", kind: timeColumn, alias: "created"},
+		{name: "LastChangedDate", kind: timeColumn, alias: "changed"},
+	},
+}
+
+// GetUsers returns a page of the users, along with how many match the filters overall
+func (r *SqlRepository) GetUsers(o ListOptions) ([]User, int, error) {
+	log.Println("INFO: Getting users")
+	query, args, total, err := r.list(userListing, "SELECT Id, UserName, Status, PasswordHash, Role, CreationDate, LastChangedDate FROM Users", o)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		log.Println("ERROR: Failed to execute statement")
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -308,23 +330,24 @@ func GetUsers() ([]User, error) {
 			&user.UserName,
 			&user.Status,
 			&user.PasswordHash,
+			&user.Role,
 			&user.CreationDate,
 			&user.LastChangedDate,
 		)
 		if err != nil {
 			log.Println("ERROR: Failed to scan rows")
-			return nil, err
+			return nil, 0, err
 		}
 		users = append(users, user)
 	}
 
-	log.Println("INFO: Found " + strconv.Itoa(len(users)) + " users")
-	return users, nil
+	log.Println("INFO: Found " + strconv.Itoa(len(users)) + " of " + strconv.Itoa(total) + " users")
+	return users, total, nil
 }
 
-func GetUserStatus(username string) (string, error) {
+func (r *SqlRepository) GetUserStatus(username string) (string, error) {
 	log.Println("INFO: Getting user status for: " + username)
-	q, err := DB.Prepare("SELECT Status FROM Users WHERE UserName IS ?")
+	q, err := r.db.Prepare("SELECT Status FROM Users WHERE UserName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return "", err
@@ -332,14 +355,7 @@ func GetUserStatus(username string) (string, error) {
 	defer q.Close()
 
 	status := ""
-	r, err := q.Query(username)
-	if err != nil {
-		log.Println("ERROR: Failed to execute statement")
-		return "", err
-	}
-	defer r.Close()
-
-	err = r.Scan(
+	err = q.QueryRow(username).Scan(
 		&status,
 	)
 	if err != nil {
@@ -355,9 +371,9 @@ func GetUserStatus(username string) (string, error) {
 	return status, nil
 }
 
-func SetUserStatus(username string, j UserStatus) (bool, error) {
+func (r *SqlRepository) SetUserStatus(username string, j UserStatus) (bool, error) {
 	log.Println("INFO: Setting user status for: " + username)
-	t, err := DB.Begin()
+	t, err := r.db.Begin()
 	if err != nil {
 		log.Println("ERROR: Failed to begin transaction")
 		return false, err
@@ -373,7 +389,7 @@ func SetUserStatus(username string, j UserStatus) (bool, error) {
 		}
 	}()
 
-	q, err := DB.Prepare("UPDATE Users SET Status = ? WHERE UserName = ?")
+	q, err := t.Prepare("UPDATE Users SET Status = ? WHERE UserName = ?")
 	if err != nil {
 		log.Println("ERROR: Failed to prepare statement")
 		return false, err
@@ -397,6 +413,11 @@ func SetUserStatus(username string, j UserStatus) (bool, error) {
 		return false, err
 	}
 	log.Println("INFO: SQL result: Rows: " + strconv.Itoa(int(numberOfRows)))
+	if numberOfRows == 0 {
+		log.Println("ERROR: No user found with name " + username)
+		err = &NotFound{Err: fmt.Errorf("no user found with name %s", username)}
+		return false, err
+	}
 
 	err = t.Commit()
 	if err != nil {
@@ -407,3 +428,186 @@ func SetUserStatus(username string, j UserStatus) (bool, error) {
 	log.Println("INFO: User " + username + " status set to: " + j.Status)
 	return true, nil
 }
+
+func (r *SqlRepository) SetUserRole(username string, j UserRole) (bool, error) {
+	log.Println("INFO: Setting user role for: " + username)
+	if !IsValidRole(j.Role) {
+		log.Println("ERROR: Invalid role value: " + j.Role)
+		return false, &InvalidRoleValue{Err: errors.New("invalid value: " + j.Role)}
+	}
+
+	t, err := r.db.Begin()
+	if err != nil {
+		log.Println("ERROR: Failed to begin transaction")
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("ERROR: Failed to set user role for: " + username)
+			t.Rollback()
+		}
+		if err != nil {
+			log.Println("ERROR: Failed to set user role for: " + username)
+			t.Rollback()
+		}
+	}()
+
+	// never leave the system without an enabled administrator to manage it
+	if j.Role != RoleAdmin {
+		admins := 0
+		err = t.QueryRow("SELECT COUNT(*) FROM Users WHERE Role = ? AND Status = 'enabled' AND UserName != ?", RoleAdmin, username).Scan(&admins)
+		if err != nil {
+			log.Println("ERROR: Failed to count administrators")
+			return false, err
+		}
+		if admins == 0 {
+			log.Println("ERROR: Refusing to remove the last enabled administrator")
+			err = &LastAdministrator{Err: errors.New("user " + username + " is the last enabled administrator")}
+			return false, err
+		}
+	}
+
+	q, err := t.Prepare("UPDATE Users SET Role = ? WHERE UserName = ?")
+	if err != nil {
+		log.Println("ERROR: Failed to prepare statement")
+		return false, err
+	}
+
+	result, err := q.Exec(j.Role, username)
+	if err != nil {
+		log.Println("ERROR: Failed to execute statement")
+		return false, err
+	}
+	numberOfRows, err := result.RowsAffected()
+	if err != nil {
+		log.Println("ERROR: Failed to get number of rows affected")
+		return false, err
+	}
+	if numberOfRows == 0 {
+		log.Println("ERROR: No user found with name: " + username)
+		err = sql.ErrNoRows
+		return false, err
+	}
+
+	err = t.Commit()
+	if err != nil {
+		log.Println("ERROR: Failed to commit transaction")
+		return false, err
+	}
+
+	log.Println("INFO: User " + username + " role set to: " + j.Role)
+	return true, nil
+}
+
+// EnsureExternalUser makes sure a user authenticated by an external identity provider has a
+// local account, so it can own objects and carry a role. New accounts get the given role and
+// a password hash no local login can match. An account with a local password was never made
+// here, and is refused rather than taken over. When syncRole is set, an existing external
+// account's role is brought in line with the provider's on every login
+func (r *SqlRepository) EnsureExternalUser(username string, role string, syncRole bool) (User, error) {
+	log.Println("INFO: Ensuring local account for external user: " + username)
+	if !IsValidRole(role) {
+		log.Println("ERROR: Invalid role value: " + role)
+		return User{}, &InvalidRoleValue{Err: errors.New("invalid value: " + role)}
+	}
+
+	user, err := r.GetUserByUserName(username)
+	if err != nil {
+		return User{}, err
+	}
+
+	if user.UserName == "" {
+		_, err = r.db.Exec("INSERT INTO Users (UserName, PasswordHash, Role) VALUES (?, ?, ?)", username, ExternalPasswordHash, role)
+		if err != nil {
+			log.Println("ERROR: Failed to execute statement")
+			return User{}, err
+		}
+		log.Println("INFO: Provisioned account for external user " + username + " with role " + role)
+		return r.GetUserByUserName(username)
+	}
+
+	if user.PasswordHash != ExternalPasswordHash {
+		log.Println("ERROR: User " + username + " is a local account, not an external one")
+		return User{}, &Conflict{Err: errors.New("user " + username + " is a local account and can't be signed in to through an identity provider")}
+	}
+
+	// an account bound to an OpenID Connect identity only ever belongs to that identity
+	var bound int
+	err = r.db.QueryRow("SELECT COUNT(*) FROM Users WHERE Id = ? AND ExternalSubject IS NOT NULL", user.Id).Scan(&bound)
+	if err != nil {
+		log.Println("ERROR: Failed to check identity binding of user " + username)
+		return User{}, err
+	}
+	if bound != 0 {
+		log.Println("ERROR: User " + username + " is bound to an OpenID Connect identity")
+		return User{}, &Conflict{Err: errors.New("user " + username + " belongs to an OpenID Connect identity")}
+	}
+
+	if syncRole && user.Role != role {
+		_, err = r.db.Exec("UPDATE Users SET Role = ? WHERE UserName = ?", role, username)
+		if err != nil {
+			log.Println("ERROR: Failed to execute statement")
+			return User{}, err
+		}
+		log.Println("INFO: Role of external user " + username + " changed from " + user.Role + " to " + role)
+		user.Role = role
+	}
+
+	return user, nil
+}
+
+// EnsureOidcUser makes sure an OpenID Connect identity has a local account. The account is
+// found by the token's issuer and subject, which the provider never hands to anyone else, so
+// changing a claim like preferred_username can't lead to someone else's account. A new
+// identity gets an account named username, unless that name is already taken. When syncRole
+// is set, the account's role is brought in line with the provider's on every login
+func (r *SqlRepository) EnsureOidcUser(issuer string, subject string, username string, role string, syncRole bool) (User, error) {
+	log.Println("INFO: Ensuring local account for OpenID Connect subject " + subject + " of " + issuer)
+	if !IsValidRole(role) {
+		log.Println("ERROR: Invalid role value: " + role)
+		return User{}, &InvalidRoleValue{Err: errors.New("invalid value: " + role)}
+	}
+
+	var boundName string
+	err := r.db.QueryRow("SELECT UserName FROM Users WHERE ExternalIssuer = ? AND ExternalSubject = ?", issuer, subject).Scan(&boundName)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("ERROR: Failed to look up account of subject " + subject)
+		return User{}, err
+	}
+
+	if err == sql.ErrNoRows {
+		existing, err := r.GetUserByUserName(username)
+		if err != nil {
+			return User{}, err
+		}
+		if existing.UserName != "" {
+			log.Println("ERROR: User " + username + " already exists and isn't bound to subject " + subject)
+			return User{}, &Conflict{Err: errors.New("user " + username + " already exists and doesn't belong to this identity")}
+		}
+
+		_, err = r.db.Exec("INSERT INTO Users (UserName, PasswordHash, Role, ExternalIssuer, ExternalSubject) VALUES (?, ?, ?, ?, ?)",
+			username, ExternalPasswordHash, role, issuer, subject)
+		if err != nil {
+			log.Println("ERROR: Failed to execute statement")
+			return User{}, err
+		}
+		log.Println("INFO: Provisioned account " + username + " for subject " + subject + " with role " + role)
+		return r.GetUserByUserName(username)
+	}
+
+	user, err := r.GetUserByUserName(boundName)
+	if err != nil {
+		return User{}, err
+	}
+	if syncRole && user.Role != role {
+		_, err = r.db.Exec("UPDATE Users SET Role = ? WHERE Id = ?", role, user.Id)
+		if err != nil {
+			log.Println("ERROR: Failed to execute statement")
+			return User{}, err
+		}
+		log.Println("INFO: Role of external user " + user.UserName + " changed from " + user.Role + " to " + role)
+		user.Role = role
+	}
+
+	return user, nil
+}
@@ -22,63 +22,98 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/greeneg/ipmanager/controllers"
+	"github.com/greeneg/ipmanager/middleware"
+	"github.com/greeneg/ipmanager/model"
 )
 
 func PublicRoutes(g *gin.RouterGroup, i *controllers.IpManager) {
 	// address related routes
-	g.GET("/address/:id", i.GetAddressById)                                 // get an addresses details by id
-	g.GET("/address/host/id/:hostid", i.GetAddressByHostNameId)             // get address by host's id
-	g.GET("/address/host/name/:hostname", i.GetAddressByHostName)           // get address by the hosts name
-	g.GET("/address/ip/:ip", i.GetAddressByIpAddress)                       // get the address details by the IP address
-	g.GET("/addresses", i.GetAddresses)                                     // get all addresses
-	g.GET("/addresses/domain/id/:domainid", i.GetAddressesByDomainId)       // get all addresses by domain id
-	g.GET("/addresses/domain/name/:domainname", i.GetAddressesByDomainName) // get all addresses by domain name
-	g.GET("/addresses/subnet/id/:subnetid", i.GetAddressesBySubnetId)       // get all addresses from the subnet id
-	g.GET("/addresses/subnet/name/:subnetname", i.GetAddressesBySubnetName) // get all addresses by the subnet name
-	g.GET("/addresses/subnet/name/:subnetname/unassigned")                  // get all unassigned addresses
+	g.GET("/address/:id", i.GetAddressById)                                                      // get an addresses details by id
+	g.GET("/address/host/id/:hostid", i.GetAddressByHostNameId)                                  // get address by host's id
+	g.GET("/address/host/name/:hostname", i.GetAddressByHostName)                                // get address by the hosts name
+	g.GET("/address/ip/:ip", i.GetAddressByIpAddress)                                            // get the address details by the IP address
+	g.GET("/addresses", i.GetAddresses)                                                          // get all addresses
+	g.GET("/addresses/domain/id/:domainid", i.GetAddressesByDomainId)                            // get all addresses by domain id
+	g.GET("/addresses/domain/name/:domainname", i.GetAddressesByDomainName)                      // get all addresses by domain name
+	g.GET("/addresses/subnet/id/:subnetid", i.GetAddressesBySubnetId)                            // get all addresses from the subnet id
+	g.GET("/addresses/subnet/name/:subnetname", i.GetAddressesBySubnetName)                      // get all addresses by the subnet name
+	g.GET("/addresses/subnet/name/:subnetname/unassigned", i.GetUnassignedAddressesBySubnetName) // get all unassigned addresses
 	// domain related routes
 	g.GET("/domain/id/:domainid", i.GetDomainById)             // get the domain by id
 	g.GET("/domain/name/:domainname", i.GetDomainByDomainName) // get the domain by its domain name
+	g.GET("/domain/name/:domainname/zone", i.GetDomainZone)    // render the domain's BIND zone file
 	g.GET("/domains", i.GetDomains)                            // get all domains
+	// configuration export routes
+	g.GET("/export/dhcpd", i.ExportDhcpd)                        // render an ISC dhcpd configuration
+	g.GET("/export/dnsmasq/domain/:domainname", i.ExportDnsmasq) // render a domain's dnsmasq configuration
+	g.GET("/export/kea/dhcp4", i.ExportKeaDhcp4)                 // render a Kea DHCPv4 configuration
+	g.GET("/export/kea/dhcp6", i.ExportKeaDhcp6)                 // render a Kea DHCPv6 configuration
 	// host related routes
 	g.GET("/host/id/:hostid", i.GetHostById)           // get a host's details by its host id
 	g.GET("/host/name/:hostname", i.GetHostByHostName) // get a host's details by its host name
 	g.GET("/hosts", i.GetHosts)                        // get all hosts
 	// subnet related routes
-	g.GET("/subnet/id/:subnetid", i.GetSubnetById)                      // get a subnet by its id
-	g.GET("/subnet/name/:subnetname", i.GetSubnetByNetworkName)         // get a subnet by its name
-	g.GET("/subnets", i.GetSubnets)                                     // get all subnets
-	g.GET("/subnets/domain/id/:domainid", i.GetSubnetsByDomainId)       // get all subnets by domain id
-	g.GET("/subnets/domain/name/:domainname", i.GetSubnetsByDomainName) // get all subnets by domain name
-	// user related routes
-	g.GET("/user/id/:id", i.GetUserById)           // get a user by id
-	g.GET("/user/name/:name", i.GetUserByUserName) // get a user by name
-	g.GET("/users", i.GetUsers)                    // get all users
+	g.GET("/subnet/id/:subnetid", i.GetSubnetById)                          // get a subnet by its id
+	g.GET("/subnet/name/:subnetname", i.GetSubnetByNetworkName)             // get a subnet by its name
+	g.GET("/subnet/name/:subnetname/reversezones", i.GetSubnetReverseZones) // render the subnet's reverse DNS zones
+	g.GET("/subnet/name/:subnetname/tree", i.GetSubnetTreeByNetworkName)    // get a subnet with the subnets nested under it
+	g.GET("/subnets", i.GetSubnets)                                         // get all subnets
+	g.GET("/subnets/domain/id/:domainid", i.GetSubnetsByDomainId)           // get all subnets by domain id
+	g.GET("/subnets/domain/name/:domainname", i.GetSubnetsByDomainName)     // get all subnets by domain name
+	g.GET("/subnets/containing/:ip", i.GetSubnetContaining)                 // get the most specific subnet holding an address
+	g.GET("/subnets/tree", i.GetSubnetTrees)                                // get the hierarchy of subnets
+	// authentication related routes
+	g.GET("/auth/oidc/login", i.OidcLogin)       // start an OpenID Connect login
+	g.GET("/auth/oidc/callback", i.OidcCallback) // finish an OpenID Connect login
 	// service related routes
 	g.OPTIONS("/")   // API options
 	g.GET("/health") // service health
 }
 
 func PrivateRoutes(g *gin.RouterGroup, i *controllers.IpManager) {
+	// routes open to every authenticated user. API tokens need the matching scope on top of
+	// the role a route requires
+	g.GET("/user/id/:id", middleware.RequireScope(model.ScopeRead), i.GetUserById)                          // get a user by id
+	g.GET("/user/name/:name", middleware.RequireScope(model.ScopeRead), i.GetUserByUserName)                // get a user by name
+	g.GET("/users", middleware.RequireScope(model.ScopeRead), i.GetUsers)                                   // get all users
+	g.PATCH("/user/:name", middleware.RequireScope(model.ScopeAdmin), i.ChangeAccountPassword)              // update a user password
+	g.GET("/user/:name/tokens", middleware.RequireScope(model.ScopeRead), i.GetApiTokens)                   // list a user's API tokens
+	g.POST("/user/:name/tokens", middleware.RequireScope(model.ScopeAdmin), i.CreateApiToken)               // create an API token
+	g.DELETE("/user/:name/tokens/:tokenid", middleware.RequireScope(model.ScopeAdmin), i.DeleteApiToken)    // revoke an API token
+	g.GET("/user/:name/sessions", middleware.RequireScope(model.ScopeRead), i.GetSessions)                  // list a user's sessions
+	g.DELETE("/user/:name/sessions", middleware.RequireScope(model.ScopeAdmin), i.DeleteSessions)           // revoke all of a user's sessions
+	g.DELETE("/user/:name/sessions/:sessionid", middleware.RequireScope(model.ScopeAdmin), i.DeleteSession) // revoke one of a user's sessions
+	g.POST("/logout", i.Logout)                                                                             // end the current session
+
+	// routes for network operators and admins
+	operator := g.Group("", middleware.RequireRole(model.RoleAdmin, model.RoleNetworkOperator), middleware.RequireScope(model.ScopeAssign))
 	// address assignment related routes
-	g.POST("/address")            // assign an address to a host
-	g.PATCH("/address/:address")  // update an address' assignment
-	g.DELETE("/address/:address") // trash an address assignment
+	operator.POST("/address", i.AssignAddress)             // assign an address to a host
+	operator.PATCH("/address/:address", i.ReassignAddress) // update an address' assignment
+	operator.DELETE("/address/:address", i.ReleaseAddress) // trash an address assignment
 	// domain related routes
-	g.POST("/domain", i.CreateDomain)               // create a domain
-	g.DELETE("/domain/:domainname", i.DeleteDomain) // trash a domain
+	operator.POST("/domain", i.CreateDomain) // create a domain
 	// host related routes
-	g.POST("/host", i.CreateHost)                    // create a host
-	g.PATCH("/host/:hostname", i.UpdateMacAddresses) // replace a host's MAC addresses
-	g.DELETE("/host/:hostname", i.DeleteHostname)    // trash a host
+	operator.POST("/host", i.CreateHost)                    // create a host
+	operator.PATCH("/host/:hostname", i.UpdateMacAddresses) // replace a host's MAC addresses
+	operator.DELETE("/host/:hostname", i.DeleteHostname)    // trash a host
+	// subnet related routes
+	operator.POST("/subnet", i.CreateSubnet)                          // create new subnet
+	operator.PATCH("/subnet/:networkname", i.ModifySubnet)            // update a subnet's network information
+	operator.POST("/subnet/:networkname/allocate", i.AllocateAddress) // assign the next free address to a host
+
+	// routes for admins only
+	admin := g.Group("", middleware.RequireRole(model.RoleAdmin), middleware.RequireScope(model.ScopeAdmin))
+	// audit related routes
+	admin.GET("/audit", i.GetAuditLog) // get the record of mutating API calls
+	// domain related routes
+	admin.DELETE("/domain/:domainname", i.DeleteDomain) // trash a domain
 	// subnet related routes
-	g.POST("/subnet", i.CreateSubnet)                // create new subnet
-	g.PATCH("/subnet/:networkname", i.ModifySubnet)  // update a subnet's network information
-	g.DELETE("/subnet/:networkname", i.DeleteSubnet) // trash a subnet
+	admin.DELETE("/subnet/:networkname", i.DeleteSubnet) // trash a subnet
 	// user related routes
-	g.POST("/user", i.CreateUser)                   // create new user
-	g.PATCH("/user/:name", i.ChangeAccountPassword) // update a user password
-	g.PATCH("/user/:name/status", i.SetUserStatus)  // lock a user
-	g.GET("/user/:name/status", i.GetUserStatus)    // get whether a user is locked or not
-	g.DELETE("/user/:name", i.DeleteUser)           // trash a user
+	admin.POST("/user", i.CreateUser)                  // create new user
+	admin.PATCH("/user/:name/status", i.SetUserStatus) // lock a user
+	admin.GET("/user/:name/status", i.GetUserStatus)   // get whether a user is locked or not
+	admin.PATCH("/user/:name/role", i.SetUserRole)     // change a user's role
+	admin.DELETE("/user/:name", i.DeleteUser)          // trash a user
 }